@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var auditCmd = &command{
+	name:    "audit",
+	usage:   "lfinder audit [options]",
+	summary: "Report broken symlinks, symlink loops, and symlinks escaping a tree",
+	args:    []string{},
+	flags: func(fs *flagSet) {
+		auditOptions.search.register(fs)
+		auditOptions.output.register(fs)
+		auditOptions.delete.register(fs)
+	},
+	run: runAudit,
+}
+
+// auditOptions holds the flags of lfinder audit.
+var auditOptions struct {
+	search auditFlags
+	output outputFlags
+	delete deleteFlags
+}
+
+// auditFlags holds the search options of lfinder audit.
+// escapes names the tree whose symlinks must not lead out of it.
+type auditFlags struct {
+	searchFlags
+	escapes string
+}
+
+// register sets up the search options plus the command line option for
+// finding symlinks escaping a tree.
+// Usage:
+//
+//	--escapes   Find symlinks leading out of a tree
+func (o *auditFlags) register(fs *flagSet) {
+	o.searchFlags.register(fs)
+	fs.StringVar(&o.escapes, "escapes", "", "", "Also report symlinks below `root` resolving to a path outside it (root is searched if --path is not given)")
+}
+
+// matcher returns the matcher for the problems the audit looks for.
+func (o *auditFlags) matcher() (lfinder.Matcher, error) {
+	broken := lfinder.BrokenSymlinkMatcher{FS: o.fs(), Loops: !o.skipLoops}
+	if o.escapes == "" {
+		return broken, nil
+	}
+	root, err := lfinder.OSFS{}.EvalSymlinks(o.escapes)
+	if err != nil {
+		return nil, fmt.Errorf("--escapes: %v", err)
+	}
+	return lfinder.MatchAny(lfinder.EscapeMatcher{FS: o.fs(), Root: root}, broken), nil
+}
+
+// runAudit implements lfinder audit, which reports every symlink below the
+// search path whose destination does not exist or that is caught in a loop,
+// and with --escapes, every symlink leading out of a tree.
+func runAudit(fs *flagSet) int {
+	opts, out, del := &auditOptions.search, &auditOptions.output, &auditOptions.delete
+	if fs.NArg() != 0 {
+		return fs.cmd.usageError()
+	}
+	if opts.escapes != "" && len(opts.paths.values) == 0 {
+		opts.paths.values = []string{opts.escapes}
+	}
+	m, err := opts.matcher()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if err := del.check(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	// Symlinks escaping the tree still resolve, and are not to be removed
+	// along with the broken ones.
+	if del.delete && opts.escapes != "" {
+		fmt.Println("Error: --delete cannot be combined with --escapes")
+		return exitError
+	}
+	opts.setupProcess()
+	finder := lfinder.NewFinder(opts.options()...)
+	report := &searchReport{}
+	report.attach(finder)
+	out.streamErrors(finder, report)
+	ctx, cancel := opts.context(context.Background())
+	defer cancel()
+	results := finder.Scan(ctx, m)
+
+	out.scan = &lfinder.ScanMetadata{Roots: opts.roots(), Started: time.Now(),
+		Errors: &report.errs, Summary: report.progress}
+	sink, err := out.sink()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if o, ok := sink.(searchObserver); ok {
+		o.observe(finder, cancel)
+	}
+
+	n, err := out.copy(sink, del.apply(opts.fs(), results))
+	if err := out.finish(ctx, err); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	out.printSummary(report.progress())
+	deleted := del.report()
+	status := opts.exitStatus(ctx, n, report, out.quiet)
+	if !deleted {
+		return exitError
+	}
+	return status
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var benchCmd = &command{
+	name:    "bench",
+	usage:   "lfinder bench [options] [directory]",
+	summary: "Measure search throughput on a generated tree",
+	flags: func(fs *flagSet) {
+		o := &benchOptions
+		fs.Group("Bench options")
+		fs.IntVar(&o.files, "files", "", 100000, "Number of regular `files` in the generated tree")
+		fs.IntVar(&o.dirs, "dirs", "", 1000, "Number of `directories` in the generated tree")
+		fs.IntVar(&o.symlinks, "symlinks", "", 1000, "Number of symlinks to the target in the generated tree, half of them absolute and half relative")
+		fs.IntVar(&o.hardlinks, "hardlinks", "", 1000, "Number of hard links to the target in the generated tree")
+		fs.IntVar(&o.depth, "depth", "", 4, "Depth of the directories of the generated tree below its root")
+		fs.StringVar(&o.workers, "workers", "w", "1,8,32", "Comma-separated `counts` of worker goroutines to run the search with (0 picks a number as find does)")
+		fs.StringVar(&o.engines, "engines", "", "portable,fast,uring", "Comma-separated `engines` to run the search with, as for find --engine")
+		fs.IntVar(&o.runs, "runs", "n", 3, "Number of timed searches per combination, after an untimed one warming the cache, of which the median is reported")
+		fs.BoolVar(&o.keep, "keep", "", false, "Keep the generated tree rather than removing it, and print its path")
+		registerColorFlag(fs)
+	},
+	run: runBench,
+}
+
+// benchOptions holds the flags of lfinder bench.
+var benchOptions struct {
+	files, dirs, symlinks, hardlinks, depth int
+	workers, engines                        string
+	runs                                    int
+	keep                                    bool
+}
+
+// runBench implements lfinder bench. It generates a tree in a temporary
+// directory below the directory argument, the system temporary directory
+// by default, so that the storage to measure is the one searched, then
+// searches it for the links to its target with every combination of engine
+// and worker count and prints their throughput.
+func runBench(fs *flagSet) int {
+	o := &benchOptions
+	if fs.NArg() > 1 {
+		return fs.cmd.usageError()
+	}
+	workers, err := parseCounts(o.workers)
+	if err != nil {
+		fmt.Printf("Error: --workers: %v\n", err)
+		return exitError
+	}
+	engines := strings.Split(o.engines, ",")
+	for _, e := range engines {
+		if !slices.Contains([]string{"portable", "fast", "uring"}, e) {
+			fmt.Printf("Error: --engines: unknown engine %q\n", e)
+			return exitError
+		}
+	}
+	if o.files < 0 || o.dirs < 1 || o.symlinks < 0 || o.hardlinks < 0 || o.depth < 1 || o.runs < 1 {
+		fmt.Println("Error: --dirs, --depth, and --runs must be positive, and the other counts not negative")
+		return exitError
+	}
+
+	parent := os.TempDir()
+	if fs.NArg() == 1 {
+		parent = fs.Arg(0)
+	}
+	root, err := os.MkdirTemp(parent, "lfinder-bench-")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if o.keep {
+		defer fmt.Printf("Tree kept at %s\n", root)
+	} else {
+		defer os.RemoveAll(root)
+	}
+
+	fmt.Printf("Generating %d files, %d directories, %d symlinks, and %d hard links in %s\n",
+		o.files, o.dirs, o.symlinks, o.hardlinks, root)
+	start := time.Now()
+	target, err := generateTree(root, o.files, o.dirs, o.symlinks, o.hardlinks, o.depth)
+	if err != nil {
+		warnf("generating the tree: %v", err)
+		return exitError
+	}
+	fmt.Printf("Generated in %s\n\n", time.Since(start).Round(time.Millisecond))
+
+	fmt.Printf("%-9s %7s %10s %13s %12s %8s\n", "ENGINE", "WORKERS", "TIME", "ENTRIES/S", "DIRS/S", "MATCHES")
+	for _, engine := range engines {
+		for _, n := range workers {
+			r, err := benchSearch(root, target, engine, n, o.runs)
+			if err != nil {
+				warnf("searching with engine %s and %d workers: %v", engine, n, err)
+				return exitError
+			}
+			entries := r.progress.FilesExamined + r.progress.DirsVisited
+			fmt.Printf("%-9s %7s %10s %13.0f %12.0f %8d\n", engine, workerCount(n), r.elapsed.Round(time.Microsecond),
+				float64(entries)/r.elapsed.Seconds(), float64(r.progress.DirsVisited)/r.elapsed.Seconds(), r.matches)
+		}
+	}
+	return 0
+}
+
+// parseCounts parses a comma-separated list of worker counts.
+func parseCounts(s string) ([]int, error) {
+	var counts []int
+	for field := range strings.SplitSeq(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid count %q", field)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+// workerCount formats the worker count n of a bench row.
+func workerCount(n int) string {
+	if n == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(n)
+}
+
+// benchResult is the outcome of the timed searches of a combination: the
+// median duration, and the progress and matches of the last search.
+type benchResult struct {
+	elapsed  time.Duration
+	progress lfinder.Progress
+	matches  int
+}
+
+// benchSearch searches root for the links to target runs times, after a
+// search warming the cache, with engine and n workers.
+func benchSearch(root, target, engine string, n, runs int) (benchResult, error) {
+	var r benchResult
+	mounts, _ := lfinder.Mounts()
+	opts := []lfinder.Option{
+		lfinder.WithRoot(root),
+		lfinder.WithWorkers(n),
+		lfinder.WithMounts(mounts...),
+		lfinder.WithProgress(func(p lfinder.Progress) { r.progress = p }, time.Hour),
+	}
+	if engine != "portable" {
+		opts = append(opts, lfinder.WithEngine(lfinder.Engine(engine)))
+	}
+	finder := lfinder.NewFinder(opts...)
+	times := make([]time.Duration, 0, runs)
+	for i := range runs + 1 {
+		start := time.Now()
+		report, err := finder.Find(context.Background(), target)
+		elapsed := time.Since(start)
+		if err != nil {
+			return r, err
+		}
+		if n := report.Errors.Len(); n > 0 {
+			return r, fmt.Errorf("%d paths could not be examined", n)
+		}
+		if i > 0 {
+			times = append(times, elapsed)
+		}
+		r.matches = len(report.Results)
+	}
+	slices.Sort(times)
+	r.elapsed = times[len(times)/2]
+	return r, nil
+}
+
+// generateTree creates below root a tree of dirs directories at most depth
+// levels deep, files empty regular files, and a target file with symlinks
+// and hardlinks links to it, all spread evenly over the directories, and
+// returns the path of the target.
+func generateTree(root string, files, dirs, symlinks, hardlinks, depth int) (string, error) {
+	// Directory k > 0 is a child of directory (k-1)/fanout, the first one
+	// being root itself, which makes for depth levels below it.
+	fanout := max(2, int(math.Ceil(math.Pow(float64(dirs), 1/float64(depth)))))
+	paths := make([]string, dirs)
+	paths[0] = root
+	for k := 1; k < dirs; k++ {
+		paths[k] = filepath.Join(paths[(k-1)/fanout], fmt.Sprintf("d%d", k))
+		if err := os.Mkdir(paths[k], 0o755); err != nil {
+			return "", err
+		}
+	}
+
+	target := filepath.Join(root, "target")
+	if err := os.WriteFile(target, []byte("lfinder bench target\n"), 0o644); err != nil {
+		return "", err
+	}
+	for i := range files {
+		f, err := os.Create(filepath.Join(paths[i%dirs], fmt.Sprintf("f%d", i)))
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+	}
+	for i := range symlinks {
+		dir := paths[i%dirs]
+		dest := target
+		if i%2 == 1 {
+			rel, err := filepath.Rel(dir, target)
+			if err != nil {
+				return "", err
+			}
+			dest = rel
+		}
+		if err := os.Symlink(dest, filepath.Join(dir, fmt.Sprintf("s%d", i))); err != nil {
+			return "", err
+		}
+	}
+	for i := range hardlinks {
+		if err := os.Link(target, filepath.Join(paths[i%dirs], fmt.Sprintf("h%d", i))); err != nil {
+			if errors.Is(err, errors.ErrUnsupported) {
+				return "", fmt.Errorf("hard links are not supported here; pass --hardlinks 0")
+			}
+			return "", err
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var completionCmd = &command{
+	name:    "completion",
+	usage:   "lfinder completion bash|zsh|fish|powershell",
+	summary: "Print a shell completion script",
+	args:    []string{"bash", "zsh", "fish", "powershell"},
+	run:     runCompletion,
+}
+
+// completionWriters maps each supported shell to its script generator.
+var completionWriters = map[string]func(io.Writer, []completionCommand){
+	"bash":       writeBashCompletion,
+	"zsh":        writeZshCompletion,
+	"fish":       writeFishCompletion,
+	"powershell": writePowerShellCompletion,
+}
+
+// runCompletion implements lfinder completion, which prints a script that
+// completes the subcommands, their flags and flag values, and file arguments.
+func runCompletion(fs *flagSet) int {
+	if fs.NArg() != 1 {
+		return fs.cmd.usageError()
+	}
+	write, ok := completionWriters[fs.Arg(0)]
+	if !ok {
+		fmt.Printf("Error: unsupported shell %q\n", fs.Arg(0))
+		return exitError
+	}
+	write(os.Stdout, completionCommands())
+	return 0
+}
+
+// completionCommand describes a subcommand for the completion scripts.
+type completionCommand struct {
+	name    string
+	summary string
+	flags   []completionFlag
+	args    []string // nil means file names
+}
+
+// completionFlag describes a flag for the completion scripts.
+type completionFlag struct {
+	long, short string
+	usage       string
+	valueName   string   // empty for boolean flags
+	choices     []string // accepted values, if known
+	repeatable  bool
+}
+
+// names returns the spellings of f, long name first.
+func (f completionFlag) names() []string {
+	if f.short == "" {
+		return []string{"--" + f.long}
+	}
+	return []string{"--" + f.long, "-" + f.short}
+}
+
+// files reports whether the value of f is a path.
+func (f completionFlag) files() bool {
+	switch strings.ToLower(f.valueName) {
+	case "path", "file", "directory":
+		return true
+	}
+	return false
+}
+
+// completionCommands collects the commands and their flags from the command table.
+func completionCommands() []completionCommand {
+	var cmds []completionCommand
+	for _, cmd := range commands {
+		fs := newFlagSet(cmd)
+		if cmd.flags != nil {
+			cmd.flags(fs)
+		}
+		fs.registerGeneral()
+
+		c := completionCommand{name: cmd.name, summary: cmd.summary, args: cmd.args}
+		for _, g := range fs.groups {
+			for _, names := range g.flags {
+				f := fs.Lookup(names.long)
+				valueName, usage := flag.UnquoteUsage(f)
+				_, repeatable := f.Value.(interface{ markDefault() })
+				c.flags = append(c.flags, completionFlag{
+					long:       names.long,
+					short:      names.short,
+					usage:      usage,
+					valueName:  valueName,
+					choices:    fs.choices[names.long],
+					repeatable: repeatable,
+				})
+			}
+		}
+		c.flags = append(c.flags, completionFlag{long: "help", usage: "Show this help"})
+		cmds = append(cmds, c)
+	}
+	return cmds
+}
+
+// commandNames returns the names of cmds plus the help and version commands.
+func commandNames(cmds []completionCommand) []string {
+	names := make([]string, 0, len(cmds)+2)
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+	return append(names, "help", "version")
+}
+
+func writeBashCompletion(w io.Writer, cmds []completionCommand) {
+	var names []string
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+
+	fmt.Fprintln(w, `# bash completion for lfinder. Load it with:`)
+	fmt.Fprintln(w, `#   source <(lfinder completion bash)`)
+	fmt.Fprintln(w, `_lfinder() {`)
+	fmt.Fprintln(w, `    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}`)
+	fmt.Fprintln(w, `    local cmd=find i`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        case ${COMP_WORDS[i]} in`)
+	fmt.Fprintf(w, "        %s) cmd=${COMP_WORDS[i]}; break ;;\n", strings.Join(names, "|"))
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    local flags paths values`)
+	fmt.Fprintln(w, `    case $cmd in`)
+	for _, c := range cmds {
+		var flags, paths, values []string
+		for _, f := range c.flags {
+			flags = append(flags, f.names()...)
+			switch {
+			case f.files():
+				paths = append(paths, f.names()...)
+			case f.valueName != "":
+				values = append(values, f.names()...)
+			}
+		}
+		fmt.Fprintf(w, "    %s)\n", c.name)
+		fmt.Fprintf(w, "        flags=%q\n", strings.Join(flags, " "))
+		fmt.Fprintf(w, "        paths=%q\n", strings.Join(paths, " "))
+		fmt.Fprintf(w, "        values=%q\n", strings.Join(values, " "))
+		fmt.Fprintln(w, `        ;;`)
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    case "$cmd $prev" in`)
+	for _, c := range cmds {
+		for _, f := range c.flags {
+			if len(f.choices) == 0 {
+				continue
+			}
+			var cases []string
+			for _, name := range f.names() {
+				cases = append(cases, fmt.Sprintf("%q", c.name+" "+name))
+			}
+			fmt.Fprintf(w, "    %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(cases, "|"), strings.Join(f.choices, " "))
+		}
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `    if [[ " $paths " == *" $prev "* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -f -- "$cur")); return`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    if [[ " $values " == *" $prev "* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=(); return`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    if [[ $cur == -* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -W "$flags" -- "$cur")); return`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    COMPREPLY=()`)
+	fmt.Fprintln(w, `    if ((COMP_CWORD == 1)); then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commandNames(cmds), " "))
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    case $cmd in`)
+	for _, c := range cmds {
+		switch {
+		case c.args == nil:
+		case len(c.args) == 0:
+			fmt.Fprintf(w, "    %s) ;;\n", c.name)
+		default:
+			fmt.Fprintf(w, "    %s) COMPREPLY+=($(compgen -W %q -- \"$cur\")) ;;\n", c.name, strings.Join(c.args, " "))
+		}
+	}
+	fmt.Fprintln(w, `    *) COMPREPLY+=($(compgen -f -- "$cur")) ;;`)
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w, `complete -o filenames -F _lfinder lfinder`)
+}
+
+// zshQuote escapes s for use inside a single-quoted _arguments spec.
+func zshQuote(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+func writeZshCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintln(w, `#compdef lfinder`)
+	fmt.Fprintln(w, `# zsh completion for lfinder. Install it as _lfinder in a directory`)
+	fmt.Fprintln(w, `# on $fpath, or load it with: source <(lfinder completion zsh)`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `_lfinder() {`)
+	fmt.Fprintln(w, `  local -a commands`)
+	fmt.Fprintln(w, `  commands=(`)
+	for _, c := range cmds {
+		fmt.Fprintf(w, "    '%s:%s'\n", c.name, zshQuote(c.summary))
+	}
+	fmt.Fprintln(w, `    'help:List the commands'`)
+	fmt.Fprintln(w, `    'version:Print the version'`)
+	fmt.Fprintln(w, `  )`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `  if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then`)
+	fmt.Fprintln(w, `    _describe -t commands 'lfinder command' commands`)
+	fmt.Fprintln(w, `    _files`)
+	fmt.Fprintln(w, `    return`)
+	fmt.Fprintln(w, `  fi`)
+	fmt.Fprintln(w, `  local cmd=find`)
+	fmt.Fprintln(w, `  if (( ${commands[(I)${words[2]}:*]} )); then`)
+	fmt.Fprintln(w, `    cmd=$words[2]`)
+	fmt.Fprintln(w, `    shift words`)
+	fmt.Fprintln(w, `    (( CURRENT-- ))`)
+	fmt.Fprintln(w, `  fi`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `  case $cmd in`)
+	for _, c := range cmds {
+		var specs []string
+		for _, f := range c.flags {
+			var spec strings.Builder
+			switch {
+			case f.repeatable:
+				spec.WriteString("'*'")
+			case f.short != "":
+				fmt.Fprintf(&spec, "'(-%s --%s)'", f.short, f.long)
+			}
+			if f.short != "" {
+				fmt.Fprintf(&spec, "{-%s,--%s}", f.short, f.long)
+			} else {
+				fmt.Fprintf(&spec, "--%s", f.long)
+			}
+			fmt.Fprintf(&spec, "'[%s]", zshQuote(f.usage))
+			switch {
+			case len(f.choices) > 0:
+				fmt.Fprintf(&spec, ":%s:(%s)", f.valueName, strings.Join(f.choices, " "))
+			case strings.ToLower(f.valueName) == "directory":
+				spec.WriteString(":directory:_files -/")
+			case f.files():
+				fmt.Fprintf(&spec, ":%s:_files", f.valueName)
+			case f.valueName != "":
+				fmt.Fprintf(&spec, ":%s: ", f.valueName)
+			}
+			spec.WriteString("'")
+			specs = append(specs, spec.String())
+		}
+		switch {
+		case c.args == nil:
+			specs = append(specs, "'*:file:_files'")
+		case len(c.args) > 0:
+			specs = append(specs, fmt.Sprintf("'1:argument:(%s)'", strings.Join(c.args, " ")))
+		}
+		fmt.Fprintf(w, "  %s)\n", c.name)
+		fmt.Fprintf(w, "    _arguments -s \\\n      %s\n", strings.Join(specs, " \\\n      "))
+		fmt.Fprintln(w, `    ;;`)
+	}
+	fmt.Fprintln(w, `  esac`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `if [[ $zsh_eval_context[-1] == loadautofunc ]]; then`)
+	fmt.Fprintln(w, `  _lfinder "$@"`)
+	fmt.Fprintln(w, `else`)
+	fmt.Fprintln(w, `  compdef _lfinder lfinder`)
+	fmt.Fprintln(w, `fi`)
+}
+
+// fishQuote returns s as a single-quoted fish string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, cmds []completionCommand) {
+	var others []string
+	for _, c := range cmds {
+		if c.name != "find" {
+			others = append(others, c.name)
+		}
+	}
+
+	fmt.Fprintln(w, `# fish completion for lfinder. Load it with:`)
+	fmt.Fprintln(w, `#   lfinder completion fish | source`)
+	fmt.Fprintln(w, `complete -c lfinder -f`)
+	for _, c := range cmds {
+		fmt.Fprintf(w, "complete -c lfinder -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	fmt.Fprintln(w, `complete -c lfinder -n __fish_use_subcommand -a help -d 'List the commands'`)
+	fmt.Fprintln(w, `complete -c lfinder -n __fish_use_subcommand -a version -d 'Print the version'`)
+
+	for _, c := range cmds {
+		// find also applies when no subcommand is given.
+		cond := "'__fish_seen_subcommand_from " + c.name + "'"
+		if c.name == "find" {
+			cond = "'not __fish_seen_subcommand_from " + strings.Join(others, " ") + "'"
+		}
+		fmt.Fprintln(w)
+		for _, f := range c.flags {
+			line := "complete -c lfinder -n " + cond
+			if f.short != "" {
+				line += " -s " + f.short
+			}
+			line += " -l " + f.long
+			switch {
+			case len(f.choices) > 0:
+				line += " -x -a " + fishQuote(strings.Join(f.choices, " "))
+			case f.files():
+				line += " -r -F"
+			case f.valueName != "":
+				line += " -x"
+			}
+			fmt.Fprintln(w, line+" -d "+fishQuote(f.usage))
+		}
+		switch {
+		case c.args == nil:
+			fmt.Fprintf(w, "complete -c lfinder -n %s -F\n", cond)
+		case len(c.args) > 0:
+			fmt.Fprintf(w, "complete -c lfinder -n %s -a %s\n", cond, fishQuote(strings.Join(c.args, " ")))
+		}
+	}
+}
+
+// psQuote returns s as a single-quoted PowerShell string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// psList returns values as a PowerShell array literal of strings.
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = psQuote(v)
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
+
+func writePowerShellCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintln(w, `# PowerShell completion for lfinder. Load it with:`)
+	fmt.Fprintln(w, `#   lfinder completion powershell | Out-String | Invoke-Expression`)
+	fmt.Fprintln(w, `Register-ArgumentCompleter -Native -CommandName lfinder -ScriptBlock {`)
+	fmt.Fprintln(w, `    param($wordToComplete, $commandAst, $cursorPosition)`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    $flags = @{`)
+	for _, c := range cmds {
+		var names []string
+		for _, f := range c.flags {
+			names = append(names, f.names()...)
+		}
+		fmt.Fprintf(w, "        %s = %s\n", psQuote(c.name), psList(names))
+	}
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintln(w, `    $values = @{`)
+	for _, c := range cmds {
+		for _, f := range c.flags {
+			if f.valueName == "" {
+				continue
+			}
+			for _, name := range f.names() {
+				fmt.Fprintf(w, "        %s = %s\n", psQuote(c.name+" "+name), psList(f.choices))
+			}
+		}
+	}
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintln(w, `    $arguments = @{`)
+	for _, c := range cmds {
+		if c.args != nil {
+			fmt.Fprintf(w, "        %s = %s\n", psQuote(c.name), psList(c.args))
+		}
+	}
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintf(w, "    $commands = %s\n", psList(commandNames(cmds)))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })`)
+	fmt.Fprintln(w, `    if ($wordToComplete -ne '' -and $words.Count -gt 0) { $words = @($words | Select-Object -SkipLast 1) }`)
+	fmt.Fprintln(w, `    $cmd = 'find'`)
+	fmt.Fprintln(w, `    if ($words.Count -gt 0 -and $flags.ContainsKey($words[0])) { $cmd = $words[0] }`)
+	fmt.Fprintln(w, `    $prev = if ($words.Count -gt 0) { $words[-1] } else { '' }`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    $candidates = @()`)
+	fmt.Fprintln(w, `    $files = $false`)
+	fmt.Fprintln(w, `    if ($values.ContainsKey("$cmd $prev")) {`)
+	fmt.Fprintln(w, `        $candidates = $values["$cmd $prev"]`)
+	fmt.Fprintln(w, `        $files = $candidates.Count -eq 0`)
+	fmt.Fprintln(w, `    } elseif ($wordToComplete.StartsWith('-')) {`)
+	fmt.Fprintln(w, `        $candidates = $flags[$cmd]`)
+	fmt.Fprintln(w, `    } else {`)
+	fmt.Fprintln(w, `        if ($words.Count -eq 0) { $candidates = $commands }`)
+	fmt.Fprintln(w, `        if ($arguments.ContainsKey($cmd)) { $candidates += $arguments[$cmd] } else { $files = $true }`)
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {`)
+	fmt.Fprintln(w, `        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)`)
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintln(w, `    if ($files) {`)
+	fmt.Fprintln(w, `        Get-ChildItem -Path "$wordToComplete*" -ErrorAction SilentlyContinue | ForEach-Object {`)
+	fmt.Fprintln(w, `            [System.Management.Automation.CompletionResult]::new($_.FullName, $_.Name, 'ProviderItem', $_.FullName)`)
+	fmt.Fprintln(w, `        }`)
+	fmt.Fprintln(w, `    }`)
+	fmt.Fprintln(w, `}`)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var findCmd = &command{
+	name:    "find",
+	usage:   "lfinder find [options] <target_file_name>...",
+	summary: "Find symlinks and hard links to target files",
+	flags: func(fs *flagSet) {
+		findOptions.search.register(fs)
+		findOptions.output.register(fs)
+		findOptions.delete.register(fs)
+	},
+	run: runFind,
+}
+
+// findOptions holds the flags of lfinder find.
+var findOptions struct {
+	search findFlags
+	output outputFlags
+	delete deleteFlags
+}
+
+// runFind implements lfinder find. Links to all the targets named on the
+// command line or listed with --targets-from are found in a single walk.
+func runFind(fs *flagSet) int {
+	opts, out, del := &findOptions.search, &findOptions.output, &findOptions.delete
+	targets, err := opts.targets(fs.Args())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if len(targets) == 0 && opts.needsTargets() {
+		return fs.cmd.usageError()
+	}
+	if err := del.check(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	opts.setupProcess()
+	finder := opts.finder()
+	report := &searchReport{}
+	report.attach(finder)
+	out.streamErrors(finder, report)
+	ctx, cancel := opts.context(context.Background())
+	defer cancel()
+	results, err := opts.results(ctx, finder, targets)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if !out.quiet {
+		opts.warnDirTargets(targets)
+	}
+
+	out.showTarget = len(targets) > 1
+	out.scan = &lfinder.ScanMetadata{Roots: opts.roots(), Targets: targets, Started: time.Now(),
+		Errors: &report.errs, Summary: report.progress}
+	sink, err := out.sink()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if o, ok := sink.(searchObserver); ok {
+		o.observe(finder, cancel)
+	}
+
+	n, err := out.copy(sink, del.apply(opts.fs(), report.tally(results)))
+	if err := out.finish(ctx, err); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	out.printSummary(report.progress())
+	deleted := del.report()
+	if ctx.Err() == nil && !out.quiet && !del.delete && (opts.maxResults == 0 || n < opts.maxResults) {
+		opts.warnUnaccounted(targets, report)
+	}
+	status := opts.exitStatus(ctx, n, report, out.quiet)
+	if !deleted {
+		return exitError
+	}
+	return status
+}
+
+// filtered reports whether candidates are selected by path with --include,
+// --exclude, --include-re, --exclude-re, --min-depth, or --max-depth, or by
+// size, modification time, owner, group, permissions, or link count, or by
+// ignore or marker files.
+func (o *findFlags) filtered() bool {
+	return len(o.include.values)+len(o.exclude.values)+len(o.includeRE.values)+len(o.excludeRE.values) > 0 ||
+		o.minDepth > 0 || o.maxDepth > 0 || o.minSize > 0 || o.maxSize > 0 ||
+		!o.newerThan.IsZero() || !o.olderThan.IsZero() || len(o.owner.IDs) > 0 || len(o.group.IDs) > 0 ||
+		o.perm.How != lfinder.PermNone || o.dirPerm.How != lfinder.PermNone || o.minNlink > 1 ||
+		o.gitignore || o.noHidden || o.excludeCaches || len(o.excludeIfPresent.values) > 0
+}
+
+// warnDirTargets tells on stderr which targets are directories, for which
+// only symlinks are searched: directories cannot have hard links.
+func (o *findFlags) warnDirTargets(targets []string) {
+	if o.symlinksOnly {
+		return
+	}
+	for _, target := range targets {
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			hint := ""
+			if !o.into {
+				hint = " (add --into to also find symlinks to paths below it)"
+			}
+			warnf("%s is a directory: searching for symlinks to it only%s", target, hint)
+		}
+	}
+}
+
+// warnUnaccounted tells on stderr which targets have hard links that the
+// search did not find, since they lie outside the search paths, and where
+// they are: where else their file system is mounted, or on Windows, which
+// links lie outside the search paths. Nothing is told when path filters may
+// have passed over the links.
+func (o *findFlags) warnUnaccounted(targets []string, report *searchReport) {
+	if o.symlinksOnly || !o.needsTargets() || o.filtered() {
+		return
+	}
+	stat := os.Stat
+	if o.follow == lfinder.FollowNever {
+		stat = os.Lstat
+	}
+	var seen []os.FileInfo
+	for _, target := range targets {
+		info, err := stat(target)
+		if err != nil || info.IsDir() || len(o.types) > 0 && !slices.Contains(o.types, lfinder.FileTypeOf(info.Mode())) {
+			continue
+		}
+		// Links to a file given twice are attributed to its first path.
+		if slices.ContainsFunc(seen, func(other os.FileInfo) bool { return os.SameFile(info, other) }) {
+			continue
+		}
+		seen = append(seen, info)
+		nlink, ok := linkCount(info)
+		names, err := lfinder.LinkNames(target)
+		if err == nil {
+			nlink, ok = uint64(len(names)), true
+		}
+		found := report.hardlinks[target]
+		if !ok || found >= nlink {
+			continue
+		}
+		elsewhere := "they exist elsewhere on its file system"
+		if outside := o.outside(names); len(outside) > 0 {
+			elsewhere = "they are " + strings.Join(outside, ", ")
+		} else if dev, ok := deviceOf(target); ok {
+			if other := o.otherMounts(dev); len(other) > 0 {
+				elsewhere += "; it is also mounted at " + strings.Join(other, ", ")
+			}
+		}
+		warnf("%d of %d links to %s not found under %s: %s",
+			nlink-found, nlink, target, strings.Join(o.roots(), ", "), elsewhere)
+	}
+}
+
+// otherMounts returns the mount points of the file system with device dev
+// that lie outside the search paths.
+func (o *searchFlags) otherMounts(dev uint64) []string {
+	mounts, err := lfinder.Mounts()
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, m := range mounts {
+		if m.Device == dev {
+			paths = append(paths, m.Path)
+		}
+	}
+	return o.outside(paths)
+}
+
+// outside returns the paths that do not lie below any search path.
+func (o *searchFlags) outside(paths []string) []string {
+	var roots, out []string
+	for _, root := range o.roots() {
+		if abs, err := filepath.Abs(root); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	for _, path := range paths {
+		if !slices.ContainsFunc(roots, func(root string) bool { return within(path, root) }) {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// searchReport collects what is reported about a search besides its
+// results: the paths that could not be examined and the final progress
+// snapshot, which tells how much of the tree was covered.
+type searchReport struct {
+	errs lfinder.ErrorReport
+	// streamed is set when the errors are written to stderr as they occur,
+	// which replaces the summary of them.
+	streamed bool
+
+	// hardlinks counts the hard links found to each target.
+	hardlinks map[string]uint64
+
+	mu       sync.Mutex
+	coverage lfinder.Progress
+}
+
+// tally counts the hard links among results as they are yielded.
+func (r *searchReport) tally(results iter.Seq[lfinder.Result]) iter.Seq[lfinder.Result] {
+	r.hardlinks = make(map[string]uint64)
+	return func(yield func(lfinder.Result) bool) {
+		for result := range results {
+			if result.Kind == lfinder.KindHardlink {
+				r.hardlinks[result.Target]++
+			}
+			if !yield(result) {
+				return
+			}
+		}
+	}
+}
+
+// attach makes f report its errors and final progress to r.
+func (r *searchReport) attach(f *lfinder.Finder) {
+	f.OnError = r.errs.Add
+	f.OnProgress = func(p lfinder.Progress) {
+		r.mu.Lock()
+		r.coverage = p
+		r.mu.Unlock()
+	}
+	// Only the final snapshot is needed; it is sent whatever the interval.
+	f.ProgressInterval = time.Hour
+}
+
+// progress returns the final progress snapshot of the search.
+func (r *searchReport) progress() lfinder.Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.coverage
+}
+
+// exitStatus reports how a search that produced n results ended and returns
+// the exit code for it. Paths that could not be examined are summarized on
+// stderr unless quiet is set; they only make the search fail when a search
+// root itself is unreadable. A quiet search that found something succeeds
+// regardless of errors, as with grep -q. When the search timed out, the part
+// of the tree it covered is reported, and so is a search for hard links that
+// stopped early because it found all of them.
+func (o *searchFlags) exitStatus(ctx context.Context, n int, report *searchReport, quiet bool) int {
+	if quiet && n > 0 {
+		return exitFound
+	}
+	if !quiet && !report.streamed {
+		printErrorSummary(&report.errs)
+	}
+	if p := report.progress(); p.Complete && !quiet {
+		warnf("all %d links located; stopped without searching the rest of the tree", p.Hardlinks)
+	}
+	failed := false
+	for err := range report.errs.All() {
+		if slices.Contains(o.roots(), err.Path) {
+			warnf("cannot search %s: %v", err.Path, err.Err)
+			failed = true
+		}
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		p := report.progress()
+		last := ""
+		if p.CurrentPath != "" {
+			last = fmt.Sprintf(" (last entered %s)", p.CurrentPath)
+		}
+		warnf("search timed out after %s, having covered %d directories and %d files%s; results are incomplete",
+			o.timeout, p.DirsVisited, p.FilesExamined, last)
+		return exitError
+	case failed:
+		return exitError
+	case n == 0:
+		return exitNotFound
+	}
+	return exitFound
+}
+
+// printErrorSummary reports on stderr how many paths could not be examined.
+func printErrorSummary(errs *lfinder.ErrorReport) {
+	if summary := errs.Summary(); summary != "" {
+		warnf("skipped paths: %s", summary)
+	}
+}
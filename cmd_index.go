@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var indexCmd = &command{
+	name:    "index",
+	usage:   "lfinder index [options] build [path...] | lfinder index [options] update | lfinder index [options] query <target_file_name>...",
+	summary: "Record the links in a tree, and look up the links to targets in the record",
+	args:    []string{"build", "update", "query"},
+	flags: func(fs *flagSet) {
+		o := &indexOptions
+		o.search.register(fs)
+		o.output.register(fs)
+		fs.Group("Index options")
+		fs.StringVar(&o.file, "index", "", defaultIndexPath(), "Read or write the index at `file`")
+	},
+	run: runIndex,
+}
+
+// indexOptions holds the flags of lfinder index.
+var indexOptions struct {
+	search searchFlags
+	output outputFlags
+	file   string
+}
+
+// defaultIndexPath returns the index used when --index is not given:
+// lfinder/index in the user's cache directory, e.g. ~/.cache/lfinder/index.
+func defaultIndexPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "lfinder.index"
+	}
+	return filepath.Join(dir, "lfinder", "index")
+}
+
+// runIndex implements lfinder index. Its first argument selects the action:
+// build walks the paths given after it, or the search paths, and records
+// every link found as inventory does; update brings the record up to date,
+// reading only the directories modified since; query looks up the links to
+// the targets given after it in the record, as find would have found them
+// when it was last built or updated, without walking anything.
+func runIndex(fs *flagSet) int {
+	if fs.NArg() == 0 {
+		return fs.cmd.usageError()
+	}
+	args := fs.Args()[1:]
+	switch fs.Arg(0) {
+	case "build":
+		return buildIndex(args, nil)
+	case "update":
+		if len(args) != 0 {
+			return fs.cmd.usageError()
+		}
+		ix, err := readIndex(indexOptions.file)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitError
+		}
+		return buildIndex(ix.Roots, ix)
+	case "query":
+		if len(args) == 0 {
+			return fs.cmd.usageError()
+		}
+		return queryIndex(args)
+	}
+	fmt.Printf("Error: unknown index action %q (build, update, or query)\n", fs.Arg(0))
+	return exitError
+}
+
+// buildIndex walks paths, added to the search paths, and writes the index
+// of the links found, unless the walk did not complete. With prev set, the
+// paths are its roots, and only the directories modified since it was built
+// are read.
+func buildIndex(paths []string, prev *lfinder.Index) int {
+	o := &indexOptions
+	opts := &o.search
+	// The paths given are search paths as they are, while --path may list
+	// several separated by commas.
+	roots := slices.Clone(paths)
+	if prev == nil && (len(opts.paths.values) > 0 || len(paths) == 0) {
+		roots = append(opts.roots(), paths...)
+	}
+	// The destinations of symlinks are recorded as absolute paths, which
+	// the paths of the links should be too for the index to be usable
+	// from any directory.
+	for i, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitError
+		}
+		roots[i] = abs
+	}
+	opts.exactRoots = roots
+
+	opts.setupProcess()
+	finder := lfinder.NewFinder(opts.options()...)
+	report := &searchReport{}
+	report.attach(finder)
+	ctx, cancel := opts.context(context.Background())
+	defer cancel()
+	started := time.Now()
+	var ix *lfinder.Index
+	var err error
+	if prev != nil {
+		ix, err = finder.UpdateIndex(ctx, prev)
+	} else {
+		ix, err = finder.BuildIndex(ctx)
+	}
+	printErrorSummary(&report.errs)
+	for err := range report.errs.All() {
+		if slices.Contains(roots, err.Path) {
+			warnf("cannot index %s: %v", err.Path, err.Err)
+			return exitError
+		}
+	}
+	if err != nil {
+		warnf("%s not written: the search did not complete", o.file)
+		return exitError
+	}
+
+	if err := writeIndex(o.file, ix); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if !o.output.quiet {
+		fmt.Printf("Indexed %d links in %d directories, reading %d of them in %s, written to %s\n",
+			len(ix.Links), len(ix.Dirs), report.progress().DirsVisited, time.Since(started).Round(time.Millisecond), o.file)
+	}
+	return exitFound
+}
+
+// writeIndex saves ix at path in a new database, replacing any previous
+// index only once it is completely written.
+func writeIndex(path string, ix *lfinder.Index) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	db, err := lfinder.OpenIndexDB(file.Name())
+	if err != nil {
+		file.abort()
+		return err
+	}
+	if err := db.Save(ix); err != nil {
+		db.Close()
+		file.abort()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		file.abort()
+		return err
+	}
+	return file.commit()
+}
+
+// openIndex opens the index at path, which must exist.
+func openIndex(path string) (*lfinder.IndexDB, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no index at %s; build one with lfinder index build", path)
+		}
+		return nil, err
+	}
+	return lfinder.OpenIndexDB(path)
+}
+
+// readIndex loads the index at path.
+func readIndex(path string) (*lfinder.Index, error) {
+	db, err := openIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	ix, err := db.Load()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ix, nil
+}
+
+// queryIndex prints the links to targets recorded in the index.
+func queryIndex(targets []string) int {
+	o := &indexOptions
+	out := &o.output
+	db, err := openIndex(o.file)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+	roots, err := db.Roots()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	results, err := db.Query(o.search.fs(), targets...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	out.showTarget = len(targets) > 1
+	out.noProgress = true
+	out.scan = &lfinder.ScanMetadata{Roots: roots, Targets: targets, Started: time.Now(),
+		Errors: &lfinder.ErrorReport{}}
+	sink, err := out.sink()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	ctx := context.Background()
+	n, err := out.copy(sink, slices.Values(results))
+	if err := out.finish(ctx, err); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if n == 0 {
+		return exitNotFound
+	}
+	return exitFound
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var inventoryCmd = &command{
+	name:    "inventory",
+	usage:   "lfinder inventory [options]",
+	summary: "List every hard link group and symlink in a tree",
+	args:    []string{},
+	flags: func(fs *flagSet) {
+		inventoryOptions.search.register(fs)
+		inventoryOptions.output.register(fs)
+	},
+	run: runInventory,
+}
+
+// inventoryOptions holds the flags of lfinder inventory.
+var inventoryOptions struct {
+	search searchFlags
+	output outputFlags
+}
+
+// runInventory implements lfinder inventory, which reports every link below
+// the search path without a target: the files with several hard links,
+// grouped by inode, and the symlinks with their destinations.
+func runInventory(fs *flagSet) int {
+	opts, out := &inventoryOptions.search, &inventoryOptions.output
+	if fs.NArg() != 0 {
+		return fs.cmd.usageError()
+	}
+	opts.setupProcess()
+	finder := lfinder.NewFinder(opts.options()...)
+	report := &searchReport{}
+	report.attach(finder)
+	out.streamErrors(finder, report)
+	ctx, cancel := opts.context(context.Background())
+	defer cancel()
+	results := finder.Scan(ctx, lfinder.LinkMatcher{FS: opts.fs()})
+
+	out.inventory = true
+	out.scan = &lfinder.ScanMetadata{Roots: opts.roots(), Started: time.Now(),
+		Errors: &report.errs, Summary: report.progress}
+	sink, err := out.sink()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if o, ok := sink.(searchObserver); ok {
+		o.observe(finder, cancel)
+	}
+
+	n, err := out.copy(sink, results)
+	if err := out.finish(ctx, err); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	out.printSummary(report.progress())
+	return opts.exitStatus(ctx, n, report, out.quiet)
+}
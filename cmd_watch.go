@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+var watchCmd = &command{
+	name:    "watch",
+	usage:   "lfinder watch [options] <target_file_name>...",
+	summary: "Repeat a search and report links as they appear and disappear",
+	flags: func(fs *flagSet) {
+		watchOptions.search.register(fs)
+		fs.Group("Watch options")
+		fs.DurationVar(&watchOptions.interval, "interval", "i", time.Minute, "Time between scans")
+		registerColorFlag(fs)
+	},
+	run: runWatch,
+}
+
+// watchOptions holds the flags of lfinder watch.
+var watchOptions struct {
+	search   findFlags
+	interval time.Duration
+}
+
+// runWatch implements lfinder watch. It prints the links found by an initial
+// search, then rescans every interval and prints "+ " and "- " lines for links
+// that were added or removed, until interrupted.
+func runWatch(fs *flagSet) int {
+	opts, interval := &watchOptions.search, watchOptions.interval
+	targets, err := opts.targets(fs.Args())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	if !opts.needsTargets() {
+		fmt.Println("Error: --inode, --target-pattern, and --target-regex are not supported by watch")
+		return exitError
+	}
+	if len(targets) == 0 {
+		return fs.cmd.usageError()
+	}
+	opts.warnDirTargets(targets)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts.setupProcess()
+	finder := opts.finder()
+	var previous map[string]lfinder.Result
+	for {
+		scanCtx, cancel := opts.context(ctx)
+		report, err := finder.Find(scanCtx, targets...)
+		cancel()
+		if ctx.Err() != nil {
+			return exitFound
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitError
+		}
+
+		sink := lfinder.NewConsoleSink()
+		sink.ShowTarget = len(targets) > 1
+		sink.Color = color.enabled(os.Stdout)
+		current := make(map[string]lfinder.Result, len(report.Results))
+		for _, result := range report.Results {
+			current[result.Path] = result
+			if _, ok := previous[result.Path]; !ok {
+				if previous != nil {
+					fmt.Print("+ ")
+				}
+				sink.Emit(result)
+			}
+		}
+		for path, result := range previous {
+			if _, ok := current[path]; !ok {
+				fmt.Print("- ")
+				sink.Emit(result)
+			}
+		}
+		if previous == nil {
+			printErrorSummary(report.Errors)
+		}
+		previous = current
+
+		select {
+		case <-ctx.Done():
+			return exitFound
+		case <-time.After(interval):
+		}
+	}
+}
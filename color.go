@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// colorMode is the value of --color: auto, always, or never.
+type colorMode string
+
+// color selects when output is colored.
+var color colorMode = "auto"
+
+func (m *colorMode) String() string { return string(*m) }
+
+func (m *colorMode) Set(value string) error {
+	switch value {
+	case "auto", "always", "never":
+		*m = colorMode(value)
+		return nil
+	}
+	return fmt.Errorf("must be auto, always, or never")
+}
+
+// enabled reports whether output written to f should be colored. In auto
+// mode it is when f is a terminal, unless NO_COLOR is set or TERM is dumb.
+func (m colorMode) enabled(f *os.File) bool {
+	switch m {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// registerColorFlag sets up the command line option selecting when output
+// is colored.
+func registerColorFlag(fs *flagSet) {
+	fs.Var(&color, "color", "", "Color the output: `when` auto, always, or never")
+	fs.Choices("color", "auto", "always", "never")
+}
+
+// warnf writes a message about the search to stderr, in red when colored.
+func warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if color.enabled(os.Stderr) {
+		msg = "\x1b[31m" + msg + "\x1b[0m"
+	}
+	fmt.Fprintln(os.Stderr, "lfinder: "+msg)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configAliases maps configuration keys onto the flags they set, for keys
+// that read more naturally in the plural.
+var configAliases = map[string]string{
+	"skip-dirs": "skip-dir",
+}
+
+// commandLineOnly lists the flags that are never set by a configuration file
+// or the environment: removing files must be asked for on each run.
+var commandLineOnly = map[string]bool{
+	"delete": true,
+	"yes":    true,
+}
+
+// envPrefix starts the name of every environment variable lfinder reads.
+const envPrefix = "LFINDER_"
+
+// configEntry is one key of a configuration file with its value, or values
+// when the key holds a list.
+type configEntry struct {
+	key    string
+	values []string
+	line   int
+}
+
+// defaultConfigPath returns the configuration file read when --config is not
+// given: lfinder/config.yaml in the user's configuration directory, e.g.
+// ~/.config/lfinder/config.yaml.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "lfinder", "config.yaml")
+}
+
+// configArg returns the value of a --config flag in args, if any. It is
+// needed before the flags are parsed so the file can supply their defaults.
+func configArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// loadConfig sets the flags of fs from the configuration file at path, or
+// from the file named by LFINDER_CONFIG or the default configuration file if
+// path is empty. A missing default
+// file is not an error. Keys naming flags fs does not have are ignored, so
+// one file can hold the options of every command.
+func (fs *flagSet) loadConfig(path string) error {
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return nil
+		}
+	}
+	entries, err := readConfig(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.key
+		if alias, ok := configAliases[name]; ok {
+			name = alias
+		}
+		f := fs.Lookup(name)
+		if f == nil || commandLineOnly[name] {
+			continue
+		}
+		if err := setDefault(f, entry.values); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, entry.line, entry.key, err)
+		}
+	}
+	return nil
+}
+
+// loadEnv sets the flags of fs from LFINDER_* environment variables. The
+// variable for a flag is its long name in upper case with dashes replaced by
+// underscores, e.g. LFINDER_WORKERS for --workers; the plural configuration
+// keys are accepted too, e.g. LFINDER_SKIP_DIRS. Repeatable flags take a
+// comma-separated list.
+func (fs *flagSet) loadEnv() error {
+	names := make(map[string]string) // environment variable -> flag name
+	for _, g := range fs.groups {
+		for _, flag := range g.flags {
+			names[envName(flag.long)] = flag.long
+		}
+	}
+	for key, name := range configAliases {
+		if fs.Lookup(name) != nil {
+			names[envName(key)] = name
+		}
+	}
+
+	for env, name := range names {
+		value, ok := os.LookupEnv(env)
+		if !ok || name == "config" || commandLineOnly[name] {
+			continue
+		}
+		f := fs.Lookup(name)
+		values := []string{value}
+		if _, isList := f.Value.(interface{ markDefault() }); isList {
+			values = strings.Split(value, ",")
+		}
+		if err := setDefault(f, values); err != nil {
+			return fmt.Errorf("%s: %v", env, err)
+		}
+	}
+	return nil
+}
+
+// envName returns the environment variable that sets the named flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// setDefault sets f to values, which come from a configuration layer below
+// the command line, so that repeatable flags given on the command line (or a
+// higher layer) replace them.
+func setDefault(f *flag.Flag, values []string) error {
+	for _, value := range values {
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid value %q: %v", value, err)
+		}
+	}
+	if l, ok := f.Value.(interface{ markDefault() }); ok {
+		l.markDefault()
+	}
+	return nil
+}
+
+// readConfig parses the YAML configuration file at path. Only the subset of
+// YAML needed for flat option files is supported: "key: value" pairs,
+// comments, quoted strings, and lists written either inline as [a, b] or as
+// "- item" lines below an empty key. Underscores in keys are read as dashes.
+func readConfig(path string) ([]configEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []configEntry
+	list := -1 // index of the entry whose "- item" lines are being read
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok || trimmed == "-" {
+			if list < 0 {
+				return nil, fmt.Errorf("%s:%d: list item outside of a list", path, n)
+			}
+			value, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			entries[list].values = append(entries[list].values, value)
+			continue
+		}
+
+		if strings.TrimLeft(line, " \t") != line {
+			return nil, fmt.Errorf("%s:%d: nested keys are not supported", path, n)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, n)
+		}
+		entry := configEntry{key: strings.ReplaceAll(strings.TrimSpace(key), "_", "-"), line: n}
+		value = strings.TrimSpace(value)
+		list = -1
+		switch {
+		case value == "":
+			list = len(entries)
+			entries = append(entries, entry)
+			continue
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				item, err := unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+				}
+				entry.values = append(entry.values, item)
+			}
+		default:
+			value, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			entry.values = []string{value}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// stripComment removes a trailing "# comment" from line, leaving '#'
+// characters inside quotes or words alone. A quote opens only at the start
+// of a value, so that an apostrophe in a word, as in don't, does not.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && startsValue(line[:i]):
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// startsValue reports whether a value starts after before: a key, the
+// opening bracket or a comma of a list, or the dash of a list item.
+func startsValue(before string) bool {
+	before = strings.TrimRight(before, " \t")
+	if strings.TrimLeft(before, " \t") == "-" {
+		return true
+	}
+	return before != "" && strings.ContainsRune(":[,", rune(before[len(before)-1]))
+}
+
+// unquote returns value without its surrounding quotes, if it has any.
+func unquote(value string) (string, error) {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			return strconv.Unquote(value)
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+		}
+	}
+	return value, nil
+}
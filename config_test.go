@@ -0,0 +1,216 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a configuration file holding content to a temporary
+// directory and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// findCommand returns a flag set for lfinder find along with the flags it
+// sets.
+func findCommand() (*flagSet, *findFlags, *deleteFlags) {
+	var search findFlags
+	var del deleteFlags
+	cmd := &command{name: "find", flags: func(fs *flagSet) {
+		search.register(fs)
+		del.register(fs)
+	}}
+	fs := newFlagSet(cmd)
+	cmd.flags(fs)
+	return fs, &search, &del
+}
+
+func TestStripComment(t *testing.T) {
+	for line, want := range map[string]string{
+		"# whole line":                 "",
+		"workers: 4  # four":           "workers: 4  ",
+		"path: /srv#1":                 "path: /srv#1",
+		"name: don't  # note":          "name: don't  ",
+		"name: 'a # b'  # note":        "name: 'a # b'  ",
+		`name: "it's # here" # note`:   `name: "it's # here" `,
+		"list: ['#a', b's]  # note":    "list: ['#a', b's]  ",
+		"  - '#not a comment'  # note": "  - '#not a comment'  ",
+		"  - rock'n'roll # note":       "  - rock'n'roll ",
+	} {
+		if got := stripComment(line); got != want {
+			t.Errorf("stripComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	path := writeConfig(t, `---
+# options for every command
+workers: 4  # comment
+format: "json"
+log_level: 'warn'
+name: don't  # note
+skip-dirs: [/proc, "/a b", ]
+exclude:
+  - '*.tmp'
+  - '#not a comment'
+include:
+path: /srv#1
+`)
+	got, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []configEntry{
+		{key: "workers", values: []string{"4"}, line: 3},
+		{key: "format", values: []string{"json"}, line: 4},
+		{key: "log-level", values: []string{"warn"}, line: 5},
+		{key: "name", values: []string{"don't"}, line: 6},
+		{key: "skip-dirs", values: []string{"/proc", "/a b"}, line: 7},
+		{key: "exclude", values: []string{"*.tmp", "#not a comment"}, line: 8},
+		{key: "include", line: 11},
+		{key: "path", values: []string{"/srv#1"}, line: 12},
+	}
+	if !slices.EqualFunc(got, want, func(a, b configEntry) bool {
+		return a.key == b.key && a.line == b.line && slices.Equal(a.values, b.values)
+	}) {
+		t.Errorf("readConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	for config, want := range map[string]string{
+		"workers: 2\n- a\n":        ":2: list item outside of a list",
+		"search:\n  workers: 2\n":  ":2: nested keys are not supported",
+		"workers\n":                `:1: expected "key: value"`,
+		"format: \"json\\q\"\n":    ":1: invalid syntax",
+		"skip-dirs: [\"/a\\q\"]\n": ":1: invalid syntax",
+		"exclude:\n  - \"\\q\"\n":  ":2: invalid syntax",
+	} {
+		_, err := readConfig(writeConfig(t, config))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("readConfig(%q) = %v, want an error containing %q", config, err, want)
+		}
+	}
+}
+
+// TestLoadConfig checks that the configuration file sets the defaults of the
+// flags it names, but never those of --delete and --yes, and that the
+// command line overrides them.
+func TestLoadConfig(t *testing.T) {
+	config := writeConfig(t, "workers: 4\nskip-dirs: [/a, /b]\ntimeout: 1m\nsymlinks: true\ndelete: true\nyes: true\nunknown: 1\n")
+
+	fs, search, del := findCommand()
+	if code, ok := fs.parse([]string{"--config", config}); !ok {
+		t.Fatalf("parse failed with code %d", code)
+	}
+	if search.workers != 4 || !slices.Equal(search.skipDirs.values, []string{"/a", "/b"}) ||
+		search.timeout.String() != "1m0s" || !search.symlinksOnly {
+		t.Errorf("workers %d, skip dirs %q, timeout %s, symlinks %v; want the values of the configuration file",
+			search.workers, search.skipDirs.values, search.timeout, search.symlinksOnly)
+	}
+	if del.delete || del.yes {
+		t.Error("--delete or --yes set from the configuration file")
+	}
+
+	fs, search, _ = findCommand()
+	if code, ok := fs.parse([]string{"--config", config, "-w", "16", "--skip-dir", "/e"}); !ok {
+		t.Fatalf("parse failed with code %d", code)
+	}
+	if search.workers != 16 || !slices.Equal(search.skipDirs.values, []string{"/e"}) {
+		t.Errorf("workers %d, skip dirs %q; want those of the command line", search.workers, search.skipDirs.values)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	fs, _, _ := findCommand()
+	if err := fs.loadConfig(writeConfig(t, "workers: many\n")); err == nil || !strings.Contains(err.Error(), "config.yaml:1: workers: invalid value") {
+		t.Errorf("loadConfig(invalid value) = %v", err)
+	}
+	missing := filepath.Join(dir, "missing.yaml")
+	if err := fs.loadConfig(missing); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("loadConfig(%s) = %v, want an error", missing, err)
+	}
+
+	// The default file may be missing.
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	if err := fs.loadConfig(""); err != nil {
+		t.Errorf("loadConfig() without a default file = %v", err)
+	}
+}
+
+func TestConfigArg(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--config", "a.yaml", "target"}, "a.yaml"},
+		{[]string{"-config=b.yaml"}, "b.yaml"},
+		{[]string{"-w", "4", "--config=c.yaml"}, "c.yaml"},
+		{[]string{"--", "--config", "d.yaml"}, ""},
+		{[]string{"config"}, ""},
+		{[]string{"--config"}, ""},
+	} {
+		if got := configArg(tt.args); got != tt.want {
+			t.Errorf("configArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestLoadEnv checks that LFINDER_* variables override the configuration
+// file, and the command line overrides them, but never set --delete and
+// --yes.
+func TestLoadEnv(t *testing.T) {
+	config := writeConfig(t, "workers: 4\nskip-dirs: [/a, /b]\ntimeout: 1m\nsymlinks: true\n")
+	t.Setenv("LFINDER_WORKERS", "8")
+	t.Setenv("LFINDER_SKIP_DIRS", "/c,/d")
+	t.Setenv("LFINDER_SYMLINKS", "false")
+	t.Setenv("LFINDER_DELETE", "true")
+	t.Setenv("LFINDER_YES", "true")
+
+	fs, search, del := findCommand()
+	if code, ok := fs.parse([]string{"--config", config}); !ok {
+		t.Fatalf("parse failed with code %d", code)
+	}
+	if search.workers != 8 || !slices.Equal(search.skipDirs.values, []string{"/c", "/d"}) ||
+		search.timeout.String() != "1m0s" || search.symlinksOnly {
+		t.Errorf("workers %d, skip dirs %q, timeout %s, symlinks %v; want 8, [/c /d], 1m0s, false",
+			search.workers, search.skipDirs.values, search.timeout, search.symlinksOnly)
+	}
+	if del.delete || del.yes {
+		t.Error("--delete or --yes set from the environment")
+	}
+
+	fs, search, _ = findCommand()
+	if code, ok := fs.parse([]string{"--config", config, "-w", "16"}); !ok {
+		t.Fatalf("parse failed with code %d", code)
+	}
+	if search.workers != 16 {
+		t.Errorf("workers %d, want those of the command line", search.workers)
+	}
+}
+
+func TestLoadEnvErrors(t *testing.T) {
+	t.Setenv("LFINDER_WORKERS", "many")
+	fs, _, _ := findCommand()
+	if err := fs.loadEnv(); err == nil || !strings.Contains(err.Error(), "LFINDER_WORKERS") {
+		t.Errorf("loadEnv() = %v, want an error naming LFINDER_WORKERS", err)
+	}
+
+	// A configuration file named by LFINDER_CONFIG must exist.
+	missing := filepath.Join(t.TempDir(), "gone.yaml")
+	t.Setenv("LFINDER_CONFIG", missing)
+	if err := fs.loadConfig(""); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("loadConfig() = %v, want an error naming %s", err, missing)
+	}
+}
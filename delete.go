@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"strings"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+// deleteFlags holds the options for removing the symlinks a search finds.
+// delete removes them; yes removes them without asking about each one.
+// The counts tell what became of the results once the search is over.
+type deleteFlags struct {
+	delete bool
+	yes    bool
+
+	removed, declined, failed, kept int
+}
+
+// register sets up the command line options for removing the symlinks found.
+// Usage:
+//
+//	    --delete            Remove the symlinks found
+//	-y, --yes               Do not ask before removing each one
+func (o *deleteFlags) register(fs *flagSet) {
+	fs.Group("Delete options")
+	fs.BoolVar(&o.delete, "delete", "", false, "Remove the symlinks found, asking about each one unless --yes is given, and print only those removed; hard links and other files found are left in place")
+	fs.BoolVar(&o.yes, "yes", "y", false, "Remove the symlinks found by --delete without asking")
+}
+
+// check validates --delete and --yes against the output options, and turns
+// off the progress line, which would get in the way of the questions.
+// Without --yes, the answers are read from standard input, which must be a
+// terminal so that a removal is never confirmed by a pipe or a device.
+func (o *deleteFlags) check(out *outputFlags) error {
+	if !o.delete {
+		if o.yes {
+			return errors.New("--yes is only meaningful with --delete")
+		}
+		return nil
+	}
+	if out.quiet || out.tui {
+		return errors.New("--delete cannot be combined with --quiet or --tui")
+	}
+	if !o.yes && !isTTY(os.Stdin) {
+		return errors.New("--delete asks before removing each symlink: standard input must be a terminal, or pass --yes")
+	}
+	out.noProgress = true
+	return nil
+}
+
+// apply removes the symlinks among results from fsys, the file system
+// searched, after confirmation unless --yes is set, and returns those
+// removed. Without --delete, results are returned as they are. The symlinks
+// are removed once the search is over, so that removing one does not break
+// the resolution of others still being examined. A symlink is removed only
+// if it is still the symlink found, with the same stored destination: the
+// link is unlinked and what it leads to left alone. Answering q, or end of
+// input, keeps the remaining ones.
+func (o *deleteFlags) apply(fsys lfinder.FS, results iter.Seq[lfinder.Result]) iter.Seq[lfinder.Result] {
+	if !o.delete {
+		return results
+	}
+	if fsys == nil {
+		fsys = lfinder.OSFS{}
+	}
+	return func(yield func(lfinder.Result) bool) {
+		var links []lfinder.Result
+		for r := range results {
+			if r.Mode.Type() != fs.ModeSymlink || r.LinkTarget == "" {
+				o.kept++
+				continue
+			}
+			links = append(links, r)
+		}
+		answers := bufio.NewReader(os.Stdin)
+		all := o.yes
+		for i, r := range links {
+			if !all {
+				switch ask(answers, r) {
+				case 'n':
+					o.declined++
+					continue
+				case 'a':
+					all = true
+				case 'q':
+					o.declined += len(links) - i
+					return
+				}
+			}
+			if err := removeSymlink(fsys, r); err != nil {
+				warnf("cannot remove %s: %v", r.Path, err)
+				o.failed++
+				continue
+			}
+			o.removed++
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// ask asks on stderr whether to remove the symlink r and returns the answer:
+// y to remove it, n to keep it, a to remove it and every other one, or q to
+// stop. Other answers ask again.
+func ask(answers *bufio.Reader, r lfinder.Result) byte {
+	for {
+		fmt.Fprintf(os.Stderr, "remove symlink %s -> %s? [y]es/[n]o/[a]ll/[q]uit ", r.Path, r.LinkTarget)
+		line, err := answers.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch {
+		case answer == "y" || answer == "yes":
+			return 'y'
+		case answer == "n" || answer == "no":
+			return 'n'
+		case answer == "a" || answer == "all":
+			return 'a'
+		case answer == "q" || answer == "quit":
+			return 'q'
+		case err == io.EOF:
+			fmt.Fprintln(os.Stderr)
+			return 'q'
+		}
+	}
+}
+
+// removeSymlink unlinks the symlink found at r.Path from fsys, unless the
+// path no longer holds a symlink with the destination it was found with.
+func removeSymlink(fsys lfinder.FS, r lfinder.Result) error {
+	rfs, ok := fsys.(lfinder.RemoveFS)
+	if !ok {
+		return errors.New("the file system searched does not support removing files")
+	}
+	info, err := rfs.Lstat(r.Path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSymlink {
+		return errors.New("no longer a symlink")
+	}
+	dest, err := rfs.Readlink(r.Path)
+	if err != nil {
+		return err
+	}
+	if dest != r.LinkTarget {
+		return errors.New("destination changed since it was found")
+	}
+	return rfs.Remove(r.Path)
+}
+
+// report tells on stderr how many symlinks were removed, and how many
+// results were left in place, and returns whether every removal attempted
+// succeeded.
+func (o *deleteFlags) report() bool {
+	if !o.delete {
+		return true
+	}
+	msg := fmt.Sprintf("removed %d symlinks", o.removed)
+	if o.declined > 0 {
+		msg += fmt.Sprintf(", kept %d on request", o.declined)
+	}
+	if o.kept > 0 {
+		msg += fmt.Sprintf(", left %d results that are not symlinks", o.kept)
+	}
+	if o.failed > 0 {
+		msg += fmt.Sprintf(", failed to remove %d", o.failed)
+	}
+	fmt.Fprintln(os.Stderr, "lfinder: "+msg)
+	return o.failed == 0
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+func TestDeleteCheck(t *testing.T) {
+	if err := (&deleteFlags{}).check(&outputFlags{}); err != nil {
+		t.Errorf("check() without --delete = %v", err)
+	}
+	if err := (&deleteFlags{yes: true}).check(&outputFlags{}); err == nil || !strings.Contains(err.Error(), "only meaningful with --delete") {
+		t.Errorf("check() with --yes alone = %v", err)
+	}
+	for _, out := range []outputFlags{{quiet: true}, {tui: true}} {
+		if err := (&deleteFlags{delete: true, yes: true}).check(&out); err == nil || !strings.Contains(err.Error(), "--quiet or --tui") {
+			t.Errorf("check() with %+v = %v", out, err)
+		}
+	}
+	if !isTTY(os.Stdin) {
+		if err := (&deleteFlags{delete: true}).check(&outputFlags{}); err == nil || !strings.Contains(err.Error(), "must be a terminal") {
+			t.Errorf("check() without a terminal = %v", err)
+		}
+	}
+	var out outputFlags
+	if err := (&deleteFlags{delete: true, yes: true}).check(&out); err != nil {
+		t.Errorf("check() with --yes = %v", err)
+	}
+	if !out.noProgress {
+		t.Error("check() left the progress line on")
+	}
+}
+
+// linkResult returns the result for the symlink at path, as found.
+func linkResult(t *testing.T, path string) lfinder.Result {
+	t.Helper()
+	dest, err := os.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lfinder.Result{Path: path, Kind: lfinder.KindSymlink, LinkTarget: dest, Mode: fs.ModeSymlink | 0o777}
+}
+
+func TestDeleteApply(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	if err := os.WriteFile(path("target"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(path("target"), path("hard")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	// A chain: removing first must not keep second from being removed.
+	for name, dest := range map[string]string{"first": "second", "second": "target", "changed": "target", "gone": "target"} {
+		if err := os.Symlink(dest, path(name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	results := []lfinder.Result{
+		linkResult(t, path("first")),
+		linkResult(t, path("second")),
+		{Path: path("hard"), Kind: lfinder.KindHardlink, Mode: 0o644},
+		linkResult(t, path("changed")),
+		linkResult(t, path("gone")),
+	}
+	// Changed after they were found.
+	if err := os.Remove(path("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("elsewhere", path("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path("gone")); err != nil {
+		t.Fatal(err)
+	}
+
+	del := &deleteFlags{delete: true, yes: true}
+	var removed []string
+	for r := range del.apply(nil, slices.Values(results)) {
+		removed = append(removed, filepath.Base(r.Path))
+	}
+	if want := []string{"first", "second"}; !slices.Equal(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	if del.removed != 2 || del.kept != 1 || del.failed != 2 || del.declined != 0 {
+		t.Errorf("removed %d, kept %d, failed %d, declined %d; want 2, 1, 2, 0", del.removed, del.kept, del.failed, del.declined)
+	}
+	if del.report() {
+		t.Error("report() succeeded despite failures")
+	}
+	for name, exists := range map[string]bool{"first": false, "second": false, "changed": true, "hard": true, "target": true} {
+		if _, err := os.Lstat(path(name)); (err == nil) != exists {
+			t.Errorf("%s: exists %v, want %v", name, err == nil, exists)
+		}
+	}
+}
+
+func TestDeleteApplyRoot(t *testing.T) {
+	// An image whose etc is reached through a link absolute in the image:
+	// the links found below it are removed through the image, not through
+	// the host's /etc.
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "config")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	link := filepath.Join(root, "etc", "lfinder-test-link")
+	if err := os.Symlink("/missing", link); err != nil {
+		t.Fatal(err)
+	}
+	r := linkResult(t, link)
+	r.Path = filepath.Join(root, "config", "lfinder-test-link")
+
+	del := &deleteFlags{delete: true, yes: true}
+	for range del.apply(lfinder.ChrootFS{Root: root}, slices.Values([]lfinder.Result{r})) {
+	}
+	if del.removed != 1 {
+		t.Fatalf("removed %d links, failed %d", del.removed, del.failed)
+	}
+	if _, err := os.Lstat(link); err == nil {
+		t.Errorf("%s was not removed", link)
+	}
+}
+
+func TestDeleteOff(t *testing.T) {
+	results := []lfinder.Result{{Path: "/a", Kind: lfinder.KindSymlink, LinkTarget: "b", Mode: fs.ModeSymlink}}
+	del := &deleteFlags{}
+	var got []lfinder.Result
+	for r := range del.apply(nil, slices.Values(results)) {
+		got = append(got, r)
+	}
+	if len(got) != 1 || del.removed != 0 || !del.report() {
+		t.Errorf("without --delete, got %v, removed %d", got, del.removed)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr = stderr }()
+	for input, want := range map[string]byte{
+		"y\n":        'y',
+		"YES\n":      'y',
+		"n\n":        'n',
+		" no \n":     'n',
+		"a\n":        'a',
+		"all\n":      'a',
+		"q\n":        'q',
+		"maybe\nn\n": 'n',
+		"":           'q',
+		"maybe":      'q',
+		"y":          'y',
+	} {
+		r := lfinder.Result{Path: "/a", LinkTarget: "b"}
+		if got := ask(bufio.NewReader(strings.NewReader(input)), r); got != want {
+			t.Errorf("ask(%q) = %c, want %c", input, got, want)
+		}
+	}
+}
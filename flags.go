@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flagSet is a flag.FlagSet whose flags have a GNU-style long name and an
+// optional one-letter alias, and whose help text lists them in titled groups.
+// Both -name and --name spellings are accepted, as with the flag package.
+type flagSet struct {
+	*flag.FlagSet
+	cmd     *command
+	groups  []flagGroup
+	choices map[string][]string // long name -> accepted values
+}
+
+// flagGroup is a titled set of flags shown together in the help text.
+type flagGroup struct {
+	title string
+	flags []flagNames
+}
+
+// flagNames are the names a flag is registered under.
+type flagNames struct {
+	long, short string
+}
+
+// newFlagSet returns an empty flagSet for cmd.
+func newFlagSet(cmd *command) *flagSet {
+	fs := &flagSet{FlagSet: flag.NewFlagSet(cmd.name, flag.ContinueOnError), cmd: cmd}
+	fs.Usage = fs.printUsage
+	return fs
+}
+
+// Group starts a new group; flags registered afterwards are listed under title.
+func (fs *flagSet) Group(title string) {
+	fs.groups = append(fs.groups, flagGroup{title: title})
+}
+
+// Choices records the values accepted by the flag long, for shell completion.
+func (fs *flagSet) Choices(long string, values ...string) {
+	if fs.choices == nil {
+		fs.choices = make(map[string][]string)
+	}
+	fs.choices[long] = values
+}
+
+// alias registers short as another name for the flag long and records the
+// flag in the current group.
+func (fs *flagSet) alias(long, short string) {
+	if short != "" {
+		f := fs.Lookup(long)
+		fs.FlagSet.Var(f.Value, short, f.Usage)
+	}
+	if len(fs.groups) == 0 {
+		fs.Group("Options")
+	}
+	g := &fs.groups[len(fs.groups)-1]
+	g.flags = append(g.flags, flagNames{long: long, short: short})
+}
+
+func (fs *flagSet) BoolVar(p *bool, long, short string, value bool, usage string) {
+	fs.FlagSet.BoolVar(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) StringVar(p *string, long, short string, value string, usage string) {
+	fs.FlagSet.StringVar(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) IntVar(p *int, long, short string, value int, usage string) {
+	fs.FlagSet.IntVar(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) Uint64Var(p *uint64, long, short string, value uint64, usage string) {
+	fs.FlagSet.Uint64Var(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) DurationVar(p *time.Duration, long, short string, value time.Duration, usage string) {
+	fs.FlagSet.DurationVar(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) TextVar(p encoding.TextUnmarshaler, long, short string, value encoding.TextMarshaler, usage string) {
+	fs.FlagSet.TextVar(p, long, value, usage)
+	fs.alias(long, short)
+}
+
+func (fs *flagSet) Var(value flag.Value, long, short string, usage string) {
+	fs.FlagSet.Var(value, long, usage)
+	fs.alias(long, short)
+}
+
+// negatedBool is a boolean flag that clears the variable it points to, for
+// --no-X options undoing --X. It has no state of its own, so it always
+// reads as unset.
+type negatedBool struct{ p *bool }
+
+func (b negatedBool) String() string { return "false" }
+
+func (b negatedBool) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*b.p = !v
+	return nil
+}
+
+func (b negatedBool) IsBoolFlag() bool { return true }
+
+// choiceFlag is a boolean flag storing a value in a variable shared by a set
+// of mutually exclusive options, such as --only-absolute and --only-relative,
+// so that the last one given wins. Clearing the flag resets the variable to
+// its zero value if the flag had set it. The flag selecting the zero value
+// reads as set from the start, so its default is not shown in the usage.
+type choiceFlag[T comparable] struct {
+	p     *T
+	value T
+}
+
+func (f choiceFlag[T]) String() string {
+	if f.p != nil && *f.p == f.value {
+		return "true"
+	}
+	return "false"
+}
+
+func (f choiceFlag[T]) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	var zero T
+	switch {
+	case v:
+		*f.p = f.value
+	case *f.p == f.value:
+		*f.p = zero
+	}
+	return nil
+}
+
+func (f choiceFlag[T]) IsBoolFlag() bool { return true }
+
+func (f choiceFlag[T]) isChoice() {}
+
+// printUsage prints the usage line of the command followed by its flags,
+// group by group.
+func (fs *flagSet) printUsage() {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: %s\n", fs.cmd.usage)
+	for _, g := range fs.groups {
+		fmt.Fprintf(w, "\n%s:\n", g.title)
+		for _, names := range g.flags {
+			f := fs.Lookup(names.long)
+			valueName, usage := flag.UnquoteUsage(f)
+
+			left := "    --" + names.long
+			if names.short != "" {
+				left = "-" + names.short + ", --" + names.long
+			}
+			if valueName != "" {
+				left += " " + strings.ToUpper(valueName)
+			}
+			if _, choice := f.Value.(interface{ isChoice() }); !choice && !isZeroDefault(f.DefValue) {
+				usage += fmt.Sprintf(" (default %q)", f.DefValue)
+			}
+			if len(left) > 28 {
+				fmt.Fprintf(w, "  %s\n  %-28s %s\n", left, "", usage)
+			} else {
+				fmt.Fprintf(w, "  %-28s %s\n", left, usage)
+			}
+		}
+	}
+	fmt.Fprintf(w, "  %-28s %s\n", "    --help", "Show this help")
+}
+
+// isZeroDefault reports whether a flag default is not worth printing.
+func isZeroDefault(value string) bool {
+	switch value {
+	case "", "false", "0", "0s", "[]":
+		return true
+	}
+	return false
+}
+
+// registerGeneral adds the options every command accepts. The value of
+// --config is read by configArg before parsing, so it is not stored.
+func (fs *flagSet) registerGeneral() {
+	fs.Group("General options")
+	fs.StringVar(new(string), "config", "", "", "Read default options from `file` (default ~/.config/lfinder/config.yaml)")
+	registerLogFlags(fs)
+}
+
+// parse loads the configuration file and the LFINDER_* environment
+// variables, then parses args. Flags override the environment, which
+// overrides the configuration file.
+// It returns the exit code to use if either failed; asking for help is not
+// a failure. The general options are registered here, after the command's
+// own flags, so they are listed last.
+func (fs *flagSet) parse(args []string) (code int, ok bool) {
+	fs.registerGeneral()
+	if err := fs.loadConfig(configArg(args)); err != nil {
+		fmt.Fprintf(fs.Output(), "lfinder: config: %v\n", err)
+		return exitError, false
+	}
+	if err := fs.loadEnv(); err != nil {
+		fmt.Fprintf(fs.Output(), "lfinder: environment: %v\n", err)
+		return exitError, false
+	}
+
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0, false
+	case err != nil:
+		return exitError, false
+	}
+	if err := setupLogger(); err != nil {
+		fmt.Fprintf(fs.Output(), "lfinder: %v\n", err)
+		return exitError, false
+	}
+	return 0, true
+}
@@ -0,0 +1,17 @@
+//go:build !(linux || darwin)
+
+package main
+
+import "io/fs"
+
+// usedInodes is not implemented on this platform; the progress line has no
+// ETA.
+func usedInodes(path string) (uint64, bool) { return 0, false }
+
+func deviceOf(path string) (uint64, bool) { return 0, false }
+
+func deviceNumber(path string) (uint64, bool) { return 0, false }
+
+func linkCount(info fs.FileInfo) (uint64, bool) { return 0, false }
+
+func makeDevice(major, minor uint64) (uint64, bool) { return 0, false }
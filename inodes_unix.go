@@ -0,0 +1,59 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"io/fs"
+	"runtime"
+	"syscall"
+)
+
+// usedInodes returns the number of files on the file system holding path.
+func usedInodes(path string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil || st.Files < st.Ffree {
+		return 0, false
+	}
+	return st.Files - st.Ffree, true
+}
+
+// deviceOf returns the device number of the file system holding path.
+func deviceOf(path string) (uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// linkCount returns the number of hard links of the file described by info.
+func linkCount(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Nlink), true
+}
+
+// deviceNumber returns the device number path stands for: the device itself
+// for a block device such as /dev/sda1, otherwise the device of the file
+// system holding path.
+func deviceNumber(path string) (uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, false
+	}
+	if st.Mode&syscall.S_IFMT == syscall.S_IFBLK {
+		return uint64(st.Rdev), true
+	}
+	return uint64(st.Dev), true
+}
+
+// makeDevice returns the device number for a major and minor number pair,
+// encoded as the kernel does in st_dev.
+func makeDevice(major, minor uint64) (uint64, bool) {
+	if runtime.GOOS == "darwin" {
+		return major<<24 | minor&0xffffff, true
+	}
+	return (major&0xfffff000)<<32 | (major&0xfff)<<8 | (minor&0xffffff00)<<12 | minor&0xff, true
+}
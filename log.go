@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// logFlags holds the options controlling the diagnostics written to stderr,
+// which every command accepts.
+// level is the minimum level of the messages written.
+// format selects text or JSON log lines.
+var logFlags struct {
+	level  slog.Level
+	format string
+}
+
+// logger writes the diagnostics selected by logFlags. It discards everything
+// until setupLogger is called.
+var logger = slog.New(slog.DiscardHandler)
+
+// registerLogFlags sets up the command line options for logging.
+// Usage:
+//
+//	--log-level    Minimum level of diagnostics: debug, info, warn, or error
+//	--log-format   Format of diagnostics: text or json
+func registerLogFlags(fs *flagSet) {
+	logFlags.level = slog.LevelError
+	fs.TextVar(&logFlags.level, "log-level", "", &logFlags.level, "Log diagnostics at `level` and above: debug, info, warn, or error")
+	fs.Choices("log-level", "debug", "info", "warn", "error")
+	fs.StringVar(&logFlags.format, "log-format", "", "text", "Log `format`: text or json")
+	fs.Choices("log-format", "text", "json")
+}
+
+// setupLogger creates logger from the logging flags.
+func setupLogger() error {
+	opts := &slog.HandlerOptions{Level: logFlags.level}
+	switch logFlags.format {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("unknown log format %q", logFlags.format)
+	}
+	return nil
+}
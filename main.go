@@ -1,125 +1,117 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sync"
-	"syscall"
+	"runtime/debug"
 )
 
-// symlinksOnly represents a boolean flag that indicates whether only symbolic links should be considered.
-// hardlinksOnly represents a boolean flag that indicates whether only hard links should be considered.
-// searchPath represents the path to be searched for symlinks or hardlinks.
-var (
-	symlinksOnly  bool
-	hardlinksOnly bool
-	searchPath    string
-)
-
-// init is a function that initializes the command line flags for the program.
-// It sets up the command line options for finding symlinks only, finding hardlinks only, and specifying the search path.
-// Usage:
-//
-//	-s   Find symlinks only
-//	-h   Find hardlinks only
-//	-p   Path to start the search from
-func init() {
-	flag.BoolVar(&symlinksOnly, "s", false, "Find symlinks only")
-	flag.BoolVar(&hardlinksOnly, "h", false, "Find hardlinks only")
-	flag.StringVar(&searchPath, "p", "/", "Path to start the search from")
-}
+// version is the release version, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version string
 
-// checkAndSendSymlink checks if a given path is a symbolic link pointing to the specified target.
-// If the path is a valid symbolic link and its resolved target matches the specified target,
-// it sends the path along with its resolved target to the results channel.
-func checkAndSendSymlink(path, target string, results chan<- string) {
-	resolved, err := filepath.EvalSymlinks(path)
-	if err != nil || resolved != target {
-		return
+// versionString returns version, falling back to the module version
+// recorded by go install.
+func versionString() string {
+	if version != "" {
+		return version
 	}
-	linkTarget, _ := os.Readlink(path)
-	results <- fmt.Sprintf("%s (symlink) -> %s", path, linkTarget)
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
 }
 
-// checkAndSendHardlink checks if the given file at `path` is a hardlink to the target file with `targetInode`.
-// If it is a hardlink, it sends the path to the `results` channel.
-func checkAndSendHardlink(path string, targetInode uint64, fileInfo os.FileInfo, results chan<- string) {
-	if fileInfo.Sys().(*syscall.Stat_t).Ino == targetInode {
-		results <- fmt.Sprintf("%s (hardlink)", path)
-	}
+// Exit codes. As with grep, the status tells scripts whether anything was
+// found: exitFound when at least one link was reported, exitNotFound when
+// none was, and exitError when the search could not be run or completed.
+const (
+	exitFound    = 0
+	exitNotFound = 1
+	exitError    = 2
+)
+
+// command is a lfinder subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// args lists the values accepted as positional arguments, for shell
+	// completion. Nil means file names.
+	args []string
+	// flags registers the options of the command on fs.
+	flags func(fs *flagSet)
+	// run executes the command once its flags are parsed and returns the
+	// process exit code. The positional arguments are fs.Args().
+	run func(fs *flagSet) int
 }
 
-func worker(id int, jobs <-chan string, results chan<- string, target string, targetInode uint64) {
-	for path := range jobs {
-		fileInfo, err := os.Lstat(path)
-		if err != nil {
-			continue // Skip on error
-		}
+// commands lists the subcommands in the order they appear in the help text.
+var commands []*command
 
-		if symlinksOnly && fileInfo.Mode()&os.ModeSymlink != 0 {
-			checkAndSendSymlink(path, target, results)
-		} else if hardlinksOnly && !fileInfo.IsDir() && fileInfo.Mode().IsRegular() {
-			checkAndSendHardlink(path, targetInode, fileInfo, results)
-		} else if !symlinksOnly && !hardlinksOnly {
-			if fileInfo.Mode()&os.ModeSymlink != 0 {
-				checkAndSendSymlink(path, target, results)
-			} else if fileInfo.Mode().IsRegular() {
-				checkAndSendHardlink(path, targetInode, fileInfo, results)
-			}
-		}
-	}
+func init() {
+	commands = []*command{findCmd, auditCmd, inventoryCmd, indexCmd, watchCmd, benchCmd, completionCmd}
 }
 
-// main is the entry point of the program.
-func main() {
-	flag.Parse()
-	args := flag.Args()
-	if len(args) != 1 {
-		fmt.Println("Usage: lfinder [-s|-h] [-p path] <target_file_name>")
-		os.Exit(1)
+// execute parses the options of cmd from args and runs it.
+func (cmd *command) execute(args []string) int {
+	fs := newFlagSet(cmd)
+	if cmd.flags != nil {
+		cmd.flags(fs)
 	}
-	target := args[0]
-
-	targetInfo, err := os.Stat(filepath.Join(searchPath, target))
-	if err != nil {
-		fmt.Printf("Error accessing target file: %v\n", err)
-		os.Exit(1)
+	if code, ok := fs.parse(args); !ok {
+		return code
 	}
-	targetInode := targetInfo.Sys().(*syscall.Stat_t).Ino
-
-	jobs := make(chan string, 100)
-	results := make(chan string, 100)
+	return cmd.run(fs)
+}
 
-	var wg sync.WaitGroup
-	const numWorkers = 8
+// usageError prints the usage line of cmd and returns the exit code for a
+// command line error.
+func (cmd *command) usageError() int {
+	fmt.Printf("Usage: %s\n", cmd.usage)
+	return exitError
+}
 
-	for w := 1; w <= numWorkers; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			worker(id, jobs, results, filepath.Join(searchPath, target), targetInode)
-		}(w)
+// lookup returns the subcommand called name, or nil.
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
 	}
+	return nil
+}
 
-	go func() {
-		filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			jobs <- path
-			return nil
-		})
-		close(jobs)
-	}()
-
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// usage prints the list of subcommands.
+func usage() {
+	fmt.Println("Usage: lfinder <command> [options] [arguments]")
+	fmt.Println("       lfinder [options] <target_file_name>...   (same as lfinder find)")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println()
+	fmt.Println("Run 'lfinder <command> --help' for the options of a command,")
+	fmt.Println("and 'lfinder --version' to print the version.")
+}
 
-	for result := range results {
-		fmt.Println(result)
+// main is the entry point of the program. It dispatches to the subcommand
+// named by the first argument; anything else is handled by find.
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-help", "--help":
+			usage()
+			return
+		case "version", "-version", "--version":
+			fmt.Println("lfinder", versionString())
+			return
+		}
+		if cmd := lookup(args[0]); cmd != nil {
+			os.Exit(cmd.execute(args[1:]))
+		}
 	}
+	os.Exit(findCmd.execute(args))
 }
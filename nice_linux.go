@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Scheduling classes, from linux/ioprio.h and linux/sched.h.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+	schedIdle        = 5
+)
+
+// lowerPriority puts the process in the idle I/O scheduling class, served
+// only when no other process needs the disk, and the SCHED_IDLE CPU
+// scheduling policy, run only when the CPU would be idle. Both are set per
+// thread: every thread of the process is changed, until none is left
+// unchanged, and the threads it starts later inherit them.
+func lowerPriority() error {
+	done := make(map[int]bool)
+	for {
+		entries, err := os.ReadDir("/proc/self/task")
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, e := range entries {
+			tid, err := strconv.Atoi(e.Name())
+			if err != nil || done[tid] {
+				continue
+			}
+			done[tid], changed = true, true
+			if err := setIdle(tid); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return err
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+}
+
+// setIdle puts the thread tid in the idle I/O class and SCHED_IDLE policy.
+func setIdle(tid int) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift); errno != 0 {
+		return errno
+	}
+	var param struct{ priority int32 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_SCHED_SETSCHEDULER, uintptr(tid), schedIdle, uintptr(unsafe.Pointer(&param))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// lowerPriority is not implemented on this platform: the search itself is
+// still made gentler by lfinder.Finder.Nice.
+func lowerPriority() error {
+	return nil
+}
@@ -0,0 +1,1303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+// stringList is a repeatable flag collecting every value it is given.
+// Values loaded from a configuration file are replaced, not extended, when
+// the flag is also given on the command line.
+type stringList struct {
+	values []string
+	reset  bool
+}
+
+func (l *stringList) String() string { return strings.Join(l.values, ",") }
+
+func (l *stringList) Set(value string) error {
+	if l.reset {
+		l.values, l.reset = nil, false
+	}
+	l.values = append(l.values, value)
+	return nil
+}
+
+// markDefault makes the next Set discard the values collected so far.
+func (l *stringList) markDefault() { l.reset = true }
+
+// patternList is a repeatable flag collecting patterns, compiled into
+// regular expressions by compile as they are given.
+type patternList struct {
+	stringList
+	compile  func(string) (*regexp.Regexp, error)
+	patterns []*regexp.Regexp
+}
+
+func (l *patternList) Set(value string) error {
+	re, err := l.compile(value)
+	if err != nil {
+		return err
+	}
+	if l.reset {
+		l.patterns = nil
+	}
+	l.patterns = append(l.patterns, re)
+	return l.stringList.Set(value)
+}
+
+// globList is a repeatable flag collecting comma-separated patterns, as
+// path.Match takes them.
+type globList struct {
+	stringList
+}
+
+func (l *globList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return l.stringList.Set(value)
+}
+
+// patterns returns the patterns given, split at commas.
+func (l *globList) patterns() []string {
+	var patterns []string
+	for _, value := range l.values {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+// fileTypes is a flag holding file type letters, as find -type takes them.
+type fileTypes []lfinder.FileType
+
+func (t *fileTypes) String() string {
+	b := make([]byte, len(*t))
+	for i, typ := range *t {
+		b[i] = byte(typ)
+	}
+	return string(b)
+}
+
+func (t *fileTypes) Set(value string) error {
+	types, err := lfinder.ParseFileTypes(value)
+	*t = types
+	return err
+}
+
+// byteSize is a flag holding a number of bytes, with an optional unit: k,
+// M, G, or T for powers of 1024, possibly followed by iB or B, as in 10M or
+// 1.5GiB.
+type byteSize int64
+
+func (b *byteSize) String() string { return strconv.FormatInt(int64(*b), 10) }
+
+func (b *byteSize) Set(value string) error {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(value), "b"), "i")
+	unit := 1.0
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("kmgt", num[n-1]); i >= 0 {
+			unit = float64(int64(1) << (10 * (i + 1)))
+			num = num[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q: want a number of bytes such as 512, 10k, or 1.5G", value)
+	}
+	*b = byteSize(n * unit)
+	return nil
+}
+
+// timeBound is a flag holding a point in time: a date, a date and time, or
+// an age such as 90m, 36h, 7d, or 2w, counted back from now.
+type timeBound struct{ time.Time }
+
+func (b *timeBound) String() string {
+	if b.IsZero() {
+		return ""
+	}
+	return b.Format(time.RFC3339)
+}
+
+func (b *timeBound) Set(value string) error {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			b.Time = t
+			return nil
+		}
+	}
+	age, err := parseAge(value)
+	if err != nil {
+		return fmt.Errorf("invalid time %q: want a date such as 2024-05-01, a date and time, or an age such as 7d", value)
+	}
+	b.Time = time.Now().Add(-age)
+	return nil
+}
+
+// parseAge parses a duration as time.ParseDuration does, also accepting
+// the units d for days and w for weeks, as in 7d or 2w.
+func parseAge(s string) (time.Duration, error) {
+	for unit, d := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if num, ok := strings.CutSuffix(s, unit); ok {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n * float64(d)), nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// idFilter is a repeatable flag selecting files by owner or by group: user
+// or group names or numeric IDs, comma-separated, prefixed by ! to select the
+// files with none of them instead. lookup returns the ID of a name.
+type idFilter struct {
+	lfinder.IDFilter
+	kind   string
+	lookup func(name string) (string, error)
+}
+
+func (f *idFilter) String() string {
+	ids := make([]string, len(f.IDs))
+	for i, id := range f.IDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (f *idFilter) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name, not := strings.CutPrefix(name, "!")
+		if len(f.IDs) > 0 && not != f.Not {
+			return fmt.Errorf("cannot both select and exclude %ss", f.kind)
+		}
+		f.Not = not
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			s, err := f.lookup(name)
+			if err != nil {
+				return fmt.Errorf("unknown %s %q", f.kind, name)
+			}
+			if id, err = strconv.ParseUint(s, 10, 32); err != nil {
+				return fmt.Errorf("%s %q has no numeric ID", f.kind, name)
+			}
+		}
+		f.IDs = append(f.IDs, uint32(id))
+	}
+	return nil
+}
+
+// permFilter is a flag selecting files by permissions, as find -perm does.
+type permFilter struct {
+	lfinder.PermFilter
+	value string
+}
+
+func (f *permFilter) String() string { return f.value }
+
+func (f *permFilter) Set(value string) error {
+	p, err := lfinder.ParsePerm(value)
+	if err != nil {
+		return err
+	}
+	f.PermFilter, f.value = p, value
+	return nil
+}
+
+// ioLimit is a flag bounding the I/O of the search, as ParseIOLimit takes
+// it.
+type ioLimit struct {
+	lfinder.IOLimit
+	value string
+}
+
+func (l *ioLimit) String() string { return l.value }
+
+func (l *ioLimit) Set(value string) error {
+	limit, err := lfinder.ParseIOLimit(value)
+	if err != nil {
+		return err
+	}
+	l.IOLimit, l.value = limit, value
+	return nil
+}
+
+// lookupUser returns the user ID of the user name.
+func lookupUser(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+// lookupGroup returns the group ID of the group name.
+func lookupGroup(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
+
+// searchFlags holds the options of every command that walks a tree.
+// paths lists the paths to be searched for symlinks or hardlinks, and
+// exactRoots, set by commands rather than flags, replaces them with paths
+// taken as they are, commas included.
+// skipDirs lists directories whose subtrees are not searched, as paths or
+// globs, and noDefaultSkips drops defaultSkipDirs from them.
+// workers is the number of goroutines examining files, and adaptiveWorkers
+// resizes their pool during the search.
+// engine selects how directories are read.
+// queueSize is the number of candidates and results queued between the
+// walk, the workers, and the output, and maxMemory the heap size above
+// which the walk is held.
+// ioLimit bounds the rate of the I/O operations and how many run at once.
+// nice runs the search in the background, at idle priority.
+// timeout bounds the duration of the search, and dirTimeout that of each
+// directory subtree.
+// maxResults stops the search after that many results, one with --first.
+// skipLoops passes over symlinks caught in a loop.
+// linkType restricts the results to absolute or relative symlinks.
+// maxChain bounds the links followed to show the chain of a symlink.
+// follow selects the symlinks followed, as with find -P, -H, and -L.
+// root is the directory absolute symlinks are resolved from, as in a chroot.
+// oneFileSystem keeps the search on the file system of each search path.
+// types restricts the candidates examined to some file types.
+// include and exclude select the candidates examined by path glob, and
+// includeRE and excludeRE by regular expression.
+// minDepth and maxDepth bound the depth of the candidates below their root.
+// minSize and maxSize bound the size of the candidates.
+// newerThan and olderThan bound their modification time.
+// owner and group select them by owner and group, and perm and dirPerm by
+// their permissions and those of their directory.
+// minNlink selects them by number of hard links.
+// gitignore passes over the paths ignored by .gitignore and .ignore files.
+// noHidden passes over dotfiles and dot-directories.
+// excludeCaches passes over the directories tagged as caches, and
+// excludeIfPresent those holding one of some marker files.
+// skipNetwork does not descend into network file systems, and
+// excludeFSTypes and includeFSTypes into file systems of some types.
+type searchFlags struct {
+	paths            stringList
+	exactRoots       []string
+	skipDirs         stringList
+	noDefaultSkips   bool
+	workers          int
+	adaptiveWorkers  bool
+	engine           string
+	queueSize        int
+	maxMemory        byteSize
+	ioLimit          ioLimit
+	nice             bool
+	timeout          time.Duration
+	dirTimeout       time.Duration
+	maxResults       int
+	skipLoops        bool
+	linkType         lfinder.LinkType
+	maxChain         int
+	follow           lfinder.FollowMode
+	root             string
+	oneFileSystem    bool
+	types            fileTypes
+	include          patternList
+	exclude          patternList
+	includeRE        patternList
+	excludeRE        patternList
+	minDepth         int
+	maxDepth         int
+	minSize          byteSize
+	maxSize          byteSize
+	newerThan        timeBound
+	olderThan        timeBound
+	owner            idFilter
+	group            idFilter
+	perm             permFilter
+	dirPerm          permFilter
+	minNlink         int
+	gitignore        bool
+	noHidden         bool
+	excludeCaches    bool
+	excludeIfPresent stringList
+	skipNetwork      bool
+	excludeFSTypes   globList
+	includeFSTypes   globList
+}
+
+// register sets up the command line options for specifying the search paths,
+// pruning directories, selecting the candidates examined, sizing the worker
+// pool, and bounding the search time and the number of results, for
+// selecting absolute or relative symlinks, for following symlinks, and for
+// resolving them inside an image root.
+// Usage:
+//
+//	-p, --path               Path to start the search from (repeatable)
+//	    --skip-dir           Directory not to descend into (repeatable)
+//	    --no-default-skips   Also descend into /proc, /sys, and /dev
+//	-x, --one-file-system    Stay on the file system of each search path
+//	    --skip-network       Skip network file systems
+//	    --exclude-fstype     Skip file systems of some types (repeatable)
+//	    --include-fstype     Search only file systems of some types (repeatable)
+//	    --types              File types of the candidates
+//	    --include            Examine only paths matching a glob (repeatable)
+//	    --exclude            Pass over paths matching a glob (repeatable)
+//	    --include-re         Examine only paths matching a regexp (repeatable)
+//	    --exclude-re         Pass over paths matching a regexp (repeatable)
+//	    --respect-gitignore  Skip paths ignored by .gitignore files
+//	    --no-hidden          Skip dotfiles and dot-directories
+//	    --exclude-caches     Skip directories tagged with CACHEDIR.TAG
+//	    --exclude-if-present Skip directories holding a marker file (repeatable)
+//	    --max-depth          Deepest level to descend to
+//	    --min-depth          Shallowest level to examine
+//	    --min-size           Smallest file size to examine
+//	    --max-size           Largest file size to examine
+//	    --newer-than         Examine only files modified since a time
+//	    --older-than         Examine only files modified before a time
+//	    --owner              Examine only files of some owners (repeatable)
+//	    --group              Examine only files of some groups (repeatable)
+//	    --perm               Examine only files with some permissions
+//	    --dir-perm           Examine only files in directories with some permissions
+//	    --min-nlink          Fewest hard links of the files to examine
+//	-w, --workers            Number of worker goroutines
+//	    --adaptive-workers   Resize the worker pool to the storage
+//	    --engine             How directories are read
+//	    --queue-size         Candidates and results queued
+//	    --max-memory         Heap size above which the walk is held
+//	    --io-limit           I/O operations per second and in flight
+//	    --nice               Search in the background
+//	-t, --timeout            Maximum duration of the search
+//	    --dir-timeout        Maximum duration of each directory subtree
+//	-m, --max-results        Maximum number of results
+//	-1, --first              Stop at the first result
+//	    --skip-loops         Pass over symlinks caught in a loop
+//	    --only-absolute      Absolute symlinks only
+//	    --only-relative      Relative symlinks only
+//	    --max-chain          Maximum links shown in a symlink chain
+//	-P, --no-follow          Follow no symlinks
+//	-H, --follow-args        Follow symlinks given as arguments
+//	-L, --follow             Follow every symlink
+//	    --root               Resolve absolute symlinks from a directory
+func (o *searchFlags) register(fs *flagSet) {
+	fs.Group("Search options")
+	fs.Var(&o.paths, "path", "p", "`Path` to start the search from (repeatable or comma-separated; default /)")
+	fs.Var(&o.skipDirs, "skip-dir", "", "Do not descend into `directory`, or into the directories matching a glob, e.g. '/var/lib/docker/*' (repeatable; /proc, /sys, and /dev are skipped by default)")
+	fs.BoolVar(&o.noDefaultSkips, "no-default-skips", "", false, "Also descend into /proc, /sys, and /dev, e.g. when searching an image mounted as the root")
+	fs.BoolVar(&o.oneFileSystem, "one-file-system", "x", false, "Do not descend into directories on another file system than their search path, such as network, FUSE, or bind mounts")
+	fs.BoolVar(&o.skipNetwork, "skip-network", "", false, "Do not descend into network file systems, such as NFS, CIFS, or SSHFS, listing them in the summary (Linux; the search paths themselves are searched)")
+	fs.Var(&o.excludeFSTypes, "exclude-fstype", "", "Do not descend into file systems whose type matches one of `types`, comma-separated globs such as proc,sysfs,tmpfs,'fuse.*' (Linux; repeatable)")
+	fs.Var(&o.includeFSTypes, "include-fstype", "", "Descend only into file systems whose type matches one of `types`, given as for --exclude-fstype, besides those of the search paths (Linux; repeatable)")
+	fs.Var(&o.types, "types", "", "Examine only the files of these `types`: letters as for find -type, f (regular file), l (symlink), p (named pipe), s (socket), b (block device), c (character device), or d (directory), e.g. f,p")
+	o.include.compile, o.exclude.compile = lfinder.CompileGlob, lfinder.CompileGlob
+	fs.Var(&o.include, "include", "", "Examine only the files whose path matches `glob` (* also matches /), e.g. '*.so*' (repeatable)")
+	fs.Var(&o.exclude, "exclude", "", "Pass over the paths matching `glob` (* also matches /), not descending into the directories it matches with a trailing /, e.g. '*/node_modules/*' (repeatable)")
+	o.includeRE.compile, o.excludeRE.compile = regexp.Compile, regexp.Compile
+	fs.Var(&o.includeRE, "include-re", "", "Examine only the files whose path matches the regular expression `re`, e.g. '\\.so(\\.[0-9]+)*$' (repeatable)")
+	fs.Var(&o.excludeRE, "exclude-re", "", "Pass over the paths matching the regular expression `re`, as --exclude does (repeatable)")
+	fs.BoolVar(&o.gitignore, "respect-gitignore", "", false, "Pass over the paths ignored by the .gitignore and .ignore files met during the search, as git, ripgrep, and fd do")
+	fs.BoolVar(&o.noHidden, "no-hidden", "", false, "Pass over the files and directories whose name starts with a dot, not descending into them, as fd does (the search paths themselves are searched)")
+	fs.BoolVar(&o.excludeCaches, "exclude-caches", "", false, "Do not descend into cache directories, tagged with a CACHEDIR.TAG file as tar and Borg honor")
+	fs.Var(&o.excludeIfPresent, "exclude-if-present", "", "Do not descend into the directories holding a file named `name`, e.g. .nolfinder (repeatable)")
+	fs.IntVar(&o.maxDepth, "max-depth", "", 0, "Descend at most `N` levels below each search path, whose entries are at level 1 (0 means no limit)")
+	fs.IntVar(&o.minDepth, "min-depth", "", 0, "Examine only the files at least `N` levels below their search path")
+	fs.Var(&o.minSize, "min-size", "", "Examine only the files of at least `size` bytes, e.g. 10k or 1.5G (symlinks and directories are not filtered)")
+	fs.Var(&o.maxSize, "max-size", "", "Examine only the files of at most `size` bytes (0 means no limit)")
+	fs.Var(&o.newerThan, "newer-than", "", "Examine only the files modified after `time`: a date such as 2024-05-01, a date and time, or an age such as 36h, 7d, or 2w (a symlink's time is when it was created)")
+	fs.Var(&o.olderThan, "older-than", "", "Examine only the files modified before `time`, given as for --newer-than")
+	o.owner.kind, o.owner.lookup = "user", lookupUser
+	o.group.kind, o.group.lookup = "group", lookupGroup
+	fs.Var(&o.owner, "owner", "", "Examine only the files owned by `users`, given by name or ID, comma-separated, or with ! by none of them, e.g. '!root' (repeatable)")
+	fs.Var(&o.group, "group", "", "Examine only the files whose group is one of `groups`, given as for --owner (repeatable)")
+	fs.Var(&o.perm, "perm", "", "Examine only the files whose permissions are `mode`, octal or symbolic, or with -mode have all of its bits set, or with /mode any of them, as for find -perm, e.g. -4000 or /o+w")
+	fs.Var(&o.dirPerm, "dir-perm", "", "Examine only the files in a directory whose permissions are `mode`, given as for --perm, e.g. -o+w for world-writable directories")
+	fs.IntVar(&o.minNlink, "min-nlink", "", 0, "Examine only the files with at least `N` hard links, e.g. with inventory to list the most linked files of a backup tree")
+	fs.IntVar(&o.workers, "workers", "w", 0, "Number of `goroutines` examining files, per device searched, and of goroutines reading directories (0 picks a number from the CPUs and the storage searched: more for network file systems, fewer for spinning disks)")
+	fs.BoolVar(&o.adaptiveWorkers, "adaptive-workers", "", false, "Grow the pool of goroutines examining files while storage latency shows they wait on it and more of them get more done, and shrink it back once files are served from the cache")
+	fs.StringVar(&o.engine, "engine", "", "portable", "Read directories with `engine` portable, fast to read them with getdents64 into reusable buffers, unsorted, or uring to also stat their entries in batches through io_uring, for NFS and CephFS (Linux, with fallback to fast; portable elsewhere and with --root)")
+	fs.Choices("engine", "portable", "fast", "uring")
+	fs.IntVar(&o.queueSize, "queue-size", "", 0, "Queue at most `N` files for each pool of goroutines examining them, and N results for the output, before waiting, so that slow output holds up the search (0 means 100)")
+	fs.Var(&o.maxMemory, "max-memory", "", "Hold the walk while the heap exceeds `size` bytes, e.g. 512M, until the files and results queued are worked through; --sort keeps every result regardless (0 means no limit)")
+	fs.Var(&o.ioLimit, "io-limit", "", "Bound the I/O of the search to `spec`: directory reads and file examinations per second, with burst=N to allow N at once after a lull (default one second's worth), and inflight=N to run at most N at once, comma-separated, e.g. 200 or 500,burst=2000,inflight=4 (uring reads directories as fast does)")
+	fs.BoolVar(&o.nice, "nice", "", false, "Search in the background, easy on latency-sensitive services: with 2 goroutines unless --workers is set, and on Linux at idle I/O and CPU priority, pausing while the I/O pressure of the system is high (uring reads directories as fast does)")
+	fs.DurationVar(&o.timeout, "timeout", "t", 0, "Stop the search after `duration` (0 means no limit)")
+	fs.DurationVar(&o.dirTimeout, "dir-timeout", "", 0, "Abandon the subtree of a directory below a search path after `duration` and go on with the rest of the search, e.g. to get past a hung automount (0 means no limit)")
+	fs.IntVar(&o.maxResults, "max-results", "m", 0, "Stop the search after `N` results (0 means no limit)")
+	fs.Var(choiceFlag[int]{&o.maxResults, 1}, "first", "1", "Stop the search at the first result, as --max-results 1 does, e.g. to tell whether anything still links to a file before removing it")
+	fs.BoolVar(&o.skipLoops, "skip-loops", "", false, "Pass over symlinks caught in a loop (a -> b -> a) instead of reporting them")
+	fs.Var(choiceFlag[lfinder.LinkType]{&o.linkType, lfinder.LinkAbsolute}, "only-absolute", "", "Report only symlinks storing an absolute path, which break when their destination tree is moved")
+	fs.Var(choiceFlag[lfinder.LinkType]{&o.linkType, lfinder.LinkRelative}, "only-relative", "", "Report only symlinks storing a relative path")
+	fs.Var(choiceFlag[lfinder.FollowMode]{&o.follow, lfinder.FollowNever}, "no-follow", "P", "Follow no symlinks (the default): a target or search path that is a symlink stands for the link itself")
+	fs.Var(choiceFlag[lfinder.FollowMode]{&o.follow, lfinder.FollowRoots}, "follow-args", "H", "Follow the targets and search paths that are symlinks, but no symlink met during the search")
+	fs.Var(choiceFlag[lfinder.FollowMode]{&o.follow, lfinder.FollowAlways}, "follow", "L", "Follow every symlink, also descending into the directories that symlinks met during the search point to")
+	fs.IntVar(&o.maxChain, "max-chain", "", 0, "Follow at most `N` links when showing the chain of a symlink to a symlink (0 means no limit)")
+	fs.StringVar(&o.root, "root", "", "", "Resolve absolute symlinks from `directory`, as in a chroot, when searching an extracted image or a mounted file system (directory is searched if --path is not given)")
+}
+
+// roots returns the search paths set by the command in exactRoots, or those
+// given with --path, or if none were, the --root directory or "/". Each
+// value of --path may hold several paths separated by commas.
+func (o *searchFlags) roots() []string {
+	if len(o.exactRoots) > 0 {
+		return o.exactRoots
+	}
+	var roots []string
+	for _, value := range o.paths.values {
+		for _, path := range strings.Split(value, ",") {
+			if path != "" {
+				roots = append(roots, filepath.Clean(path))
+			}
+		}
+	}
+	if len(roots) == 0 && o.root != "" {
+		return []string{filepath.Clean(o.root)}
+	}
+	if len(roots) == 0 {
+		return []string{"/"}
+	}
+	return roots
+}
+
+// fs returns the file system links are resolved on: the host seen from the
+// --root directory if given, or nil for the host itself.
+func (o *searchFlags) fs() lfinder.FS {
+	if o.root == "" {
+		return nil
+	}
+	root, err := lfinder.OSFS{}.EvalSymlinks(o.root)
+	if err != nil {
+		// The search reports the directory as inaccessible.
+		root, _ = filepath.Abs(o.root)
+	}
+	return lfinder.ChrootFS{Root: root}
+}
+
+// searchPath returns the first search path, which target file names are
+// relative to.
+func (o *searchFlags) searchPath() string {
+	return o.roots()[0]
+}
+
+// setupProcess applies the settings of the flags that concern the whole
+// process rather than a Finder: with --max-memory, the memory limit of the
+// runtime, so that the collector works harder as the heap nears the budget,
+// before the walk is held; with --nice, the idle scheduling priority of
+// every thread. Commands call it once, before searching.
+func (o *searchFlags) setupProcess() {
+	if o.maxMemory > 0 {
+		debug.SetMemoryLimit(int64(o.maxMemory))
+	}
+	if o.nice {
+		if err := lowerPriority(); err != nil {
+			warnf("cannot lower the scheduling priority: %v", err)
+		}
+	}
+}
+
+// options returns the Finder options selected by the flags.
+func (o *searchFlags) options() []lfinder.Option {
+	skipDirs := make([]string, len(o.skipDirs.values))
+	for i, dir := range o.skipDirs.values {
+		skipDirs[i] = filepath.Clean(dir)
+	}
+	if !o.noDefaultSkips {
+		skipDirs = append(skipDirs, o.defaultSkips()...)
+	}
+	opts := []lfinder.Option{
+		lfinder.WithRoots(o.roots()...),
+		lfinder.WithSkipDirs(skipDirs...),
+		lfinder.WithWorkers(o.workers),
+		lfinder.WithMaxResults(o.maxResults),
+		lfinder.WithMaxChain(o.maxChain),
+		lfinder.WithFollow(o.follow),
+		lfinder.WithLogger(logger),
+	}
+	if fsys := o.fs(); fsys != nil {
+		opts = append(opts, lfinder.WithFS(fsys))
+	}
+	if o.skipLoops {
+		opts = append(opts, lfinder.WithSkipLoops())
+	}
+	if o.oneFileSystem {
+		opts = append(opts, lfinder.WithOneFileSystem())
+	}
+	if len(o.types) > 0 {
+		opts = append(opts, lfinder.WithTypes(o.types...))
+	}
+	if o.minDepth > 0 || o.maxDepth > 0 {
+		opts = append(opts, lfinder.WithDepth(o.minDepth, o.maxDepth))
+	}
+	if o.minSize > 0 || o.maxSize > 0 {
+		opts = append(opts, lfinder.WithSize(int64(o.minSize), int64(o.maxSize)))
+	}
+	if !o.newerThan.IsZero() || !o.olderThan.IsZero() {
+		opts = append(opts, lfinder.WithModTime(o.newerThan.Time, o.olderThan.Time))
+	}
+	if o.perm.How != lfinder.PermNone || o.dirPerm.How != lfinder.PermNone {
+		opts = append(opts, lfinder.WithPerm(o.perm.PermFilter, o.dirPerm.PermFilter))
+	}
+	if o.dirTimeout > 0 {
+		opts = append(opts, lfinder.WithDirTimeout(o.dirTimeout))
+	}
+	if o.minNlink > 1 {
+		opts = append(opts, lfinder.WithMinNlink(uint64(o.minNlink)))
+	}
+	if o.gitignore {
+		opts = append(opts, lfinder.WithGitignore())
+	}
+	if o.noHidden {
+		opts = append(opts, lfinder.WithSkipHidden())
+	}
+	if o.adaptiveWorkers {
+		opts = append(opts, lfinder.WithAdaptiveWorkers())
+	}
+	if o.engine != "portable" {
+		opts = append(opts, lfinder.WithEngine(lfinder.Engine(o.engine)))
+	}
+	if o.queueSize > 0 {
+		opts = append(opts, lfinder.WithQueueSize(o.queueSize))
+	}
+	if o.maxMemory > 0 {
+		opts = append(opts, lfinder.WithMaxMemory(int64(o.maxMemory)))
+	}
+	if o.ioLimit.value != "" {
+		opts = append(opts, lfinder.WithIOLimit(o.ioLimit.IOLimit))
+	}
+	if o.nice {
+		opts = append(opts, lfinder.WithNice())
+	}
+	if o.excludeCaches {
+		opts = append(opts, lfinder.WithMarkers(lfinder.CacheDirTag))
+	}
+	if len(o.excludeIfPresent.values) > 0 {
+		opts = append(opts, lfinder.WithMarkers(o.excludeIfPresent.values...))
+	}
+	if len(o.owner.IDs) > 0 || len(o.group.IDs) > 0 {
+		opts = append(opts, lfinder.WithOwner(o.owner.IDFilter, o.group.IDFilter))
+	}
+	if include := slices.Concat(o.include.patterns, o.includeRE.patterns); len(include) > 0 {
+		opts = append(opts, lfinder.WithInclude(include...))
+	}
+	if exclude := slices.Concat(o.exclude.patterns, o.excludeRE.patterns); len(exclude) > 0 {
+		opts = append(opts, lfinder.WithExclude(exclude...))
+	}
+	if firmlinks, err := lfinder.Firmlinks(); err == nil {
+		opts = append(opts, lfinder.WithFirmlinks(firmlinks...))
+	}
+	// The mount table describes the host, not an image searched with --root.
+	mounts, err := lfinder.Mounts()
+	if err == nil && o.root == "" {
+		opts = append(opts, lfinder.WithMounts(mounts...))
+	}
+	if o.skipNetwork {
+		if err != nil {
+			warnf("--skip-network: cannot read the mount table: %v", err)
+		}
+		opts = append(opts, lfinder.WithSkipNetwork())
+	}
+	if include, exclude := o.includeFSTypes.patterns(), o.excludeFSTypes.patterns(); len(include) > 0 || len(exclude) > 0 {
+		if err != nil {
+			warnf("--include-fstype, --exclude-fstype: cannot read the mount table: %v", err)
+		}
+		opts = append(opts, lfinder.WithFSTypes(include, exclude))
+	}
+	if o.linkType != "" {
+		opts = append(opts, lfinder.WithLinkType(o.linkType))
+	}
+	return opts
+}
+
+// defaultSkipDirs are the pseudo file systems of Unix hosts, holding no
+// links worth finding but many entries that are slow or unsafe to examine.
+var defaultSkipDirs = []string{"/proc", "/sys", "/dev"}
+
+// defaultSkips returns the defaultSkipDirs that are not search paths and
+// contain none, on the platforms having them.
+func (o *searchFlags) defaultSkips() []string {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var skips []string
+	for _, dir := range defaultSkipDirs {
+		if !slices.ContainsFunc(o.roots(), func(root string) bool { return within(root, dir) }) {
+			skips = append(skips, dir)
+		}
+	}
+	return skips
+}
+
+// context returns a context bounded by the --timeout flag, if set.
+func (o *searchFlags) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(parent, o.timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// findFlags holds the options shared by the commands that search for links to a target.
+// symlinksOnly indicates whether only symbolic links should be considered.
+// hardlinksOnly indicates whether only hard links should be considered.
+// targetsFrom names a file listing more targets, "-" for standard input.
+// null makes the target list NUL-separated.
+// globs lists patterns whose matches are targets too.
+// into also finds symlinks to paths below a target directory.
+// caseInsensitive compares paths regardless of case.
+// normalize is the Unicode normalization form paths are compared in.
+// inode and device identify a file by number instead of by path.
+// targetPattern and targetRegex select symlinks by their stored destination.
+// reflinks also finds copy-on-write clones of the targets.
+// sameContent also finds copies of the targets; verify byte-compares them.
+// shortcuts also finds Windows shell links to the targets.
+// aliases also finds macOS Finder aliases of the targets.
+type findFlags struct {
+	searchFlags
+	symlinksOnly    bool
+	hardlinksOnly   bool
+	into            bool
+	caseInsensitive bool
+	normalize       string
+	targetsFrom     string
+	null            bool
+	globs           stringList
+	inode           uint64
+	device          string
+	targetPattern   string
+	targetRegex     string
+	reflinks        bool
+	sameContent     bool
+	verify          bool
+	shortcuts       bool
+	aliases         bool
+}
+
+// register sets up the search options plus the command line options for
+// finding symlinks only, finding hardlinks only, and reading targets from a file.
+// Usage:
+//
+//	-s, --symlinks         Find symlinks only
+//	-h, --hardlinks        Find hardlinks only
+//	    --into             Find symlinks into a target directory
+//	    --case-insensitive Compare paths regardless of case
+//	    --normalize        Unicode normalization of paths
+//	-T, --targets-from     File listing target files
+//	-0, --null             Target list is NUL-separated
+//	-g, --glob             Pattern matching target files
+//	    --reflinks         Find clones of the targets
+//	    --same-content     Find copies of the targets
+//	    --verify           Byte-compare copies
+//	    --shortcuts        Find Windows shortcuts to the targets
+//	    --aliases          Find Finder aliases of the targets
+//	    --inode            Find the paths to an inode
+//	    --device           Device of the inode
+//	    --target-pattern   Find symlinks by destination glob
+//	    --target-regex     Find symlinks by destination regexp
+func (o *findFlags) register(fs *flagSet) {
+	o.searchFlags.register(fs)
+	fs.BoolVar(&o.symlinksOnly, "symlinks", "s", false, "Find symlinks only")
+	fs.BoolVar(&o.hardlinksOnly, "hardlinks", "h", false, "Find hardlinks only")
+	fs.BoolVar(&o.into, "into", "", false, "Also find symlinks to any path below a target directory")
+	fs.BoolVar(&o.caseInsensitive, "case-insensitive", "", false, "Compare the paths of links and targets regardless of case (the default when a target lies on a file system that ignores case, as on macOS and Windows)")
+	fs.StringVar(&o.normalize, "normalize", "", "nfc", "Compare the paths of links and targets in Unicode normalization `form` nfc or nfd, so that names stored decomposed (as on HFS+) match names typed precomposed, or none to compare them byte by byte")
+	fs.Choices("normalize", "nfc", "nfd", "none")
+	fs.StringVar(&o.targetsFrom, "targets-from", "T", "", "Read target files from `file`, one per line or NUL-separated (- for standard input)")
+	fs.Var(&o.globs, "glob", "g", "Also take the files matching `pattern` as targets, e.g. 'libssl.so*' (repeatable; relative to the first search path unless absolute)")
+	fs.BoolVar(&o.null, "null", "0", false, "Target lists are separated by NUL bytes only, as written by find -print0 or --print0")
+	fs.BoolVar(&o.reflinks, "reflinks", "", false, "Also find copy-on-write clones of the targets, i.e. files sharing storage with one, as made by cp --reflink on Linux or cp -c on macOS")
+	fs.BoolVar(&o.sameContent, "same-content", "", false, "Also find files whose contents are identical to a target's, reported as duplicates")
+	fs.BoolVar(&o.verify, "verify", "", false, "Compare the files found by --same-content byte by byte, not only by SHA-256 digest")
+	fs.BoolVar(&o.shortcuts, "shortcuts", "", false, "Also find Windows shortcuts (.lnk files) leading to a target, reported as shortcuts")
+	fs.BoolVar(&o.aliases, "aliases", "", false, "Also find macOS Finder aliases of a target, reported as aliases")
+	fs.Uint64Var(&o.inode, "inode", "", 0, "Find the paths to the file with inode number `n` instead of the links to target files")
+	fs.StringVar(&o.targetPattern, "target-pattern", "", "", "Find the symlinks whose stored destination matches `glob` (* also matches /), e.g. '*/python2*', instead of the links to target files")
+	fs.StringVar(&o.targetRegex, "target-regex", "", "", "Find the symlinks whose stored destination matches the regular expression `re` instead of the links to target files")
+	fs.StringVar(&o.device, "device", "", "", "Device holding the --inode: a `number`, a major,minor pair, or a device or file path (default the device of the first search path)")
+}
+
+// needsTargets reports whether the search is for the links to target files,
+// rather than for an inode or for symlinks by destination pattern.
+func (o *findFlags) needsTargets() bool {
+	return o.inode == 0 && o.targetPattern == "" && o.targetRegex == ""
+}
+
+// results starts the search for the paths to the file given by --inode, for
+// the symlinks matching --target-pattern or --target-regex, or else for the
+// links to targets.
+func (o *findFlags) results(ctx context.Context, finder *lfinder.Finder, targets []string) (iter.Seq[lfinder.Result], error) {
+	if o.needsTargets() {
+		return finder.Results(ctx, targets...)
+	}
+	if len(targets) > 0 {
+		return nil, errors.New("--inode, --target-pattern, and --target-regex cannot be combined with target files")
+	}
+	m, err := o.matcher()
+	if err != nil {
+		return nil, err
+	}
+	return finder.Scan(ctx, m), nil
+}
+
+// matcher returns the matcher for the search selected by --inode,
+// --target-pattern, or --target-regex.
+func (o *findFlags) matcher() (lfinder.Matcher, error) {
+	switch {
+	case o.inode != 0 && (o.targetPattern != "" || o.targetRegex != ""), o.targetPattern != "" && o.targetRegex != "":
+		return nil, errors.New("only one of --inode, --target-pattern, and --target-regex can be used")
+	case o.targetPattern != "":
+		re, err := lfinder.CompileGlob(o.targetPattern)
+		if err != nil {
+			return nil, fmt.Errorf("--target-pattern: %v", err)
+		}
+		return lfinder.LinkPatternMatcher{FS: o.fs(), Pattern: re}, nil
+	case o.targetRegex != "":
+		re, err := regexp.Compile(o.targetRegex)
+		if err != nil {
+			return nil, fmt.Errorf("--target-regex: %v", err)
+		}
+		return lfinder.LinkPatternMatcher{FS: o.fs(), Pattern: re}, nil
+	}
+	dev, err := o.deviceNumber()
+	if err != nil {
+		return nil, err
+	}
+	return lfinder.InodeMatcher{Device: dev, Inode: o.inode}, nil
+}
+
+// deviceNumber returns the device given by --device: a number such as 2049
+// or 0x801, a major and minor number pair such as 8,1 (as printed by lsof)
+// or 8:1, or the path of a block device or of any file on the file system.
+// It defaults to the device of the first search path.
+func (o *findFlags) deviceNumber() (uint64, error) {
+	value := o.device
+	if value == "" {
+		value = o.searchPath()
+	}
+	if major, minor, ok := strings.Cut(strings.ReplaceAll(value, ":", ","), ","); ok {
+		maj, err1 := strconv.ParseUint(major, 0, 32)
+		min, err2 := strconv.ParseUint(minor, 0, 32)
+		if err1 == nil && err2 == nil {
+			if dev, ok := makeDevice(maj, min); ok {
+				return dev, nil
+			}
+		}
+	} else if dev, err := strconv.ParseUint(value, 0, 64); err == nil {
+		return dev, nil
+	}
+	if dev, ok := deviceNumber(value); ok {
+		return dev, nil
+	}
+	return 0, fmt.Errorf("--device: cannot determine the device of %s", value)
+}
+
+// finder returns a Finder configured from the flags.
+func (o *findFlags) finder() *lfinder.Finder {
+	opts := o.options()
+	if o.symlinksOnly {
+		opts = append(opts, lfinder.WithSymlinksOnly())
+	}
+	if o.hardlinksOnly {
+		opts = append(opts, lfinder.WithHardlinksOnly())
+	}
+	if o.into {
+		opts = append(opts, lfinder.WithInto())
+	}
+	if o.caseInsensitive {
+		opts = append(opts, lfinder.WithCaseInsensitive())
+	}
+	if o.normalize != "none" {
+		opts = append(opts, lfinder.WithNormalization(lfinder.Normalization(o.normalize)))
+	}
+	if o.reflinks {
+		opts = append(opts, lfinder.WithReflinks())
+	}
+	if o.sameContent {
+		opts = append(opts, lfinder.WithSameContent())
+	}
+	if o.verify {
+		opts = append(opts, lfinder.WithVerifyContent())
+	}
+	if o.shortcuts {
+		opts = append(opts, lfinder.WithShortcuts())
+	}
+	if o.aliases {
+		opts = append(opts, lfinder.WithAliases())
+	}
+	return lfinder.NewFinder(opts...)
+}
+
+// targets returns the paths of the target files named on the command line,
+// in the --targets-from file, and matching the --glob patterns. Relative
+// names and patterns are relative to the first search path; absolute ones
+// are taken as they are. A "-" argument reads more names from standard
+// input.
+func (o *findFlags) targets(args []string) ([]string, error) {
+	var names []string
+	readStdin := o.targetsFrom == "-"
+	for _, arg := range args {
+		if arg == "-" {
+			readStdin = true
+			continue
+		}
+		names = append(names, arg)
+	}
+	if o.targetsFrom != "" && o.targetsFrom != "-" {
+		file, err := os.Open(o.targetsFrom)
+		if err != nil {
+			return nil, err
+		}
+		list, err := readTargets(file, o.null)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", o.targetsFrom, err)
+		}
+		names = append(names, list...)
+	}
+	if readStdin {
+		list, err := readTargets(os.Stdin, o.null)
+		if err != nil {
+			return nil, fmt.Errorf("reading standard input: %v", err)
+		}
+		names = append(names, list...)
+	}
+
+	targets := make([]string, len(names))
+	for i, name := range names {
+		targets[i] = o.targetPath(name)
+	}
+	for _, pattern := range o.globs.values {
+		matches, err := filepath.Glob(o.targetPath(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("--glob %s: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("--glob %s: no file matches", pattern)
+		}
+		targets = append(targets, matches...)
+	}
+	return targets, nil
+}
+
+// targetPath returns the path of the target named name: name itself if it
+// is absolute, and name below the first search path otherwise.
+func (o *findFlags) targetPath(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(o.searchPath(), name)
+}
+
+// readTargets returns the names listed in r. Names are separated by NUL
+// bytes if null is set or r contains any, as written by find -print0, and by
+// newlines otherwise. Empty names are skipped.
+func readTargets(r io.Reader, null bool) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	null = null || strings.Contains(string(data), "\x00")
+	sep := "\n"
+	if null {
+		sep = "\x00"
+	}
+	var names []string
+	for _, name := range strings.Split(string(data), sep) {
+		if !null {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// outputFlags holds the options controlling how results are written.
+// format selects the output format.
+// template formats each result with a text/template instead.
+// print0 writes only the paths, each followed by a NUL byte.
+// sortBy orders the results, once all of them are known.
+// summary adds statistics about the search after the results.
+// quiet suppresses the output and stops the search at the first result.
+// tui shows the results in an interactive table instead.
+// showTarget makes the text format name the target of each result,
+// inventory groups the results in the text and JSON formats, and scan
+// describes the search in the JSON format; they are set by the commands
+// rather than by flags.
+type outputFlags struct {
+	format     string
+	output     string
+	file       *atomicFile
+	template   string
+	print0     bool
+	long       bool
+	absolute   bool
+	relative   bool
+	relativeTo string
+	sortBy     string
+	summary    bool
+	errors     string
+	noProgress bool
+	quiet      bool
+	tui        bool
+	showTarget bool
+	inventory  bool
+	scan       *lfinder.ScanMetadata
+}
+
+// register sets up the command line options for selecting the output format
+// for checking whether anything matches without printing it, and for
+// browsing the results interactively.
+// Usage:
+//
+//	-f, --format            Output format: text, json, ndjson, csv, tsv, dot, html, or junit
+//	-o, --output            Write the results to a file instead of stdout
+//	    --format-template   Go template for each result
+//	    --print0            NUL-separated paths only
+//	-l, --long              Permissions, owner, size, etc. of each link
+//	    --absolute          Print absolute paths
+//	    --relative          Print paths relative to the search path
+//	    --relative-to       Print paths relative to a directory
+//	    --sort              Sort the results by path, mtime, or size
+//	    --summary           Statistics about the search
+//	    --errors            How errors are reported on stderr: text or json
+//	    --no-progress       No progress line on stderr
+//	-q, --quiet             Print nothing, exit at the first match
+//	    --tui               Interactive table of the results
+//	    --color             When to color the output
+func (o *outputFlags) register(fs *flagSet) {
+	fs.Group("Output options")
+	fs.StringVar(&o.format, "format", "f", "text", "Output `format`: text, json, ndjson, csv, tsv, dot (Graphviz), html (standalone report), or junit (CI test report)")
+	fs.Choices("format", "text", "json", "ndjson", "csv", "tsv", "dot", "html", "junit")
+	fs.StringVar(&o.output, "output", "o", "", "Write the results to `file` instead of standard output; it is replaced only once the search has completed")
+	fs.StringVar(&o.template, "format-template", "", "", "Print each result with a Go `template` over the result fields, e.g. '{{.Path}}\\t{{.Inode}}'")
+	fs.BoolVar(&o.print0, "print0", "", false, "Print only the paths, each followed by a NUL byte, for xargs -0")
+	fs.BoolVar(&o.long, "long", "l", false, "Show the permissions, link count, owner, group, size, modification time, and device of each link, like ls -l")
+	fs.BoolVar(&o.absolute, "absolute", "", false, "Print the paths of the links as absolute paths")
+	fs.BoolVar(&o.relative, "relative", "", false, "Print the paths of the links relative to the search path they were found under")
+	fs.StringVar(&o.relativeTo, "relative-to", "", "", "Print the paths of the links relative to `directory`, e.g. . for the current directory")
+	fs.StringVar(&o.sortBy, "sort", "", "", "Print the results sorted by `key`: path, mtime, or size (buffers them until the search ends)")
+	fs.Choices("sort", "path", "mtime", "size")
+	fs.BoolVar(&o.summary, "summary", "", false, "Print statistics about the search after the results (in the output with --format json, on stderr otherwise)")
+	fs.Var(negatedBool{&o.summary}, "no-summary", "", "Do not print statistics, e.g. when --summary is set in the configuration")
+	fs.StringVar(&o.errors, "errors", "", "text", "Report the paths that could not be examined on stderr as `format`: text (a summary) or json (one object per path as it happens)")
+	fs.Choices("errors", "text", "json")
+	fs.BoolVar(&o.noProgress, "no-progress", "", false, "Do not show the progress of the search on stderr when it is a terminal")
+	fs.BoolVar(&o.quiet, "quiet", "q", false, "Print nothing and stop at the first match; only the exit status tells whether a link was found")
+	fs.BoolVar(&o.tui, "tui", "", false, "Browse the results in an interactive table; marked paths are printed on exit")
+	registerColorFlag(fs)
+}
+
+// unescape replaces the escape sequences \t, \n, \0, and \\ in a template
+// given on the command line with the characters they stand for.
+func unescape(s string) string {
+	return strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\0`, "\x00", `\\`, `\`).Replace(s)
+}
+
+// searchObserver is implemented by sinks that follow the progress of the
+// search feeding them and can stop it.
+type searchObserver interface {
+	observe(f *lfinder.Finder, cancel context.CancelFunc)
+}
+
+// printSummary writes the statistics of a search to stderr if --summary is
+// set, unless they are part of the JSON output or the output is suppressed.
+func (o *outputFlags) printSummary(p lfinder.Progress) {
+	if !o.summary || o.quiet || o.tui || (o.format == "json" && o.template == "" && !o.print0 && !o.inventory) {
+		return
+	}
+	w := os.Stderr
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  %-21s %d\n", "directories visited", p.DirsVisited)
+	fmt.Fprintf(w, "  %-21s %d\n", "files examined", p.FilesExamined)
+	fmt.Fprintf(w, "  %-21s %d\n", "symlinks found", p.Symlinks)
+	fmt.Fprintf(w, "  %-21s %d\n", "hard links found", p.Hardlinks)
+	fmt.Fprintf(w, "  %-21s %d\n", "broken links found", p.BrokenLinks)
+	fmt.Fprintf(w, "  %-21s %d\n", "errors", p.Errors)
+	fmt.Fprintf(w, "  %-21s %d\n", "skipped directories", p.SkippedDirs)
+	if p.AbandonedDirs > 0 {
+		fmt.Fprintf(w, "  %-21s %d\n", "abandoned directories", p.AbandonedDirs)
+	}
+	if len(p.SkippedMounts) > 0 {
+		fmt.Fprintf(w, "  %-21s %s\n", "skipped mounts", strings.Join(p.SkippedMounts, ", "))
+	}
+	fmt.Fprintf(w, "  %-21s %s\n", "elapsed", p.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  %-21s %.0f entries/s\n", "throughput", p.EntriesPerSec)
+}
+
+// streamErrors makes f write each path it cannot examine to stderr as a
+// line of JSON, if --errors json is set. The errors are still recorded in
+// report, whose text summary is then left out.
+func (o *outputFlags) streamErrors(f *lfinder.Finder, report *searchReport) {
+	if o.errors != "json" || o.quiet {
+		return
+	}
+	report.streamed = true
+	var mu sync.Mutex
+	enc := json.NewEncoder(os.Stderr)
+	onError := f.OnError
+	f.OnError = func(err *lfinder.ScanError) {
+		if onError != nil {
+			onError(err)
+		}
+		mu.Lock()
+		enc.Encode(err)
+		mu.Unlock()
+	}
+}
+
+// pathRewriter returns the function rewriting the paths of results as
+// selected by --absolute, --relative, or --relative-to, or nil to print
+// them as found. Paths that cannot be rewritten are left alone.
+func (o *outputFlags) pathRewriter() func(string) string {
+	switch {
+	case o.absolute:
+		return func(path string) string {
+			if abs, err := filepath.Abs(path); err == nil {
+				return abs
+			}
+			return path
+		}
+	case o.relative && o.scan != nil:
+		// Paths are compared in absolute form, as the chains of symlinks
+		// are absolute whatever the search paths.
+		var roots []string
+		for _, root := range o.scan.Roots {
+			if abs, err := filepath.Abs(root); err == nil {
+				roots = append(roots, abs)
+			}
+		}
+		return func(path string) string {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return path
+			}
+			best := ""
+			for _, root := range roots {
+				rel, err := filepath.Rel(root, abs)
+				if err == nil && within(abs, root) && (best == "" || len(rel) < len(best)) {
+					best = rel
+				}
+			}
+			if best == "" {
+				return path
+			}
+			return best
+		}
+	case o.relativeTo != "":
+		base, err := filepath.Abs(o.relativeTo)
+		if err != nil {
+			return nil
+		}
+		return func(path string) string {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return path
+			}
+			if rel, err := filepath.Rel(base, abs); err == nil {
+				return rel
+			}
+			return path
+		}
+	}
+	return nil
+}
+
+// rewritePaths returns results with their paths passed through rewrite: the
+// path of the link, the target it refers to, and the links of its chain, so
+// that a line never mixes forms. The stored destination of a symlink is
+// left as it is.
+func rewritePaths(results iter.Seq[lfinder.Result], rewrite func(string) string) iter.Seq[lfinder.Result] {
+	return func(yield func(lfinder.Result) bool) {
+		for r := range results {
+			r.Path = rewrite(r.Path)
+			if r.Target != "" {
+				r.Target = rewrite(r.Target)
+			}
+			if len(r.Chain) > 0 {
+				chain := make([]string, len(r.Chain))
+				for i, p := range r.Chain {
+					chain[i] = rewrite(p)
+				}
+				r.Chain = chain
+			}
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// sortOrders maps the values of --sort onto result comparisons.
+var sortOrders = map[string]func(a, b lfinder.Result) int{
+	"path":  lfinder.ByPath,
+	"mtime": lfinder.ByModTime,
+	"size":  lfinder.BySize,
+}
+
+// within reports whether path is dir or lies below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copy writes results to sink and returns how many there were. With --quiet
+// nothing is written and the search stops at the first result; with --sort
+// the results are written once the search is over.
+func (o *outputFlags) copy(sink lfinder.Sink, results iter.Seq[lfinder.Result]) (int, error) {
+	if o.quiet {
+		for range results {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if rewrite := o.pathRewriter(); rewrite != nil {
+		results = rewritePaths(results, rewrite)
+	}
+	if o.sortBy != "" && !o.tui {
+		results = lfinder.Sorted(results, sortOrders[o.sortBy])
+	}
+	n, err := lfinder.Copy(sink, results)
+	if errors.Is(err, errQuit) {
+		err = sink.Flush()
+	}
+	return n, err
+}
+
+// finish completes the output file of a search that ended with err, if
+// --output is set: the file is put in place if the search completed, and
+// removed otherwise, leaving any previous report untouched.
+func (o *outputFlags) finish(ctx context.Context, err error) error {
+	if o.file == nil {
+		return err
+	}
+	file := o.file
+	o.file = nil
+	if err != nil {
+		file.abort()
+		return err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		file.abort()
+		warnf("%s not written: the search did not complete", file.name)
+		return nil
+	}
+	return file.commit()
+}
+
+// sink returns the Sink writing results in the selected format to standard
+// output or the --output file, or the interactive table with --tui.
+func (o *outputFlags) sink() (lfinder.Sink, error) {
+	if _, ok := sortOrders[o.sortBy]; o.sortBy != "" && !ok {
+		return nil, fmt.Errorf("unknown sort key %q", o.sortBy)
+	}
+	if o.errors != "text" && o.errors != "json" {
+		return nil, fmt.Errorf("unknown error format %q", o.errors)
+	}
+	if o.absolute && o.relative || (o.absolute || o.relative) && o.relativeTo != "" {
+		return nil, errors.New("--absolute, --relative, and --relative-to cannot be combined")
+	}
+	sink, err := o.newSink()
+	if err != nil {
+		if o.file != nil {
+			o.file.abort()
+			o.file = nil
+		}
+		return nil, err
+	}
+	if !o.noProgress && !o.quiet && !o.tui && o.scan != nil && isTerminal(os.Stderr) && os.Getenv("TERM") != "dumb" {
+		sink = newProgressLine(sink, o.scan.Roots)
+	}
+	return sink, nil
+}
+
+// newSink implements sink.
+func (o *outputFlags) newSink() (lfinder.Sink, error) {
+	w := os.Stdout
+	if o.output != "" && !o.quiet {
+		file, err := createAtomic(o.output)
+		if err != nil {
+			return nil, err
+		}
+		o.file, w = file, file.File
+	}
+	switch {
+	case o.tui:
+		return newTUISink(w)
+	case o.print0:
+		return lfinder.NewPathSink(w, 0), nil
+	case o.template != "":
+		tmpl, err := template.New("result").Parse(unescape(o.template))
+		if err != nil {
+			return nil, fmt.Errorf("--format-template: %v", err)
+		}
+		return lfinder.NewTemplateSink(w, tmpl), nil
+	}
+	switch o.format {
+	case "text", "json":
+		if o.inventory {
+			sink := lfinder.NewInventorySink(w)
+			sink.JSON = o.format == "json"
+			return sink, nil
+		}
+	}
+	switch o.format {
+	case "text":
+		sink := lfinder.NewTextSink(w)
+		sink.ShowTarget = o.showTarget
+		sink.Color = color.enabled(w)
+		sink.Long = o.long
+		return sink, nil
+	case "json":
+		sink := lfinder.NewJSONSink(w)
+		if o.scan != nil {
+			scan := *o.scan
+			if !o.summary {
+				scan.Summary = nil
+			}
+			sink.Scan = &scan
+		}
+		return sink, nil
+	case "ndjson":
+		return lfinder.NewNDJSONSink(w), nil
+	case "csv":
+		return lfinder.NewCSVSink(w, ','), nil
+	case "tsv":
+		return lfinder.NewCSVSink(w, '\t'), nil
+	case "dot":
+		return lfinder.NewDOTSink(w), nil
+	case "html":
+		sink := lfinder.NewHTMLSink(w)
+		sink.Scan = o.scan
+		return sink, nil
+	case "junit":
+		sink := lfinder.NewJUnitSink(w)
+		sink.Scan = o.scan
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", o.format)
+	}
+}
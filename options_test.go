@@ -0,0 +1,236 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+// targetTree creates two directories holding files to use as targets, and
+// returns them.
+func targetTree(t *testing.T) (root, other string) {
+	t.Helper()
+	root, other = t.TempDir(), t.TempDir()
+	for _, name := range []string{
+		filepath.Join(root, "lib", "libssl.so.3"),
+		filepath.Join(root, "lib", "libssl.so.1"),
+		filepath.Join(root, "bin", "tool"),
+		filepath.Join(other, "libcrypto.so.3"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, other
+}
+
+// TestTargets checks that relative targets are taken from the first search
+// path, and that every target is cleaned.
+func TestTargets(t *testing.T) {
+	root, other := targetTree(t)
+	var o findFlags
+	o.paths.values = []string{root, other}
+	got, err := o.targets([]string{"bin/tool", "lib/../bin/tool", filepath.Join(other, "libcrypto.so.3"), other + "/./x/../libcrypto.so.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(root, "bin/tool"),
+		filepath.Join(root, "bin/tool"),
+		filepath.Join(other, "libcrypto.so.3"),
+		filepath.Join(other, "libcrypto.so.3"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("targets() = %q, want %q", got, want)
+	}
+}
+
+// TestTargetsFrom checks that the targets listed in a file follow those on
+// the command line.
+func TestTargetsFrom(t *testing.T) {
+	root, other := targetTree(t)
+	list := filepath.Join(other, "targets")
+	if err := os.WriteFile(list, []byte("bin/tool\r\n\n"+filepath.Join(other, "x")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var o findFlags
+	o.paths.values = []string{root}
+	o.targetsFrom = list
+	got, err := o.targets([]string{"lib/libssl.so.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "lib/libssl.so.3"), filepath.Join(root, "bin/tool"), filepath.Join(other, "x")}
+	if !slices.Equal(got, want) {
+		t.Errorf("targets() = %q, want %q", got, want)
+	}
+
+	o.targetsFrom = filepath.Join(other, "missing")
+	if _, err := o.targets(nil); err == nil || !strings.Contains(err.Error(), o.targetsFrom) {
+		t.Errorf("targets() = %v, want an error naming %s", err, o.targetsFrom)
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		null  bool
+		want  []string
+	}{
+		{"a\nb c\n\nd\n", false, []string{"a", "b c", "d"}},
+		{"a\r\nb\r\n", false, []string{"a", "b"}},
+		{"a\nb", false, []string{"a", "b"}},
+		// A list holding a NUL byte is NUL-separated.
+		{"a\nb\x00c\x00", false, []string{"a\nb", "c"}},
+		{"a\nb", true, []string{"a\nb"}},
+		{"a\r\x00", true, []string{"a\r"}},
+		{"", false, nil},
+	} {
+		got, err := readTargets(strings.NewReader(tt.input), tt.null)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("readTargets(%q, %v) = %q, want %q", tt.input, tt.null, got, tt.want)
+		}
+	}
+}
+
+func TestGlobTargets(t *testing.T) {
+	root, other := targetTree(t)
+	var o findFlags
+	o.paths.values = []string{root}
+	// Relative patterns are taken from the first search path.
+	o.globs.values = []string{"lib/libssl.so*", filepath.Join(other, "lib*.so.?")}
+	got, err := o.targets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "lib/libssl.so.1"), filepath.Join(root, "lib/libssl.so.3"), filepath.Join(other, "libcrypto.so.3")}
+	if !slices.Equal(got, want) {
+		t.Errorf("targets() = %q, want %q", got, want)
+	}
+
+	o.globs.values = []string{"lib/libz*"}
+	if _, err := o.targets(nil); err == nil || !strings.Contains(err.Error(), "--glob lib/libz*: no file matches") {
+		t.Errorf("targets() = %v, want an error for a pattern without match", err)
+	}
+}
+
+func TestSearchRoots(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	if got := (&searchFlags{}).roots(); !slices.Equal(got, []string{"/"}) {
+		t.Errorf("default roots %q, want /", got)
+	}
+	o := searchFlags{paths: stringList{values: []string{"/srv/", "/a,/b"}}}
+	if got, want := o.roots(), []string{"/srv", "/a", "/b"}; !slices.Equal(got, want) {
+		t.Errorf("roots() = %q, want %q", got, want)
+	}
+	// --root is searched unless paths below it are given.
+	o = searchFlags{root: "/mnt/image/"}
+	if got, want := o.roots(), []string{"/mnt/image"}; !slices.Equal(got, want) {
+		t.Errorf("roots() with --root = %q, want %q", got, want)
+	}
+	o.paths.values = []string{"/mnt/image/etc"}
+	if got, want := o.roots(), []string{"/mnt/image/etc"}; !slices.Equal(got, want) {
+		t.Errorf("roots() with --root and --path = %q, want %q", got, want)
+	}
+}
+
+// TestExactRoots checks that the roots recorded in an index are searched
+// as they are, even when they hold commas.
+func TestExactRoots(t *testing.T) {
+	o := searchFlags{exactRoots: []string{"/tmp/c,d"}, paths: stringList{values: []string{"/srv"}}}
+	if got, want := o.roots(), []string{"/tmp/c,d"}; !slices.Equal(got, want) {
+		t.Errorf("roots() = %q, want %q", got, want)
+	}
+}
+
+// chainResult is a symlink reached through another one, whose paths are
+// rewritten by the path options.
+var chainResult = lfinder.Result{
+	Path:       "/srv/www/link",
+	Kind:       lfinder.KindSymlink,
+	Target:     "/srv/data/file",
+	LinkTarget: "../data/file",
+	Chain:      []string{"/srv/www/link", "/srv/www/hop", "/srv/data/file"},
+}
+
+// rewrite returns the path, target, and chain of chainResult rewritten as
+// out asks, checking that its stored destination and the original result
+// are left alone.
+func rewrite(t *testing.T, out *outputFlags) (path, target string, chain []string) {
+	t.Helper()
+	rewriter := out.pathRewriter()
+	if rewriter == nil {
+		t.Fatal("no rewriter")
+	}
+	for r := range rewritePaths(slices.Values([]lfinder.Result{chainResult}), rewriter) {
+		if r.LinkTarget != chainResult.LinkTarget {
+			t.Errorf("stored destination rewritten to %s", r.LinkTarget)
+		}
+		path, target, chain = r.Path, r.Target, r.Chain
+	}
+	if !slices.Equal(chainResult.Chain, []string{"/srv/www/link", "/srv/www/hop", "/srv/data/file"}) {
+		t.Errorf("chain of the original result modified: %q", chainResult.Chain)
+	}
+	return path, target, chain
+}
+
+func TestRelativePaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	// Paths are relative to the deepest search path holding them.
+	out := &outputFlags{relative: true, scan: &lfinder.ScanMetadata{Roots: []string{"/srv", "/srv/www"}}}
+	path, target, chain := rewrite(t, out)
+	if path != "link" || target != "data/file" || !slices.Equal(chain, []string{"link", "hop", "data/file"}) {
+		t.Errorf("rewritten to %s, %s, %q", path, target, chain)
+	}
+	// Those outside the search paths are left absolute.
+	out.scan.Roots = []string{"/srv/www"}
+	path, target, chain = rewrite(t, out)
+	if path != "link" || target != "/srv/data/file" || !slices.Equal(chain, []string{"link", "hop", "/srv/data/file"}) {
+		t.Errorf("rewritten to %s, %s, %q", path, target, chain)
+	}
+	if (&outputFlags{}).pathRewriter() != nil {
+		t.Error("paths rewritten by default")
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	path, target, chain := rewrite(t, &outputFlags{relativeTo: "/srv/data"})
+	if path != "../www/link" || target != "file" || !slices.Equal(chain, []string{"../www/link", "../www/hop", "file"}) {
+		t.Errorf("rewritten to %s, %s, %q", path, target, chain)
+	}
+}
+
+func TestAbsolutePaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	path, target, chain := rewrite(t, &outputFlags{absolute: true})
+	if path != chainResult.Path || target != chainResult.Target || !slices.Equal(chain, chainResult.Chain) {
+		t.Errorf("absolute paths rewritten to %s, %s, %q", path, target, chain)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := (&outputFlags{absolute: true}).pathRewriter()("a/b"), filepath.Join(wd, "a/b"); got != want {
+		t.Errorf("a/b rewritten to %s, want %s", got, want)
+	}
+}
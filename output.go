@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// atomicFile is an output file written under a temporary name in the same
+// directory and renamed into place by commit, so that readers never see a
+// partial report. An interrupted run removes the temporary file.
+type atomicFile struct {
+	*os.File
+	name    string
+	signals chan os.Signal
+}
+
+// createAtomic starts writing the file name.
+func createAtomic(name string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	signals := make(chan os.Signal, 1)
+	a := &atomicFile{File: f, name: name, signals: signals}
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-signals; ok {
+			os.Remove(f.Name())
+			os.Exit(130)
+		}
+	}()
+	return a, nil
+}
+
+// commit closes the file and renames it into place.
+func (a *atomicFile) commit() error {
+	a.stopSignals()
+	if err := a.Sync(); err != nil {
+		a.abort()
+		return err
+	}
+	if err := a.Close(); err != nil {
+		os.Remove(a.File.Name())
+		return err
+	}
+	if err := os.Rename(a.File.Name(), a.name); err != nil {
+		os.Remove(a.File.Name())
+		return err
+	}
+	return nil
+}
+
+// abort closes and removes the file, leaving any previous file in place.
+func (a *atomicFile) abort() {
+	a.stopSignals()
+	a.Close()
+	os.Remove(a.File.Name())
+}
+
+func (a *atomicFile) stopSignals() {
+	if a.signals != nil {
+		signal.Stop(a.signals)
+		close(a.signals)
+		a.signals = nil
+	}
+}
@@ -0,0 +1,161 @@
+package lfinder
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// maxAliasSize bounds the size of the files examined as Finder aliases,
+// which hold the bookmark of their destination and possibly its icon.
+const maxAliasSize = 4 << 20
+
+// Bookmark data, as written by the CFURL bookmark API into Finder alias
+// files since Mac OS X 10.6.
+const (
+	bookmarkTOCMagic = 0xfffffffe
+	bookmarkPath     = 0x1004 // array of the path components of the destination
+	bookmarkString   = 0x0101
+	bookmarkArray    = 0x0601
+	maxBookmarkTOCs  = 16
+)
+
+var (
+	bookmarkMagic = []byte("book")
+	errNotAlias   = errors.New("not a Finder alias")
+)
+
+// readAlias reads the Finder alias file path on fsys and returns the path
+// of its destination. Files that cannot be aliases are ruled out by their
+// size and first bytes, before being read in full.
+func readAlias(fsys OpenFS, path string, info fs.FileInfo) (string, error) {
+	if info.Size() < 16 || info.Size() > maxAliasSize {
+		return "", errNotAlias
+	}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data := make([]byte, info.Size())
+	n, err := io.ReadFull(f, data[:len(bookmarkMagic)])
+	if err != nil || !bytes.Equal(data[:n], bookmarkMagic) {
+		return "", errNotAlias
+	}
+	m, err := io.ReadFull(f, data[n:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return parseAlias(data[:n+m])
+}
+
+// parseAlias returns the destination path recorded in the bookmark data of
+// an alias file. An alias file starts with a "book" ... "mark" header
+// followed by the bookmark itself, which starts with "book" too, so every
+// occurrence is tried in turn.
+func parseAlias(data []byte) (string, error) {
+	for off := 0; off < len(data); {
+		if path, ok := parseBookmark(data[off:]); ok {
+			return path, nil
+		}
+		i := bytes.Index(data[off+1:], bookmarkMagic)
+		if i < 0 {
+			break
+		}
+		off += 1 + i
+	}
+	return "", errNotAlias
+}
+
+// parseBookmark decodes bookmark data: a header giving its size and the
+// offset of the data area, then in the data area the offset of the first
+// table of contents, whose entries map keys to the offsets of items. The
+// path is an array item of string items, the components of the absolute
+// path of the destination.
+func parseBookmark(data []byte) (string, bool) {
+	size, ok1 := uint32At(data, 4)
+	header, ok2 := uint32At(data, 12)
+	if !ok1 || !ok2 || !bytes.HasPrefix(data, bookmarkMagic) || header < 16 || size <= header || int(size) > len(data) {
+		return "", false
+	}
+	data = data[:size]
+	base := int(header)
+	item := func(off uint32) (typ uint32, value []byte, ok bool) {
+		start := base + int(off)
+		length, ok1 := uint32At(data, start)
+		typ, ok2 := uint32At(data, start+4)
+		if !ok1 || !ok2 || start+8+int(length) > len(data) {
+			return 0, nil, false
+		}
+		return typ, data[start+8 : start+8+int(length)], true
+	}
+
+	toc, ok := uint32At(data, base)
+	if !ok {
+		return "", false
+	}
+	for range maxBookmarkTOCs {
+		start := base + int(toc)
+		magic, _ := uint32At(data, start+4)
+		next, _ := uint32At(data, start+12)
+		count, ok := uint32At(data, start+16)
+		if !ok || magic != bookmarkTOCMagic {
+			return "", false
+		}
+		for i := range int(count) {
+			entry := start + 20 + 12*i
+			key, ok1 := uint32At(data, entry)
+			off, ok2 := uint32At(data, entry+4)
+			if !ok1 || !ok2 {
+				return "", false
+			}
+			if key != bookmarkPath {
+				continue
+			}
+			typ, array, ok := item(off)
+			if !ok || typ != bookmarkArray {
+				return "", false
+			}
+			var elems []string
+			for j := 0; j+4 <= len(array); j += 4 {
+				off, _ := uint32At(array, j)
+				typ, elem, ok := item(off)
+				if !ok || typ != bookmarkString {
+					return "", false
+				}
+				elems = append(elems, string(elem))
+			}
+			return "/" + strings.Join(elems, "/"), true
+		}
+		if next == 0 {
+			return "", false
+		}
+		toc = next
+	}
+	return "", false
+}
+
+// matchAlias matches the Finder alias path if it leads to a target.
+func (ts *targetSet) matchAlias(path string, info fs.FileInfo) (Result, bool, error) {
+	dest, err := readAlias(ts.aliases, path, info)
+	if errors.Is(err, errNotAlias) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, err
+	}
+	resolved, err := ts.fsys.EvalSymlinks(dest)
+	if err != nil {
+		return Result{}, false, nil
+	}
+	target, ok := ts.lookup(resolved)
+	if !ok {
+		return Result{}, false, nil
+	}
+	result := newResult(path, KindAlias, info)
+	result.Target = target
+	result.LinkTarget = dest
+	return result, true, nil
+}
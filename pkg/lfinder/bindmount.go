@@ -0,0 +1,89 @@
+package lfinder
+
+import "path/filepath"
+
+// mountTable locates paths on the mounts of the host, to recognize the
+// files reachable at several paths because their file system, or part of
+// it, is bind mounted more than once.
+type mountTable struct {
+	mounts []Mount
+}
+
+// newMountTable returns the table of mounts, in mount order, or nil if no
+// file system is mounted twice, in which case every file has a single path.
+func newMountTable(mounts []Mount) *mountTable {
+	seen := make(map[uint64]bool, len(mounts))
+	for _, m := range mounts {
+		if seen[m.Device] {
+			return &mountTable{mounts: mounts}
+		}
+		seen[m.Device] = true
+	}
+	return nil
+}
+
+// locate returns the mount path lies on and the path of the file within
+// its file system. A mount hides the earlier ones at the same mount point.
+func (t *mountTable) locate(path string) (Mount, string, bool) {
+	var (
+		found Mount
+		rel   string
+		ok    bool
+	)
+	for _, m := range t.mounts {
+		r, in := below(path, m.Path)
+		if in && (!ok || len(m.Path) >= len(found.Path)) {
+			found, rel, ok = m, r, true
+		}
+	}
+	if !ok {
+		return Mount{}, "", false
+	}
+	return found, filepath.Join(found.Root, rel), true
+}
+
+// bind reports whether m is a bind mount: a subdirectory of a file system,
+// or a file system mounted at another path before.
+func (t *mountTable) bind(m Mount) bool {
+	if m.Root != "/" {
+		return true
+	}
+	for _, other := range t.mounts {
+		if other.ID == m.ID {
+			return false
+		}
+		if other.Device == m.Device {
+			return true
+		}
+	}
+	return false
+}
+
+// primary returns the path of the file at path through the first mount of
+// its file system showing it, so that the paths to a file through bind
+// mounts compare equal. Other paths are returned unchanged.
+func (t *mountTable) primary(path string) string {
+	m, fsPath, ok := t.locate(path)
+	if !ok {
+		return path
+	}
+	for _, other := range t.mounts {
+		if other.Device != m.Device {
+			continue
+		}
+		if rel, ok := below(fsPath, other.Root); ok {
+			return filepath.Join(other.Path, rel)
+		}
+	}
+	return path
+}
+
+// key returns the identity of r for de-duplication across bind mounts: its
+// path within its file system rather than on the host.
+func (t *mountTable) key(r Result) resultKey {
+	key := keyOf(r)
+	if _, fsPath, ok := t.locate(key.path); ok {
+		key.path = fsPath
+	}
+	return key
+}
@@ -0,0 +1,57 @@
+package lfinder
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// foldCase returns path in lower case, the form in which paths are
+// compared on file systems that ignore case.
+func foldCase(path string) string {
+	return strings.ToLower(path)
+}
+
+// swapCase returns s with the case of its letters swapped.
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// ignoresCase reports whether the file system holding the existing file
+// path ignores case in names, as APFS, HFS+, and NTFS do by default: whether
+// the nearest component of path holding letters still names the same file
+// with their case swapped.
+func ignoresCase(fsys FS, path string) bool {
+	for p := path; ; p = filepath.Dir(p) {
+		name := filepath.Base(p)
+		if swapped := swapCase(name); swapped != name {
+			info, err1 := fsys.Lstat(p)
+			other, err2 := fsys.Lstat(filepath.Join(filepath.Dir(p), swapped))
+			if err1 != nil || err2 != nil {
+				return false
+			}
+			dev1, ino1, ok1 := fileID(info)
+			dev2, ino2, ok2 := fileID(other)
+			return ok1 && ok2 && dev1 == dev2 && ino1 == ino2
+		}
+		if filepath.Dir(p) == p {
+			return false
+		}
+	}
+}
+
+// ignoresCase reports whether some target lies on a file system that
+// ignores case.
+func (ts *targetSet) ignoresCase() bool {
+	for path := range ts.paths {
+		if ignoresCase(ts.fsys, path) {
+			return true
+		}
+	}
+	return false
+}
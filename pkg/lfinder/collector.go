@@ -0,0 +1,100 @@
+package lfinder
+
+import (
+	"iter"
+	"path/filepath"
+	"sync"
+)
+
+// resultKey identifies a result for de-duplication.
+type resultKey struct {
+	dev, ino uint64
+	path     string
+}
+
+func keyOf(r Result) resultKey {
+	return resultKey{dev: r.Device, ino: r.Inode, path: filepath.Clean(r.Path)}
+}
+
+// resultSet records the results seen so far. It is safe for concurrent use.
+type resultSet struct {
+	mu   sync.Mutex
+	seen map[resultKey]struct{}
+}
+
+// add records r and reports whether it had not been seen before.
+func (s *resultSet) add(r Result) bool {
+	return s.addKey(keyOf(r))
+}
+
+// addKey records key and reports whether it had not been seen before.
+func (s *resultSet) addKey(key resultKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[resultKey]struct{})
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
+
+// Dedup returns a sequence yielding the results of seq with duplicates
+// removed. Two results are duplicates when they have the same device, inode,
+// and cleaned path, as happens when overlapping roots or bind mounts make a
+// path reachable twice.
+func Dedup(seq iter.Seq[Result]) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		var seen resultSet
+		for r := range seq {
+			if seen.add(r) && !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// Collector is a Sink that accumulates results in memory, dropping
+// duplicates as Dedup does. It is safe for concurrent use.
+type Collector struct {
+	seen    resultSet
+	mu      sync.Mutex
+	results []Result
+}
+
+// Add records r unless an equivalent result was already collected, and
+// reports whether it was added.
+func (c *Collector) Add(r Result) bool {
+	if !c.seen.add(r) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+	return true
+}
+
+// Emit implements Sink by calling Add.
+func (c *Collector) Emit(r Result) error {
+	c.Add(r)
+	return nil
+}
+
+// Flush implements Sink; it has nothing to do.
+func (c *Collector) Flush() error { return nil }
+
+// Results returns the collected results in the order they were added.
+func (c *Collector) Results() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Result(nil), c.results...)
+}
+
+// Len returns the number of results collected.
+func (c *Collector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.results)
+}
@@ -0,0 +1,128 @@
+package lfinder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// contentIndex matches regular files whose contents are identical to those
+// of a target. Candidates are first compared by size, then by SHA-256 digest
+// and, with verify set, byte by byte. The digest of a target is only
+// computed once a file of the same size is met.
+type contentIndex struct {
+	fsys   OpenFS
+	verify bool
+	bySize map[int64][]*contentTarget
+}
+
+// contentTarget is a target compared by content.
+type contentTarget struct {
+	path string
+	once sync.Once
+	sum  [sha256.Size]byte
+	err  error
+}
+
+// newContentIndex stats targets on fsys, which must be able to read files.
+// Targets that are not regular files are left out.
+func newContentIndex(fsys FS, verify bool, targets []string) (*contentIndex, error) {
+	ofs, ok := fsys.(OpenFS)
+	if !ok {
+		return nil, errors.New("comparing contents: the file system cannot open files")
+	}
+	c := &contentIndex{fsys: ofs, verify: verify, bySize: make(map[int64][]*contentTarget)}
+	for _, target := range targets {
+		info, err := fsys.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("accessing target file: %w", err)
+		}
+		if info.Mode().IsRegular() {
+			c.bySize[info.Size()] = append(c.bySize[info.Size()], &contentTarget{path: target})
+		}
+	}
+	return c, nil
+}
+
+// match returns the first target whose contents are identical to those of
+// the regular file at path.
+func (c *contentIndex) match(path string, info fs.FileInfo) (string, bool, error) {
+	targets := c.bySize[info.Size()]
+	if len(targets) == 0 {
+		return "", false, nil
+	}
+	sum, err := c.digest(path)
+	if err != nil {
+		return "", false, err
+	}
+	for _, t := range targets {
+		t.once.Do(func() { t.sum, t.err = c.digest(t.path) })
+		if t.err != nil || t.sum != sum {
+			continue
+		}
+		if c.verify {
+			same, err := c.equal(path, t.path)
+			if err != nil {
+				return "", false, err
+			}
+			if !same {
+				continue
+			}
+		}
+		return t.path, true, nil
+	}
+	return "", false, nil
+}
+
+// digest returns the SHA-256 digest of the contents of the file at path.
+func (c *contentIndex) digest(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := c.fsys.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+// equal reports whether the files at a and b have the same contents.
+func (c *contentIndex) equal(a, b string) (bool, error) {
+	fa, err := c.fsys.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := c.fsys.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		doneA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		doneB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		switch {
+		case errA != nil && !doneA:
+			return false, errA
+		case errB != nil && !doneB:
+			return false, errB
+		case doneA || doneB:
+			return doneA && doneB, nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package lfinder
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// direntBufs holds the buffers getdents64 fills, reused across directories.
+var direntBufs = sync.Pool{New: func() any {
+	b := make([]byte, 32<<10)
+	return &b
+}}
+
+// Offsets in a struct linux_dirent64, as returned by getdents64.
+const (
+	direntIno    = 0
+	direntReclen = 16
+	direntType   = 18
+	direntName   = 19
+)
+
+// readDirFast reads the directory name with getdents64, returning its
+// entries unsorted, whose Info fetches fields with lstatFields. Entries whose
+// type the file system does not report are typed with it.
+func readDirFast(name string, fields statFields) ([]fs.DirEntry, error) {
+	fd, err := syscall.Open(name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer syscall.Close(fd)
+	bufp := direntBufs.Get().(*[]byte)
+	defer direntBufs.Put(bufp)
+	buf := *bufp
+
+	var entries []fs.DirEntry
+	for {
+		n, err := syscall.Getdents(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return entries, &fs.PathError{Op: "getdents64", Path: name, Err: err}
+		}
+		if n <= 0 {
+			return entries, nil
+		}
+		for off := 0; off < n; {
+			rec := buf[off:]
+			reclen := int(binary.NativeEndian.Uint16(rec[direntReclen:]))
+			if reclen < direntName || off+reclen > n {
+				return entries, &fs.PathError{Op: "getdents64", Path: name, Err: syscall.EIO}
+			}
+			off += reclen
+			ino := binary.NativeEndian.Uint64(rec[direntIno:])
+			base := rec[direntName:reclen]
+			for i, c := range base {
+				if c == 0 {
+					base = base[:i]
+					break
+				}
+			}
+			if ino == 0 || string(base) == "." || string(base) == ".." {
+				continue
+			}
+			e := &dirent{dir: name, name: string(base), typ: direntMode(rec[direntType]), fields: fields}
+			if rec[direntType] == syscall.DT_UNKNOWN {
+				info, err := lstatFields(e.path(), fields)
+				if err != nil {
+					// Removed since the directory was read.
+					continue
+				}
+				e.typ, e.info = info.Mode().Type(), info
+			}
+			entries = append(entries, e)
+		}
+	}
+}
+
+// direntMode returns the type bits of the file mode for the d_type t.
+func direntMode(t byte) fs.FileMode {
+	switch t {
+	case syscall.DT_DIR:
+		return fs.ModeDir
+	case syscall.DT_LNK:
+		return fs.ModeSymlink
+	case syscall.DT_FIFO:
+		return fs.ModeNamedPipe
+	case syscall.DT_SOCK:
+		return fs.ModeSocket
+	case syscall.DT_CHR:
+		return fs.ModeDevice | fs.ModeCharDevice
+	case syscall.DT_BLK:
+		return fs.ModeDevice
+	}
+	return 0
+}
+
+// dirent is an entry read by readDirFast.
+type dirent struct {
+	dir, name string
+	typ       fs.FileMode
+	fields    statFields
+	info      fs.FileInfo // set when the entry was typed with Lstat
+}
+
+func (e *dirent) path() string      { return filepath.Join(e.dir, e.name) }
+func (e *dirent) Name() string      { return e.name }
+func (e *dirent) IsDir() bool       { return e.typ.IsDir() }
+func (e *dirent) Type() fs.FileMode { return e.typ }
+func (e *dirent) String() string    { return fs.FormatDirEntry(e) }
+
+func (e *dirent) Info() (fs.FileInfo, error) {
+	if e.info != nil {
+		return e.info, nil
+	}
+	return lstatFields(e.path(), e.fields)
+}
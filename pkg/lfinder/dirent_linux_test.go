@@ -0,0 +1,87 @@
+package lfinder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// typedDir creates a directory holding a file, a subdirectory, a symbolic
+// link, a named pipe, and enough files for getdents64 to fill its buffer
+// several times, and returns it.
+func typedDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := range 2000 {
+		name := filepath.Join(dir, fmt.Sprintf("file-with-a-rather-long-name-%04d", i))
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// TestReadDirFast checks that readDirFast reads the same entries, with the
+// same types, as os.ReadDir, whatever fields their Info fetches.
+func TestReadDirFast(t *testing.T) {
+	dir := typedDir(t)
+	want, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fields := range []statFields{0, statMode, statAll} {
+		got, err := readDirFast(dir, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.SortFunc(got, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+		if len(got) != len(want) {
+			t.Fatalf("fields %d: read %d entries, want %d", fields, len(got), len(want))
+		}
+		for i, e := range got {
+			if e.Name() != want[i].Name() || e.Type() != want[i].Type() {
+				t.Errorf("fields %d: entry %s %v, want %s %v", fields, e.Name(), e.Type(), want[i].Name(), want[i].Type())
+			}
+		}
+		info, err := got[0].Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name() != got[0].Name() {
+			t.Errorf("fields %d: Info().Name() = %s, want %s", fields, info.Name(), got[0].Name())
+		}
+	}
+}
+
+func TestReadDirFastErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]error{
+		filepath.Join(dir, "missing"): fs.ErrNotExist,
+		file:                          syscall.ENOTDIR,
+	} {
+		_, err := readDirFast(path, 0)
+		var pe *fs.PathError
+		if !errors.As(err, &pe) || pe.Path != path || !errors.Is(err, want) {
+			t.Errorf("readDirFast(%s) = %v, want a *fs.PathError for %v", path, err, want)
+		}
+	}
+}
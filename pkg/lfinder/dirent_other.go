@@ -0,0 +1,10 @@
+//go:build !linux
+
+package lfinder
+
+import "io/fs"
+
+// readDirFast is readDir on this platform.
+func readDirFast(name string, fields statFields) ([]fs.DirEntry, error) {
+	return readDir(name)
+}
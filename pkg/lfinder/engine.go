@@ -0,0 +1,137 @@
+package lfinder
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// Engine selects how the walk reads directories.
+type Engine string
+
+const (
+	// EnginePortable reads directories with os.ReadDir, which sorts their
+	// entries.
+	EnginePortable Engine = ""
+	// EngineFast reads the directories of the host file system on Linux
+	// with getdents64 into reusable buffers, leaving their entries in the
+	// order the file system returns them, which spares an allocation and a
+	// sort per directory on trees of millions of files. Elsewhere, and for
+	// FS other than OSFS, it is EnginePortable.
+	EngineFast Engine = "fast"
+	// EngineURing reads directories as EngineFast does, then Lstats the
+	// entries of each directory in one batch of statx calls submitted
+	// through io_uring, so that hundreds of them are in flight at once
+	// rather than one per worker: on NFS or CephFS, the walk is then bound
+	// by their latency no longer. Where the kernel lacks io_uring or
+	// forbids it, it falls back to EngineFast.
+	EngineURing Engine = "uring"
+)
+
+// walkFS returns fsys, reading directories with the engine selected by
+// Engine, for a walk by workers goroutines, and a function releasing the
+// resources of the engine once the walk is over. On the host file system,
+// the Info of the entries read only holds the attributes the search reads,
+// where the platform allows.
+func (s *search) walkFS(fsys FS, workers int) (FS, func()) {
+	if _, ok := fsys.(OSFS); !ok {
+		return fsys, func() {}
+	}
+	switch s.Engine {
+	case EngineFast:
+		return fastFS{fields: s.fields}, func() {}
+	case EngineURing:
+		if s.io != nil {
+			s.log.Info("io_uring batches cannot be paced, reading directories with the fast engine")
+			return fastFS{fields: s.fields}, func() {}
+		}
+		u, err := newURingFS(workers, s.fields, func(t FileType) bool { return matchesType(s.m, t) })
+		if err != nil {
+			s.log.Info("io_uring unavailable, reading directories with the fast engine", "err", err)
+			return fastFS{fields: s.fields}, func() {}
+		}
+		return u, u.close
+	}
+	if s.fields != statAll {
+		return leanFS{fields: s.fields}, func() {}
+	}
+	return fsys, func() {}
+}
+
+// fastFS is the host file system, with directories read by readDirFast,
+// whose entries fetch fields.
+type fastFS struct {
+	OSFS
+	fields statFields
+}
+
+func (f fastFS) ReadDir(name string) ([]fs.DirEntry, error) { return readDirFast(name, f.fields) }
+
+// uringFS is fastFS, fetching the Info of the entries of each directory
+// with a statxRing. It holds a ring per walker goroutine.
+type uringFS struct {
+	fastFS
+	rings chan *statxRing
+	// wants reports whether the entries of a type are examined at all.
+	wants func(t FileType) bool
+}
+
+func newURingFS(workers int, fields statFields, wants func(t FileType) bool) (*uringFS, error) {
+	u := &uringFS{fastFS: fastFS{fields: fields}, rings: make(chan *statxRing, workers), wants: wants}
+	for range workers {
+		r, err := newStatxRing(fields)
+		if err != nil {
+			u.close()
+			return nil, err
+		}
+		u.rings <- r
+	}
+	return u, nil
+}
+
+// close releases the rings.
+func (u *uringFS) close() {
+	for {
+		select {
+		case r := <-u.rings:
+			r.close()
+		default:
+			return
+		}
+	}
+}
+
+// prefetch Lstats the entries of dir the search examines. A ring that
+// fails is dropped, leaving its entries to be Lstat one by one.
+func (u *uringFS) prefetch(dir string, entries []*cachedEntry) {
+	var (
+		wanted []*cachedEntry
+		paths  []string
+	)
+	for _, e := range entries {
+		if u.wants(FileTypeOf(e.Type())) {
+			wanted = append(wanted, e)
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(wanted) < 2 {
+		return
+	}
+	var r *statxRing
+	select {
+	case r = <-u.rings:
+	default:
+		// Every ring was dropped.
+		return
+	}
+	infos, errs := make([]fs.FileInfo, len(paths)), make([]error, len(paths))
+	if err := r.lstat(paths, infos, errs); err != nil {
+		r.close()
+		return
+	}
+	u.rings <- r
+	for i, e := range wanted {
+		if infos[i] != nil || errs[i] != nil {
+			e.set(infos[i], errs[i])
+		}
+	}
+}
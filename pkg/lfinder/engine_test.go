@@ -0,0 +1,98 @@
+package lfinder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// hostTree creates a tree in a temporary directory holding a file, hard and
+// symbolic links to it, a dangling link, and enough other files for a
+// directory to take more than one read, and returns the directory and the
+// file.
+func hostTree(t *testing.T) (dir, target string) {
+	t.Helper()
+	dir = t.TempDir()
+	target = filepath.Join(dir, "target")
+	for _, name := range []string{"a/b", "many"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(target, []byte("target"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 500 {
+		name := filepath.Join(dir, fmt.Sprintf("many/file-with-a-rather-long-name-%03d", i))
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a/hard", "many/hard"} {
+		if err := os.Link(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("hard links not supported: %v", err)
+		}
+	}
+	for name, dest := range map[string]string{
+		"a/b/rel":     "../../target",
+		"a/abs":       target,
+		"a/chain":     "b/rel",
+		"many/broken": "missing",
+	} {
+		if err := os.Symlink(dest, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return dir, target
+}
+
+// testEngine checks that engine finds the links in a hostTree with one
+// walker goroutine and with several. An engine not supported here falls
+// back to another.
+func testEngine(t *testing.T, engine Engine) {
+	t.Helper()
+	dir, target := hostTree(t)
+	// The target counts as one of its own hard links.
+	want := []string{
+		"a/abs symlink",
+		"a/b/rel symlink",
+		"a/chain symlink",
+		"a/hard hardlink",
+		"many/hard hardlink",
+		"target hardlink",
+	}
+	for _, workers := range []int{1, 4} {
+		f := NewFinder(WithRoot(dir), WithEngine(engine), WithWorkers(workers))
+		report, err := f.Find(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Errors.Len() > 0 {
+			t.Errorf("%d workers: errors: %s", workers, report.Errors.Summary())
+		}
+		var got []string
+		for _, r := range report.Results {
+			rel, err := filepath.Rel(dir, r.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprintf("%s %s", filepath.ToSlash(rel), r.Kind))
+			if r.Target != target {
+				t.Errorf("%s: target %s, want %s", r.Path, r.Target, target)
+			}
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%d workers: found %q, want %q", workers, got, want)
+		}
+	}
+}
+
+func TestPortableEngine(t *testing.T) { testEngine(t, EnginePortable) }
+
+func TestFastEngine(t *testing.T) { testEngine(t, EngineFast) }
+
+func TestURingEngine(t *testing.T) { testEngine(t, EngineURing) }
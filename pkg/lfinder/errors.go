@@ -0,0 +1,190 @@
+package lfinder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ErrorCategory classifies why a path could not be examined.
+type ErrorCategory string
+
+// Error categories reported in a ScanError.
+const (
+	// ErrPermission means access to the path was denied.
+	ErrPermission ErrorCategory = "permission"
+	// ErrVanished means the path was removed while the search was running.
+	ErrVanished ErrorCategory = "vanished"
+	// ErrLoop means symbolic link resolution did not terminate.
+	ErrLoop ErrorCategory = "loop"
+	// ErrIO covers every other failure, typically I/O errors.
+	ErrIO ErrorCategory = "io"
+)
+
+// ScanError records a path that could not be examined during a search.
+type ScanError struct {
+	// Path is the path being examined.
+	Path string
+	// Op is the operation that failed, such as "lstat" or "readdir".
+	Op string
+	// Category classifies Err.
+	Category ErrorCategory
+	// Err is the underlying error.
+	Err error
+}
+
+// newScanError wraps err for path, preferring the operation recorded in an
+// fs.PathError over op.
+func newScanError(path, op string, err error) *ScanError {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		op = pe.Op
+	}
+	return &ScanError{Path: path, Op: op, Category: categorize(err), Err: err}
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %v", e.Op, e.Path, e.Category, e.Err)
+}
+
+func (e *ScanError) Unwrap() error { return e.Err }
+
+// MarshalJSON encodes e as an object with path, op, category, errno, and
+// error members. Errno is the symbolic name of the system error, such as
+// "EACCES", and is omitted when Err does not wrap one.
+func (e *ScanError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path     string        `json:"path"`
+		Op       string        `json:"op"`
+		Category ErrorCategory `json:"category"`
+		Errno    string        `json:"errno,omitempty"`
+		Error    string        `json:"error"`
+	}{e.Path, e.Op, e.Category, errnoName(e.Err), e.Err.Error()})
+}
+
+// errnoNames are the symbolic names of the system errors commonly met
+// while walking a file system.
+var errnoNames = map[syscall.Errno]string{
+	syscall.EACCES:       "EACCES",
+	syscall.EPERM:        "EPERM",
+	syscall.ENOENT:       "ENOENT",
+	syscall.ENOTDIR:      "ENOTDIR",
+	syscall.ELOOP:        "ELOOP",
+	syscall.ENAMETOOLONG: "ENAMETOOLONG",
+	syscall.EIO:          "EIO",
+	syscall.ENXIO:        "ENXIO",
+	syscall.ENODEV:       "ENODEV",
+	syscall.ESTALE:       "ESTALE",
+	syscall.EINVAL:       "EINVAL",
+	syscall.EMFILE:       "EMFILE",
+	syscall.ENFILE:       "ENFILE",
+	syscall.ENOMEM:       "ENOMEM",
+	syscall.EINTR:        "EINTR",
+	syscall.ETIMEDOUT:    "ETIMEDOUT",
+}
+
+// errnoName returns the symbolic name of the system error wrapped by err,
+// its number if it has no known name, or "" if err wraps none.
+func errnoName(err error) string {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return ""
+	}
+	if name, ok := errnoNames[errno]; ok {
+		return name
+	}
+	return strconv.Itoa(int(errno))
+}
+
+// categorize maps err onto an ErrorCategory.
+func categorize(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrVanished
+	case isLoop(err):
+		return ErrLoop
+	default:
+		return ErrIO
+	}
+}
+
+// ErrorReport collects the ScanErrors of a search. It is safe for
+// concurrent use; its Add method can be used as Finder.OnError.
+type ErrorReport struct {
+	mu   sync.Mutex
+	errs []*ScanError
+}
+
+// Add records err.
+func (r *ErrorReport) Add(err *ScanError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// Len returns the number of errors recorded.
+func (r *ErrorReport) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.errs)
+}
+
+// Counts returns the number of errors recorded in each category.
+func (r *ErrorReport) Counts() map[ErrorCategory]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[ErrorCategory]int)
+	for _, err := range r.errs {
+		counts[err.Category]++
+	}
+	return counts
+}
+
+// All yields the recorded errors in the order they occurred.
+func (r *ErrorReport) All() iter.Seq[*ScanError] {
+	r.mu.Lock()
+	errs := r.errs[:len(r.errs):len(r.errs)]
+	r.mu.Unlock()
+	return func(yield func(*ScanError) bool) {
+		for _, err := range errs {
+			if !yield(err) {
+				return
+			}
+		}
+	}
+}
+
+// Summary describes the recorded errors in one line, e.g.
+// "3 errors (2 permission, 1 vanished)". It is empty when there are none.
+func (r *ErrorReport) Summary() string {
+	counts := r.Counts()
+	if len(counts) == 0 {
+		return ""
+	}
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+	total := 0
+	parts := make([]string, 0, len(categories))
+	for _, category := range categories {
+		n := counts[ErrorCategory(category)]
+		total += n
+		parts = append(parts, fmt.Sprintf("%d %s", n, category))
+	}
+	noun := "errors"
+	if total == 1 {
+		noun = "error"
+	}
+	return fmt.Sprintf("%d %s (%s)", total, noun, strings.Join(parts, ", "))
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package lfinder
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hasOwnerIDs is set where files have a numeric owner and group.
+const hasOwnerIDs = true
+
+// fileID returns the device and inode numbers recorded in info, if the file
+// system provides them.
+func fileID(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
+
+// fileOwner returns the link count and the numeric owner and group recorded
+// in info, if the file system provides them.
+func fileOwner(info fs.FileInfo) (nlink uint64, uid, gid uint32, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return uint64(st.Nlink), st.Uid, st.Gid, true
+}
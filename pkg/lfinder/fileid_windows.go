@@ -0,0 +1,36 @@
+package lfinder
+
+import "io/fs"
+
+// hasOwnerIDs is set where files have a numeric owner and group.
+const hasOwnerIDs = false
+
+// fileID returns the volume serial number and file index of the file
+// described by info, which identify it as device and inode numbers do on
+// other platforms. Only the FileInfo returned by OSFS and ChrootFS carries
+// them.
+func fileID(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	fi, ok := info.(*fileInfo)
+	if !ok {
+		return 0, 0, false
+	}
+	d := fi.handleInfo()
+	if d == nil {
+		return 0, 0, false
+	}
+	return uint64(d.VolumeSerialNumber), uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow), true
+}
+
+// fileOwner returns the link count of the file described by info. Files
+// have no numeric owner and group on Windows; they are reported as 0.
+func fileOwner(info fs.FileInfo) (nlink uint64, uid, gid uint32, ok bool) {
+	fi, ok := info.(*fileInfo)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	d := fi.handleInfo()
+	if d == nil {
+		return 0, 0, 0, false
+	}
+	return uint64(d.NumberOfLinks), 0, 0, true
+}
@@ -0,0 +1,88 @@
+package lfinder
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// FileType is a type of file, named by the letter find -type uses for it.
+type FileType byte
+
+const (
+	TypeRegular   FileType = 'f' // regular file
+	TypeDir       FileType = 'd' // directory
+	TypeSymlink   FileType = 'l' // symbolic link
+	TypeFIFO      FileType = 'p' // named pipe
+	TypeSocket    FileType = 's' // Unix domain socket
+	TypeBlock     FileType = 'b' // block device
+	TypeCharacter FileType = 'c' // character device
+)
+
+// ParseFileTypes parses a list of file type letters, such as "fl" or
+// "f,l", as accepted by find -type.
+func ParseFileTypes(s string) ([]FileType, error) {
+	var types []FileType
+	for _, c := range []byte(s) {
+		switch t := FileType(c); t {
+		case ',':
+		case TypeRegular, TypeDir, TypeSymlink, TypeFIFO, TypeSocket, TypeBlock, TypeCharacter:
+			types = append(types, t)
+		default:
+			return nil, fmt.Errorf("unknown file type %q: want f, d, l, p, s, b, or c", c)
+		}
+	}
+	return types, nil
+}
+
+// FileTypeOf returns the type of files with mode. Irregular files are
+// reported as regular files.
+func FileTypeOf(mode fs.FileMode) FileType {
+	switch {
+	case mode&fs.ModeDir != 0:
+		return TypeDir
+	case mode&fs.ModeSymlink != 0:
+		return TypeSymlink
+	case mode&fs.ModeNamedPipe != 0:
+		return TypeFIFO
+	case mode&fs.ModeSocket != 0:
+		return TypeSocket
+	case mode&fs.ModeCharDevice != 0:
+		return TypeCharacter
+	case mode&fs.ModeDevice != 0:
+		return TypeBlock
+	}
+	return TypeRegular
+}
+
+// special reports whether files with mode are named pipes, sockets, or
+// device nodes, which can be hard linked like regular files.
+func special(mode fs.FileMode) bool {
+	return mode&(fs.ModeNamedPipe|fs.ModeSocket|fs.ModeDevice|fs.ModeCharDevice) != 0
+}
+
+// lsMode formats mode as ls -l does, e.g. lrwxrwxrwx or drwxr-sr-x, rather
+// than as fs.FileMode.String, which writes Lrwxrwxrwx and dgrwxr-xr-x.
+func lsMode(mode fs.FileMode) string {
+	b := []byte("-rwxrwxrwx")
+	if t := FileTypeOf(mode); t != TypeRegular {
+		b[0] = byte(t)
+	}
+	for i := range 9 {
+		if mode&(1<<(8-i)) == 0 {
+			b[1+i] = '-'
+		}
+	}
+	mark := func(set bool, i int, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == '-' {
+			c -= 'a' - 'A'
+		}
+		b[i] = c
+	}
+	mark(mode&fs.ModeSetuid != 0, 3, 's')
+	mark(mode&fs.ModeSetgid != 0, 6, 's')
+	mark(mode&fs.ModeSticky != 0, 9, 't')
+	return string(b)
+}
@@ -0,0 +1,28 @@
+package lfinder
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestLsMode(t *testing.T) {
+	for mode, want := range map[fs.FileMode]string{
+		0o644:                              "-rw-r--r--",
+		fs.ModeSymlink | 0o777:             "lrwxrwxrwx",
+		fs.ModeDir | 0o755:                 "drwxr-xr-x",
+		fs.ModeNamedPipe | 0o600:           "prw-------",
+		fs.ModeSocket | 0o755:              "srwxr-xr-x",
+		fs.ModeDevice | 0o660:              "brw-rw----",
+		fs.ModeDevice | fs.ModeCharDevice:  "c---------",
+		fs.ModeSetuid | 0o755:              "-rwsr-xr-x",
+		fs.ModeSetuid | 0o644:              "-rwSr--r--",
+		fs.ModeDir | fs.ModeSetgid | 0o750: "drwxr-s---",
+		fs.ModeDir | fs.ModeSticky | 0o777: "drwxrwxrwt",
+		fs.ModeDir | fs.ModeSticky | 0o770: "drwxrwx--T",
+		fs.ModeIrregular | 0o400:           "-r--------",
+	} {
+		if got := lsMode(mode); got != want {
+			t.Errorf("lsMode(%v) = %q, want %q", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,960 @@
+// Package lfinder searches file systems for symbolic links and hard links
+// that refer to a target file.
+package lfinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the least number of worker goroutines used when
+// Finder.Workers is not set, but on spinning disks.
+const DefaultWorkers = 8
+
+// niceWorkers is the number of worker goroutines of a Nice search when
+// Finder.Workers is not set.
+const niceWorkers = 2
+
+// DefaultQueueSize is the number of candidates queued for each worker pool,
+// and of results queued for the caller, when Finder.QueueSize is not set.
+const DefaultQueueSize = 100
+
+// FollowMode selects the symbolic links a Finder follows, like the -P, -H,
+// and -L options of find(1).
+type FollowMode int
+
+// Follow modes.
+const (
+	// FollowNever follows no symbolic link: a root that is a link is not
+	// descended into, and a target that is a link is the link itself, so
+	// the links to the link are found rather than those to its destination.
+	FollowNever FollowMode = iota
+	// FollowRoots follows the roots and targets that are symbolic links,
+	// but no link met during the walk.
+	FollowRoots
+	// FollowAlways also descends into the directories that symbolic links
+	// met during the walk point to, but not those below themselves.
+	FollowAlways
+)
+
+// Finder searches a directory tree for links to a target file.
+// The zero value searches from "/" for both symlinks and hard links;
+// NewFinder builds one from functional options.
+type Finder struct {
+	// Root is the path to start the search from. Defaults to "/" unless
+	// Roots is set.
+	Root string
+	// Roots lists further paths to search along with Root. The roots are
+	// walked concurrently; a root inside another one is only searched once.
+	Roots []string
+	// SymlinksOnly restricts the search to symbolic links.
+	SymlinksOnly bool
+	// HardlinksOnly restricts the search to hard links.
+	HardlinksOnly bool
+	// Into also matches symbolic links resolving to any path below a
+	// target directory, not only to the target itself.
+	Into bool
+	// CaseInsensitive compares the paths of links and targets regardless
+	// of case, so that a link to /Users/Me/File matches the target
+	// /users/me/file. Otherwise, they are compared so when a target lies on
+	// a file system that ignores case, as APFS and NTFS do by default.
+	CaseInsensitive bool
+	// Normalization is the Unicode normalization form the paths of links
+	// and targets are compared in, so that a link stored in NFD, as on
+	// HFS+, matches a target given in NFC. Defaults to NormNone, comparing
+	// paths as they are.
+	Normalization Normalization
+	// SameContent also matches regular files whose contents are identical
+	// to a target's, as KindDuplicate results. FS must implement OpenFS.
+	SameContent bool
+	// VerifyContent compares duplicates byte by byte after their digests,
+	// ruling out hash collisions at the cost of reading both files again.
+	VerifyContent bool
+	// Reflinks also matches regular files sharing storage with a target:
+	// copy-on-write clones on btrfs and XFS, as KindReflink results, and
+	// APFS clones, as KindClone results. It is supported on Linux and
+	// macOS, on the host file system only.
+	Reflinks bool
+	// Shortcuts also matches Windows shell links (.lnk files) leading to a
+	// target, as KindShortcut results. FS must implement OpenFS.
+	Shortcuts bool
+	// Aliases also matches macOS Finder alias files leading to a target,
+	// as KindAlias results. FS must implement OpenFS.
+	Aliases bool
+	// LinkType, if set, restricts the results to symbolic links storing
+	// that type of destination, absolute or relative.
+	LinkType LinkType
+	// MaxChain bounds the number of links followed when expanding the
+	// chain of a symlink resolving through other symlinks. A chain cut short
+	// ends with a link rather than the destination. Zero means no limit.
+	MaxChain int
+	// Workers is the number of goroutines examining files concurrently,
+	// and of goroutines reading directories in parallel. Zero picks a
+	// number from GOMAXPROCS and the storage the roots lie on, as told by
+	// Mounts: more for network file systems, fewer for spinning disks.
+	// When the roots span several devices listed in Mounts, the files of
+	// each device are examined by a pool of their own, of Workers
+	// goroutines or as many as picked for its storage, so that a slow
+	// device does not hold up the others.
+	Workers int
+	// Engine selects how directories are read. Defaults to EnginePortable.
+	Engine Engine
+	// AdaptiveWorkers resizes the pool of goroutines examining files during
+	// the search, starting from Workers: it grows while the latency of their
+	// Lstat calls shows them waiting on storage and each step raises the
+	// rate of the calls, and shrinks back once they are served from the
+	// cache.
+	AdaptiveWorkers bool
+	// QueueSize is the number of candidates the walk queues for each pool
+	// of workers, and of results the workers queue for the caller, before
+	// waiting: a caller slower than the search holds up the workers and,
+	// in turn, the walk. Defaults to DefaultQueueSize.
+	QueueSize int
+	// MaxMemory, if set, holds the walk while the Go heap exceeds that
+	// many bytes, until the workers and the caller have worked through the
+	// candidates and results queued. It bounds the memory held by the
+	// search, not by the results the caller keeps, such as to sort them:
+	// when the heap stays over the budget with nothing queued, the budget
+	// is given up, with a warning.
+	MaxMemory int64
+	// IOLimit bounds the rate of the directory reads and file examinations
+	// of the search, and how many run at once. It reads directories with
+	// EngineFast rather than EngineURing, whose batches it cannot bound.
+	IOLimit IOLimit
+	// Nice runs the search in the background, easy on the latency of other
+	// work: with two goroutines reading directories and examining files
+	// unless Workers is set, and on Linux, pausing before each operation
+	// while the I/O pressure of the system is high, as told by pressure
+	// stall information, for longer as long as it stays high. It then
+	// reads directories with EngineFast rather than EngineURing, as
+	// IOLimit does. The scheduling priority of the process is the caller's.
+	Nice bool
+	// FS is the file system to search. Defaults to the host file system.
+	FS FS
+	// Types, if set, restricts the candidates examined to files of these
+	// types. Directories are still descended into.
+	Types []FileType
+	// MaxDepth bounds the depth below each root the search descends to:
+	// the entries of a root are at depth 1. Zero means no limit.
+	MaxDepth int
+	// MinDepth skips the candidates less deep than that below their root,
+	// which are still descended into.
+	MinDepth int
+	// MinSize and MaxSize bound the size in bytes of the candidates
+	// examined, other than directories and symbolic links, whose size is
+	// not that of a file's contents. A zero MaxSize means no limit.
+	MinSize, MaxSize int64
+	// NewerThan and OlderThan, if set, restrict the candidates examined to
+	// those modified after, or before, that time. The modification time of
+	// a symbolic link is when it was created or last changed.
+	NewerThan, OlderThan time.Time
+	// Owner and Group restrict the candidates examined to those whose
+	// owner and group they select. They select every file on Windows,
+	// where files have no numeric owner.
+	Owner, Group IDFilter
+	// Perm restricts the candidates examined to those whose permissions it
+	// selects, and DirPerm to those in a directory whose permissions it
+	// selects, such as the world-writable ones.
+	Perm, DirPerm PermFilter
+	// MinNlink restricts the candidates examined, other than directories,
+	// to those with at least that many hard links, such as the heavily
+	// linked files of backup trees. Values below 2 select every file.
+	MinNlink uint64
+	// Include, if set, restricts the candidates examined, other than
+	// directories, to the paths matching one of these patterns.
+	Include []*regexp.Regexp
+	// Exclude passes over the paths matching one of these patterns. A
+	// directory is not descended into when its path, or its path followed
+	// by a separator, matches one: '*/node_modules/*' prunes node_modules.
+	Exclude []*regexp.Regexp
+	// Gitignore passes over the paths ignored by the .gitignore and .ignore
+	// files met during the search, as git, ripgrep, and fd do. It needs an
+	// FS that implements OpenFS.
+	Gitignore bool
+	// Markers lists the names of marker files, such as CacheDirTag or
+	// ".nolfinder", whose presence in a directory below a root keeps it from
+	// being searched, as backup tools do. A CacheDirTag file marks its
+	// directory only if it holds the signature of the specification.
+	Markers []string
+	// SkipHidden passes over the files and directories whose name starts
+	// with a dot, other than the roots themselves.
+	SkipHidden bool
+	// SkipDirs lists directories whose subtrees are not searched, as paths
+	// or as patterns accepted by filepath.Match, such as
+	// "/var/lib/docker/*". A root lying below one of them is not searched
+	// either.
+	SkipDirs []string
+	// Timeout bounds the duration of each search. Zero means no limit.
+	// DirTimeout bounds the time spent walking the subtree of each
+	// directory below a root: a subtree not walked in time is abandoned,
+	// and the rest of the search goes on. A directory read that hangs, as
+	// on a dead network mount, is abandoned too. Zero means no limit.
+	Timeout, DirTimeout time.Duration
+	// OnError, if set, is called for every path that cannot be examined.
+	// It is called concurrently from several goroutines.
+	OnError func(*ScanError)
+	// OnProgress, if set, receives a Progress snapshot every
+	// ProgressInterval while a search runs and a final one when it stops.
+	OnProgress func(Progress)
+	// ProgressInterval is the time between progress snapshots.
+	// Defaults to DefaultProgressInterval.
+	ProgressInterval time.Duration
+	// Logger receives diagnostics: the start and end of each search at
+	// level Info along with skipped directories, paths that could not be
+	// examined at level Warn, and directories entered and matches at level
+	// Debug. Defaults to discarding them.
+	Logger *slog.Logger
+	// MaxResults stops the search once that many results have been
+	// produced. Zero means no limit.
+	MaxResults int
+	// Dedup drops results whose device, inode, and path were already
+	// reported during the same search.
+	Dedup bool
+	// SkipLoops passes over symbolic links caught in a loop, which cannot
+	// be resolved, instead of reporting them to OnError.
+	SkipLoops bool
+	// Follow selects the symbolic links that are followed. Defaults to
+	// FollowNever.
+	Follow FollowMode
+	// OneFileSystem does not descend into directories on another device
+	// than their search root, such as network, FUSE, or bind mounts.
+	OneFileSystem bool
+	// Firmlinks lists the directories that appear at two paths, as
+	// returned by Firmlinks. A directory is searched once, at its Path, and
+	// the paths through a firmlink compare equal when resolving links.
+	// Results record the other path to them in Result.Alias.
+	Firmlinks []Firmlink
+	// SkipNetwork does not descend into the mount points of network file
+	// systems listed in Mounts, recording them in Progress.SkippedMounts.
+	// The roots themselves are searched.
+	SkipNetwork bool
+	// ExcludeFSTypes and IncludeFSTypes select the file systems listed in
+	// Mounts that are descended into by type, with patterns as accepted by
+	// path.Match such as "fuse.*": the mount points of the file systems of
+	// a type matching ExcludeFSTypes, or if set matching no IncludeFSTypes,
+	// are passed over as with SkipNetwork.
+	ExcludeFSTypes, IncludeFSTypes []string
+	// Mounts lists the file systems mounted on the host, as returned by
+	// Mounts. When a file system is bind mounted at several paths, a file
+	// reached through more than one of them is reported once, preferably at
+	// the path through its first mount, the paths compare equal when
+	// resolving links, and results reached through a bind mount record it
+	// in Result.BindMount.
+	Mounts []Mount
+
+	// visitDir, if set, is called for every directory entered, which is
+	// not descended into if it reports false. It is called concurrently.
+	visitDir func(path string, d fs.DirEntry) bool
+}
+
+// Report is the outcome of Find.
+type Report struct {
+	// Results lists every link found.
+	Results []Result
+	// Errors collects the paths that could not be examined.
+	Errors *ErrorReport
+}
+
+// Find walks the trees below the roots and reports every link that refers to
+// one of targets, along with the paths that could not be examined. All the
+// targets are matched during a single walk; each Result records the target
+// it refers to. The walk stops early when ctx is cancelled or f.Timeout
+// elapses, in which case the report of what was found so far is returned
+// together with the context's error.
+func (f *Finder) Find(ctx context.Context, targets ...string) (*Report, error) {
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+
+	m, err := f.targetMatcher(targets)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Errors: &ErrorReport{}}
+	onError := func(err *ScanError) {
+		report.Errors.Add(err)
+		if f.OnError != nil {
+			f.OnError(err)
+		}
+	}
+	for result := range f.scan(ctx, m, onError) {
+		report.Results = append(report.Results, result)
+	}
+	return report, ctx.Err()
+}
+
+// Results starts a search for links to targets and returns a sequence that
+// yields each match as soon as a worker finds it. The targets are validated
+// before Results returns; the tree is only walked while the sequence is
+// being ranged over. Breaking out of the loop stops the walk.
+func (f *Finder) Results(ctx context.Context, targets ...string) (iter.Seq[Result], error) {
+	m, err := f.targetMatcher(targets)
+	if err != nil {
+		return nil, err
+	}
+	return f.Scan(ctx, m), nil
+}
+
+// Scan walks the trees below the roots and yields every path m matches. It is
+// the building block behind Results and lets callers supply their own
+// criteria, such as symlinks whose destination contains a given string.
+// Paths that cannot be examined are passed to f.OnError.
+func (f *Finder) Scan(ctx context.Context, m Matcher) iter.Seq[Result] {
+	return f.scan(ctx, m, nil)
+}
+
+// scan implements Scan, reporting failures to onError, or to the f.OnError
+// set when the walk starts if onError is nil.
+func (f *Finder) scan(ctx context.Context, m Matcher, onError func(*ScanError)) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		if onError == nil {
+			onError = f.OnError
+		}
+		if onError == nil {
+			onError = func(*ScanError) {}
+		}
+		ctx, cancel := f.withTimeout(ctx)
+		defer cancel()
+
+		results, stats := f.run(ctx, m, onError)
+		c, bounded := m.(completer)
+		n := 0
+		for result := range results {
+			n++
+			complete := bounded && c.complete(result)
+			if complete {
+				stats.complete.Store(true)
+				f.logger().Info("all links located", "matches", n)
+			}
+			if !yield(result) || n == f.MaxResults || complete {
+				cancel()
+				for range results {
+					// Drain so the workers can exit.
+				}
+				return
+			}
+		}
+	}
+}
+
+// targetMatcher returns the matcher for links to targets, restricted to the
+// kinds selected by SymlinksOnly and HardlinksOnly, and extended to links
+// into target directories by Into, to copies of the targets by Reflinks
+// and SameContent, and to shell links and Finder aliases by Shortcuts and
+// Aliases.
+func (f *Finder) targetMatcher(targets []string) (Matcher, error) {
+	switch f.Normalization {
+	case NormNone, NormNFC, NormNFD:
+	default:
+		return nil, fmt.Errorf("unknown Unicode normalization form %q", f.Normalization)
+	}
+	ts, err := newTargetSet(f.fs(), !f.HardlinksOnly, !f.SymlinksOnly, f.Follow != FollowNever, targets)
+	if err != nil {
+		return nil, err
+	}
+	ts.into = f.Into
+	if fold, norm := f.CaseInsensitive || ts.ignoresCase(), f.Normalization; fold || norm != NormNone {
+		ts.equate(func(path string) string {
+			path = norm.normalize(path)
+			if fold {
+				path = foldCase(path)
+			}
+			return path
+		})
+	}
+	if len(f.Firmlinks) > 0 {
+		ts.equate(func(path string) string { return firmlinkPath(f.Firmlinks, path) })
+	}
+	if mt := newMountTable(f.Mounts); mt != nil {
+		ts.equate(mt.primary)
+	}
+	if f.Reflinks {
+		if ts.reflinks, err = newReflinkIndex(f.fs(), targets); err != nil {
+			return nil, err
+		}
+	}
+	if f.SameContent {
+		if ts.content, err = newContentIndex(f.fs(), f.VerifyContent, targets); err != nil {
+			return nil, err
+		}
+	}
+	if f.Shortcuts || f.Aliases {
+		ofs, ok := f.fs().(OpenFS)
+		if !ok {
+			return nil, errors.New("reading shortcuts and aliases: the file system cannot open files")
+		}
+		if f.Shortcuts {
+			ts.shortcuts = ofs
+		}
+		if f.Aliases {
+			ts.aliases = ofs
+		}
+	}
+	return ts, nil
+}
+
+// withTimeout derives a context bounded by f.Timeout, if set.
+func (f *Finder) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.Timeout > 0 {
+		return context.WithTimeout(ctx, f.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// roots returns the paths to start the search from: Root and Roots without
+// duplicates or paths inside another root, or "/" if neither is set.
+func (f *Finder) roots() []string {
+	var roots []string
+	for _, root := range append([]string{f.Root}, f.Roots...) {
+		if root != "" {
+			roots = append(roots, filepath.Clean(root))
+		}
+	}
+	if len(roots) == 0 {
+		return []string{"/"}
+	}
+
+	var pruned []string
+	for i, root := range roots {
+		nested := false
+		for j, other := range roots {
+			if i != j && within(root, other) && (root != other || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			pruned = append(pruned, root)
+		}
+	}
+	return pruned
+}
+
+// within reports whether path is dir or lies below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// firmlinkAliases returns the Data side of the firmlinks whose Path lies
+// within a search root, which are passed over since their contents are
+// searched at their Path.
+func (f *Finder) firmlinkAliases() map[string]bool {
+	aliases := make(map[string]bool)
+	for _, fl := range f.Firmlinks {
+		if !fl.Synthetic && slices.ContainsFunc(f.roots(), func(root string) bool { return within(fl.Path, root) }) {
+			aliases[fl.Data] = true
+		}
+	}
+	return aliases
+}
+
+// logger returns the logger receiving diagnostics.
+func (f *Finder) logger() *slog.Logger {
+	if f.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return f.Logger
+}
+
+// queueSize returns QueueSize, or DefaultQueueSize if it is not set.
+func (f *Finder) queueSize() int {
+	if f.QueueSize <= 0 {
+		return DefaultQueueSize
+	}
+	return f.QueueSize
+}
+
+// fs returns the file system to search.
+func (f *Finder) fs() FS {
+	if f.FS == nil {
+		return OSFS{}
+	}
+	return f.FS
+}
+
+// expandChain records in r the chain of links followed to resolve the
+// symlink r if it resolves through other symlinks.
+func (s *search) expandChain(r *Result) {
+	if (r.Kind != KindSymlink && r.Kind != KindEscape) || r.Chain != nil {
+		return
+	}
+	limit := s.MaxChain
+	if limit <= 0 {
+		limit = maxSymlinks
+	}
+	if chain := linkChain(s.links, r.Path, limit); len(chain) > 2 {
+		r.Chain, r.Depth = chain, len(chain)-1
+	}
+}
+
+// job is a candidate path handed from the walker to the workers.
+type job struct {
+	path  string
+	entry fs.DirEntry
+}
+
+// search holds the state shared by the walker and workers of one run.
+type search struct {
+	*Finder
+	fsys    FS
+	links   FS // fsys, resolving links through the cache of the search
+	m       Matcher
+	fields  statFields // attributes of the candidates read
+	onError func(*ScanError)
+	stats   *counters
+	seen    *resultSet       // nil unless Dedup is set
+	aliases map[string]bool  // firmlinked directories searched at their Path
+	binds   *mountTable      // nil unless a file system is mounted twice
+	bound   *resultSet       // results seen through bind mounts
+	pruned  map[string]Mount // mount points not descended into
+	pools   *pools
+	memory  *memoryGate // nil unless MaxMemory is set
+	io      *ioGate     // nil unless IOLimit is set
+	log     *slog.Logger
+}
+
+// completer is implemented by matchers that can tell when no further match
+// is possible, so that the search can stop early.
+type completer interface {
+	// complete records that r was produced and reports whether every
+	// possible match has been.
+	complete(r Result) bool
+}
+
+// run starts the walk and the worker pools and returns the channel the
+// workers send matches on, along with the counters of the search. The channel
+// is closed once every worker is done.
+func (f *Finder) run(ctx context.Context, m Matcher, onError func(*ScanError)) (<-chan Result, *counters) {
+	roots := f.roots()
+	numWorkers := f.Workers
+	if numWorkers <= 0 {
+		numWorkers = f.defaultWorkers(roots)
+	}
+
+	// Links are resolved through a cache of the canonical directories for
+	// the duration of the search.
+	cache := linkCaches()
+	s := &search{Finder: f, fsys: f.fs(), m: cacheLinks(m, cache), stats: newCounters(), log: f.logger()}
+	s.links = cache(s.fsys)
+	s.fields = s.statFields()
+	s.onError = func(err *ScanError) {
+		s.stats.errors.Add(1)
+		s.log.Warn("cannot examine path", "path", err.Path, "op", err.Op, "category", err.Category, "err", err.Err)
+		onError(err)
+	}
+	if f.Dedup {
+		s.seen = &resultSet{}
+	}
+	s.aliases = f.firmlinkAliases()
+	if s.binds = newMountTable(f.Mounts); s.binds != nil {
+		s.bound = &resultSet{}
+	}
+	s.pruned = f.prunedMounts()
+	s.io = newIOGate(f.IOLimit, ctx.Done())
+	if f.Nice {
+		s.watchPressure(ctx.Done())
+	}
+
+	results := make(chan Result, f.queueSize())
+	var wg sync.WaitGroup
+	walked := make(chan struct{})
+	s.pools = s.newPools(roots, numWorkers, results, &wg, walked)
+	if f.MaxMemory > 0 {
+		s.memory = &memoryGate{limit: uint64(f.MaxMemory)}
+		go s.memory.watch(walked)
+	}
+
+	stopProgress := make(chan struct{})
+	var progress sync.WaitGroup
+	if f.OnProgress != nil {
+		interval := f.ProgressInterval
+		if interval <= 0 {
+			interval = DefaultProgressInterval
+		}
+		progress.Go(func() { s.stats.report(f.OnProgress, interval, stopProgress) })
+	}
+
+	s.log.Info("search started", "roots", roots, "workers", numWorkers)
+	wg.Go(func() {
+		s.walk(ctx, roots, numWorkers)
+		s.pools.close()
+		close(walked)
+	})
+
+	go func() {
+		wg.Wait()
+		p := s.stats.snapshot()
+		attrs := []any{"dirs", p.DirsVisited, "files", p.FilesExamined,
+			"matches", p.Matches, "errors", p.Errors, "elapsed", p.Elapsed}
+		if err := ctx.Err(); err != nil {
+			attrs = append(attrs, "stopped", err)
+		}
+		s.log.Info("search finished", attrs...)
+		close(stopProgress)
+		progress.Wait()
+		close(results)
+	}()
+
+	return results, s.stats
+}
+
+// walk enumerates the trees below roots with workers goroutines and sends
+// to the worker pools every entry that is selected and whose type the
+// matcher can match.
+func (s *search) walk(ctx context.Context, roots []string, workers int) {
+	// rootDevs holds the device of each root, with OneFileSystem.
+	rootDevs := make(map[string]*uint64, len(roots))
+	for _, root := range roots {
+		rootDevs[root] = new(uint64)
+	}
+	var ig *ignores
+	if ofs, ok := s.fsys.(OpenFS); ok && s.Gitignore {
+		ig = newIgnores(ofs)
+	}
+	// permitted holds the directories selected by DirPerm.
+	var permitted *sync.Map
+	if s.DirPerm.How != PermNone {
+		permitted = new(sync.Map)
+		for _, root := range roots {
+			if info, err := s.fsys.Lstat(filepath.Dir(root)); err == nil && s.DirPerm.Match(info.Mode()) {
+				permitted.Store(filepath.Dir(root), true)
+			}
+		}
+	}
+	var budget *dirBudget
+	if s.DirTimeout > 0 {
+		budget = &dirBudget{timeout: s.DirTimeout, abandon: func(path string) {
+			s.log.Warn("abandoning directory after timeout", "path", path, "timeout", s.DirTimeout)
+			s.stats.abandoned.Add(1)
+		}}
+	}
+	fsys, release := s.walkFS(s.fsys, workers)
+	defer release()
+	if s.io != nil {
+		fsys = gatedFS{fsys, s.io}
+	}
+	walkTrees(fsys, roots, s.Follow, workers, budget, s.pools.label, func(root, path string, d fs.DirEntry, label any, err error) error {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			op := "readdir"
+			if d == nil {
+				op = "lstat"
+			}
+			s.onError(newScanError(path, op, err))
+			return nil
+		}
+		if d.IsDir() {
+			if s.skipDir(root, path) {
+				s.log.Info("skipping directory", "path", path)
+				s.stats.skipped.Add(1)
+				return filepath.SkipDir
+			}
+			if path != root && s.excluded(path, true) {
+				s.log.Info("skipping excluded directory", "path", path)
+				s.stats.skipped.Add(1)
+				return filepath.SkipDir
+			}
+			if s.SkipHidden && path != root && hidden(path) {
+				s.log.Info("skipping hidden directory", "path", path)
+				s.stats.skipped.Add(1)
+				return filepath.SkipDir
+			}
+			if ig != nil && path != root && ig.ignored(root, path, true) {
+				s.log.Info("skipping ignored directory", "path", path)
+				s.stats.skipped.Add(1)
+				return filepath.SkipDir
+			}
+			if m, ok := s.pruned[path]; ok && path != root {
+				s.log.Info("skipping mount point", "path", path, "type", m.Type)
+				s.stats.skipped.Add(1)
+				s.stats.skipMount(path)
+				return filepath.SkipDir
+			}
+			if len(s.Markers) > 0 && path != root {
+				if marker, ok := s.tagged(path); ok {
+					s.log.Info("skipping tagged directory", "path", path, "marker", marker)
+					s.stats.skipped.Add(1)
+					return filepath.SkipDir
+				}
+			}
+			if s.aliases[path] && path != root {
+				s.log.Info("skipping firmlink", "path", path)
+				s.stats.skipped.Add(1)
+				return filepath.SkipDir
+			}
+			if s.OneFileSystem {
+				if info, err := d.Info(); err == nil {
+					dev, _, ok := fileID(info)
+					rootDev := rootDevs[root]
+					switch {
+					case !ok:
+					case path == root:
+						*rootDev = dev
+					case dev != *rootDev:
+						s.log.Info("skipping mount point", "path", path)
+						s.stats.skipped.Add(1)
+						return filepath.SkipDir
+					}
+				}
+			}
+			if s.visitDir != nil && !s.visitDir(path, d) {
+				return filepath.SkipDir
+			}
+			s.log.Debug("entering directory", "path", path)
+			s.stats.dirs.Add(1)
+			s.stats.current.Store(&path)
+			if ig != nil {
+				ig.load(path)
+			}
+			if permitted != nil {
+				if info, err := d.Info(); err == nil && s.DirPerm.Match(info.Mode()) {
+					permitted.Store(path, true)
+				}
+			}
+		}
+		// A directory at MaxDepth is examined but not descended into.
+		depth := depthBelow(root, path)
+		var next error
+		if d.IsDir() && s.MaxDepth > 0 && depth >= s.MaxDepth {
+			next = filepath.SkipDir
+		}
+		if depth < s.MinDepth {
+			return next
+		}
+		if len(s.Types) > 0 && !slices.Contains(s.Types, FileTypeOf(d.Type())) {
+			return next
+		}
+		if permitted != nil {
+			if _, ok := permitted.Load(filepath.Dir(path)); !ok {
+				return next
+			}
+		}
+		// Entries read from a directory have the type Lstat gives; the
+		// others, roots and followed links, are left to the workers.
+		if _, ok := d.(*cachedEntry); ok && !matchesType(s.m, FileTypeOf(d.Type())) {
+			if !d.IsDir() {
+				s.stats.files.Add(1)
+			}
+			return next
+		}
+		if !d.IsDir() && (s.excluded(path, false) || s.SkipHidden && hidden(path) || ig != nil && ig.ignored(root, path, false)) {
+			return next
+		}
+		s.throttle(ctx)
+		select {
+		case s.pools.pool(label).jobs <- job{path: path, entry: d}:
+		case <-ctx.Done():
+			return filepath.SkipAll
+		}
+		return next
+	})
+}
+
+// prunedMounts returns the mount points of Mounts not to descend into, by
+// path. A mount hides the earlier ones at the same mount point.
+func (f *Finder) prunedMounts() map[string]Mount {
+	if !f.SkipNetwork && len(f.ExcludeFSTypes) == 0 && len(f.IncludeFSTypes) == 0 {
+		return nil
+	}
+	top := make(map[string]Mount, len(f.Mounts))
+	for _, m := range f.Mounts {
+		top[m.Path] = m
+	}
+	pruned := make(map[string]Mount)
+	for path, m := range top {
+		if f.SkipNetwork && m.Network() || m.typeMatches(f.ExcludeFSTypes) ||
+			len(f.IncludeFSTypes) > 0 && !m.typeMatches(f.IncludeFSTypes) {
+			pruned[path] = m
+		}
+	}
+	return pruned
+}
+
+// depthBelow returns the number of path components of path below root, which
+// contains it.
+func depthBelow(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// skipDir reports whether the directory path, found below root, is listed
+// in SkipDirs: whether it matches one of them, or for a root, whether it or
+// one of its parents does.
+func (s *search) skipDir(root, path string) bool {
+	return slices.ContainsFunc(s.SkipDirs, func(skip string) bool {
+		if matchDir(skip, path) {
+			return true
+		}
+		if path != root {
+			return false
+		}
+		for p := filepath.Dir(path); ; p = filepath.Dir(p) {
+			if matchDir(skip, p) {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				return false
+			}
+		}
+	})
+}
+
+// matchDir reports whether dir is the directory skip or matches it as a
+// pattern.
+func matchDir(skip, dir string) bool {
+	ok, _ := filepath.Match(skip, dir)
+	return ok || skip == dir
+}
+
+// hidden reports whether the name of path starts with a dot, hiding it from
+// ls and fd by default.
+func hidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+// wanted reports whether the candidate described by info is within MinSize
+// and MaxSize, and NewerThan and OlderThan, selected by Perm, Owner, and
+// Group, and linked at least MinNlink times.
+func (s *search) wanted(info fs.FileInfo) bool {
+	if !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 &&
+		(info.Size() < s.MinSize || s.MaxSize > 0 && info.Size() > s.MaxSize) {
+		return false
+	}
+	mtime := info.ModTime()
+	if !s.NewerThan.IsZero() && !mtime.After(s.NewerThan) || !s.OlderThan.IsZero() && !mtime.Before(s.OlderThan) {
+		return false
+	}
+	if !s.Perm.Match(info.Mode()) {
+		return false
+	}
+	if s.MinNlink > 1 && !info.IsDir() {
+		if nlink, _, _, ok := fileOwner(info); ok && nlink < s.MinNlink {
+			return false
+		}
+	}
+	if hasOwnerIDs && (len(s.Owner.IDs) > 0 || len(s.Group.IDs) > 0) {
+		if _, uid, gid, ok := fileOwner(info); ok {
+			return s.Owner.Match(uid) && s.Group.Match(gid)
+		}
+	}
+	return true
+}
+
+// excluded reports whether the path of a candidate is excluded by Exclude,
+// or for files, not included by Include.
+func (s *search) excluded(path string, dir bool) bool {
+	matches := func(re *regexp.Regexp) bool { return re.MatchString(path) }
+	if slices.ContainsFunc(s.Exclude, matches) {
+		return true
+	}
+	if dir {
+		sep := path + string(filepath.Separator)
+		return slices.ContainsFunc(s.Exclude, func(re *regexp.Regexp) bool { return re.MatchString(sep) })
+	}
+	return len(s.Include) > 0 && !slices.ContainsFunc(s.Include, matches)
+}
+
+// bindMount records the bind mount r was reached through, if any, and
+// reports whether r is to be reported: a file reached through a bind mount
+// is reported at its primary path when a search root contains it, and
+// otherwise once, however reached.
+func (s *search) bindMount(r *Result) bool {
+	if m, _, ok := s.binds.locate(r.Path); ok && s.binds.bind(m) {
+		primary := s.binds.primary(r.Path)
+		if primary != r.Path && slices.ContainsFunc(s.roots(), func(root string) bool { return within(primary, root) }) {
+			s.log.Debug("skipping duplicate through bind mount", "path", r.Path, "primary", primary)
+			return false
+		}
+		r.BindMount = m.Path
+	}
+	if !s.bound.addKey(s.binds.key(*r)) {
+		s.log.Debug("skipping duplicate through bind mount", "path", r.Path)
+		return false
+	}
+	return true
+}
+
+// lstat returns the Lstat of the entry of j: the Info of the entry when the
+// walker read it from its directory, sparing a second call, or else that of
+// its path, for roots and followed links.
+func (s *search) lstat(wp *workerPool, j job) (fs.FileInfo, error) {
+	if wp.clock != nil {
+		defer wp.clock.record(time.Now())
+	}
+	if e, ok := j.entry.(*cachedEntry); ok {
+		return e.Info()
+	}
+	return s.fsys.Lstat(j.path)
+}
+
+// worker examines the candidates sent to the pool wp and sends every match
+// on results, until its jobs are closed or it is retired.
+func (s *search) worker(wp *workerPool, results chan<- Result) {
+	for {
+		var j job
+		select {
+		case <-wp.quit:
+			return
+		case next, ok := <-wp.jobs:
+			if !ok {
+				return
+			}
+			j = next
+		}
+		if !j.entry.IsDir() {
+			s.stats.files.Add(1)
+		}
+		release := s.io.acquire()
+		fileInfo, err := s.lstat(wp, j)
+		if err != nil {
+			release()
+			s.onError(newScanError(j.path, "lstat", err))
+			continue
+		}
+		if !s.wanted(fileInfo) {
+			release()
+			continue
+		}
+		result, ok, err := s.m.Match(j.path, j.entry, fileInfo)
+		release()
+		var loop *LoopError
+		if s.SkipLoops && errors.As(err, &loop) {
+			continue
+		}
+		if err != nil {
+			s.onError(newScanError(j.path, "match", err))
+			continue
+		}
+		if !ok || (s.LinkType != "" && result.LinkType() != s.LinkType) || (s.seen != nil && !s.seen.add(result)) {
+			continue
+		}
+		if s.binds != nil && !s.bindMount(&result) {
+			continue
+		}
+		if lacks(fileInfo, statAll) {
+			s.restat(&result)
+		}
+		s.expandChain(&result)
+		if len(s.Firmlinks) > 0 {
+			result.Alias = firmlinkAlias(s.Firmlinks, result.Path)
+		}
+		s.log.Debug("match", "path", result.Path, "kind", result.Kind)
+		s.stats.match(result.Kind)
+		results <- result
+	}
+}
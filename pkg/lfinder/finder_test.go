@@ -0,0 +1,205 @@
+package lfinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// linkTree is a tree of symbolic links: chains, absolute and relative
+// destinations, a dangling link, a loop, and a link leaving dir.
+var linkTree = fstest.MapFS{
+	"etc/hosts":  {Data: []byte("127.0.0.1 localhost\n")},
+	"etc/link":   {Data: []byte("hosts"), Mode: fs.ModeSymlink},
+	"abs":        {Data: []byte("/etc/hosts"), Mode: fs.ModeSymlink},
+	"chain":      {Data: []byte("etc/link"), Mode: fs.ModeSymlink},
+	"broken":     {Data: []byte("missing"), Mode: fs.ModeSymlink},
+	"loop":       {Data: []byte("loop"), Mode: fs.ModeSymlink},
+	"dir/escape": {Data: []byte("../etc/hosts"), Mode: fs.ModeSymlink},
+	"dir/inside": {Data: []byte("file"), Mode: fs.ModeSymlink},
+	"dir/file":   {Data: []byte("x")},
+}
+
+// found returns the path, kind, and target of each result, sorted.
+func found(results []Result) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, fmt.Sprintf("%s %s %s", r.Path, r.Kind, r.Target))
+	}
+	slices.Sort(s)
+	return s
+}
+
+// findIn searches linkTree from root for the links to targets.
+func findIn(t *testing.T, root string, targets []string, opts ...Option) []string {
+	t.Helper()
+	f := NewFinder(append([]Option{WithFS(FromFS(linkTree)), WithRoot(root)}, opts...)...)
+	report, err := f.Find(context.Background(), targets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found(report.Results)
+}
+
+func TestFromFS(t *testing.T) {
+	fsys := FromFS(linkTree)
+	info, err := fsys.Lstat("chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("Lstat(chain) has mode %v, want a symlink", info.Mode())
+	}
+	if dest, err := fsys.Readlink("chain"); err != nil || dest != "etc/link" {
+		t.Errorf("Readlink(chain) = %q, %v; want etc/link", dest, err)
+	}
+	// Absolute destinations resolve from the root of the tree.
+	for _, name := range []string{"chain", "abs", "dir/escape"} {
+		info, err := fsys.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat(%s): %v", name, err)
+		}
+		if info.Size() != int64(len(linkTree["etc/hosts"].Data)) {
+			t.Errorf("Stat(%s) has size %d, want that of etc/hosts", name, info.Size())
+		}
+		if path, err := fsys.EvalSymlinks(name); err != nil || path != "etc/hosts" {
+			t.Errorf("EvalSymlinks(%s) = %q, %v; want etc/hosts", name, path, err)
+		}
+	}
+	if _, err := fsys.Stat("broken"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(broken) = %v, want fs.ErrNotExist", err)
+	}
+	entries, err := fsys.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"escape", "file", "inside"}; !slices.Equal(names, want) {
+		t.Errorf("ReadDir(dir) = %q, want %q", names, want)
+	}
+}
+
+// TestFindFS checks that a search goes through the FS it is given, here a
+// tree in memory.
+func TestFindFS(t *testing.T) {
+	want := []string{
+		"abs symlink etc/hosts",
+		"chain symlink etc/hosts",
+		"dir/escape symlink etc/hosts",
+		"etc/link symlink etc/hosts",
+	}
+	if got := findIn(t, ".", []string{"etc/hosts"}); !slices.Equal(got, want) {
+		t.Errorf("found %q, want %q", got, want)
+	}
+	want = []string{"dir/escape symlink etc/hosts"}
+	if got := findIn(t, "dir", []string{"etc/hosts"}); !slices.Equal(got, want) {
+		t.Errorf("below dir, found %q, want %q", got, want)
+	}
+	if got := findIn(t, "etc", []string{"dir/file"}); len(got) != 0 {
+		t.Errorf("below etc, found %q, want nothing", got)
+	}
+}
+
+func TestFindMissingTarget(t *testing.T) {
+	f := NewFinder(WithFS(FromFS(linkTree)), WithRoot("."))
+	if _, err := f.Find(context.Background(), "missing"); err == nil {
+		t.Error("Find(missing) succeeded")
+	}
+}
+
+// TestFindTargets checks that a search for several targets reports each
+// link with the target it leads to, or passes through.
+func TestFindTargets(t *testing.T) {
+	got := findIn(t, ".", []string{"dir/file", "etc/link"})
+	// Only chain leads through etc/link; the other links to etc/hosts do not.
+	want := []string{
+		"chain symlink etc/link",
+		"dir/inside symlink dir/file",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("found %q, want %q", got, want)
+	}
+}
+
+// TestFindInto checks that WithInto finds the links to anything below a
+// directory, reported with the directory as their target.
+func TestFindInto(t *testing.T) {
+	if got, want := findIn(t, ".", []string{"dir"}, WithInto()), []string{"dir/inside symlink dir"}; !slices.Equal(got, want) {
+		t.Errorf("into dir, found %q, want %q", got, want)
+	}
+	want := []string{
+		"abs symlink etc",
+		"chain symlink etc",
+		"dir/escape symlink etc",
+		"etc/link symlink etc",
+	}
+	if got := findIn(t, ".", []string{"etc"}, WithInto()); !slices.Equal(got, want) {
+		t.Errorf("into etc, found %q, want %q", got, want)
+	}
+}
+
+func TestDepthBelow(t *testing.T) {
+	for _, tt := range []struct {
+		root, path string
+		want       int
+	}{
+		{"/srv", "/srv", 0},
+		{"/srv", "/srv/a", 1},
+		{"/srv", "/srv/a/b", 2},
+		{"/srv/", "/srv/a", 1},
+		{"/", "/a/b", 2},
+		{".", ".", 0},
+		{".", "a", 1},
+		{".", "a/b", 2},
+		{"./", "a", 1},
+		{"a/", "a/b/c", 2},
+	} {
+		root, path := filepath.FromSlash(tt.root), filepath.FromSlash(tt.path)
+		if got := depthBelow(root, path); got != tt.want {
+			t.Errorf("depthBelow(%q, %q) = %d, want %d", root, path, got, tt.want)
+		}
+	}
+}
+
+// TestMaxDepth checks that the depth of the links found is counted from the
+// root as given, whether "." or one ending with a separator.
+func TestMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, dest := range map[string]string{"l": "target", "a/l": "../target", "a/b/l": "../../target"} {
+		if err := os.Symlink(dest, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	t.Chdir(dir)
+
+	for _, root := range []string{".", "." + string(filepath.Separator), dir, dir + string(filepath.Separator)} {
+		f := NewFinder(WithRoot(root), WithSymlinksOnly(), WithDepth(2, 2))
+		report, err := f.Find(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range report.Results {
+			got = append(got, filepath.Base(filepath.Dir(r.Path)))
+		}
+		if want := []string{"a"}; !slices.Equal(got, want) {
+			t.Errorf("root %q: found links in %q, want %q", root, got, want)
+		}
+	}
+}
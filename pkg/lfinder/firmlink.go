@@ -0,0 +1,109 @@
+package lfinder
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Firmlink is a directory that appears at two paths on the host, as the
+// firmlinks of macOS 10.15 and later join the read-only system volume to
+// the data volume: /Users is also /System/Volumes/Data/Users. Entries of
+// /etc/synthetic.conf naming a target are recorded too, since the symbolic
+// links they create at the root make another path to their target.
+type Firmlink struct {
+	// Path is the path the directory is known by, such as /Users.
+	Path string
+	// Data is the other path to the directory, such as
+	// /System/Volumes/Data/Users.
+	Data string
+	// Synthetic marks a symbolic link created from /etc/synthetic.conf
+	// rather than a firmlink. Path is the link and Data its destination.
+	Synthetic bool
+}
+
+// Firmlinks returns the firmlinks and synthetic links of the host. It is
+// supported on macOS.
+func Firmlinks() ([]Firmlink, error) {
+	return firmlinks()
+}
+
+// dataVolume is the mount point of the data volume on macOS.
+const dataVolume = "/System/Volumes/Data"
+
+// parseFirmlinks reads /usr/share/firmlinks, whose lines hold the path of
+// a firmlink and its location on the data volume, separated by a tab:
+//
+//	/Users	Users
+func parseFirmlinks(r io.Reader) ([]Firmlink, error) {
+	var list []Firmlink
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		path, data, ok := strings.Cut(sc.Text(), "\t")
+		if !ok || !filepath.IsAbs(path) {
+			continue
+		}
+		list = append(list, Firmlink{Path: filepath.Clean(path), Data: filepath.Join(dataVolume, data)})
+	}
+	return list, sc.Err()
+}
+
+// parseSynthetic reads /etc/synthetic.conf, whose lines hold the name of
+// an entry created at the root and, for a symbolic link, its destination,
+// relative to the root, separated by a tab. Entries without a destination
+// are empty directories and are left out, as are comments.
+func parseSynthetic(r io.Reader) ([]Firmlink, error) {
+	var list []Firmlink
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, dest, ok := strings.Cut(line, "\t")
+		dest = strings.TrimSpace(dest)
+		if !ok || name == "" || dest == "" {
+			continue
+		}
+		list = append(list, Firmlink{Path: filepath.Join("/", name), Data: filepath.Join("/", dest), Synthetic: true})
+	}
+	return list, sc.Err()
+}
+
+// firmlinkPath returns the path by which the file at path is known: path
+// with the Data side of a firmlink replaced by its Path, so that the two
+// paths to a file compare equal. Synthetic links are resolved like any
+// symbolic link and are left alone.
+func firmlinkPath(firmlinks []Firmlink, path string) string {
+	for _, fl := range firmlinks {
+		if rel, ok := below(path, fl.Data); ok && !fl.Synthetic {
+			return filepath.Join(fl.Path, rel)
+		}
+	}
+	return path
+}
+
+// firmlinkAlias returns the other path to the file at path through a
+// firmlink, or "" if it has none. A synthetic link is not followed, since
+// the path of the link is the link itself.
+func firmlinkAlias(firmlinks []Firmlink, path string) string {
+	for _, fl := range firmlinks {
+		if rel, ok := below(path, fl.Path); ok && !fl.Synthetic {
+			return filepath.Join(fl.Data, rel)
+		}
+		if rel, ok := below(path, fl.Data); ok {
+			return filepath.Join(fl.Path, rel)
+		}
+	}
+	return ""
+}
+
+// below returns path relative to dir if it is dir or lies below it.
+func below(path, dir string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,33 @@
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// firmlinks reads the firmlinks of the system volume from
+// /usr/share/firmlinks, and the synthetic links from /etc/synthetic.conf if
+// it exists.
+func firmlinks() ([]Firmlink, error) {
+	f, err := os.Open("/usr/share/firmlinks")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list, err := parseFirmlinks(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := os.Open("/etc/synthetic.conf")
+	if errors.Is(err, fs.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conf.Close()
+	synthetic, err := parseSynthetic(conf)
+	return append(list, synthetic...), err
+}
@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package lfinder
+
+import "errors"
+
+// firmlinks is not implemented on this platform, which has no firmlinks.
+func firmlinks() ([]Firmlink, error) {
+	return nil, errors.ErrUnsupported
+}
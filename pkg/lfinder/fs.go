@@ -0,0 +1,282 @@
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FS is the file system a Finder searches. Paths passed to its methods are
+// built with filepath.Join from Finder.Root and the names returned by ReadDir.
+type FS interface {
+	// Stat returns the FileInfo for name, following symbolic links.
+	Stat(name string) (fs.FileInfo, error)
+	// Lstat returns the FileInfo for name without following symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of the named directory. The Info of an
+	// entry must be that Lstat returns for it, which it stands in for.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Readlink returns the destination of the named symbolic link.
+	Readlink(name string) (string, error)
+	// EvalSymlinks returns name after resolving every symbolic link in it.
+	EvalSymlinks(name string) (string, error)
+}
+
+// OpenFS is an FS whose files can be read, which comparing file contents
+// requires. OSFS, ChrootFS, and the file systems returned by FromFS
+// implement it.
+type OpenFS interface {
+	FS
+	// Open opens the named file for reading.
+	Open(name string) (fs.File, error)
+}
+
+// RemoveFS is an FS whose files can be removed, which deleting the links
+// found requires. OSFS and ChrootFS implement it.
+type RemoveFS interface {
+	FS
+	// Remove removes the named file, or the link itself for a symbolic
+	// link.
+	Remove(name string) error
+}
+
+// OSFS is the FS backed by the host operating system. It is used when
+// Finder.FS is nil. On Windows, NTFS junctions are treated as symbolic links.
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)      { return stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error)     { return lstat(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) { return readDir(name) }
+func (OSFS) Readlink(name string) (string, error)       { return readlink(name) }
+func (OSFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (OSFS) Remove(name string) error                   { return os.Remove(name) }
+
+// EvalSymlinks returns the absolute path of name after resolving every
+// symbolic link in it, so that paths reached from relative and absolute
+// search roots compare equal.
+func (OSFS) EvalSymlinks(name string) (string, error) {
+	resolved, err := evalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// maxSymlinks bounds symlink resolution in FromFS, matching the Linux limit.
+const maxSymlinks = 255
+
+// ChrootFS is the host file system as seen from Root, such as an extracted
+// image or a mounted rescue file system: below Root, absolute symbolic link
+// destinations are resolved from Root rather than from the host root, and
+// ".." never leads above Root. Paths remain host paths; those outside Root
+// are accessed as with OSFS. Root must be absolute and free of symbolic
+// links, as returned by OSFS.EvalSymlinks.
+type ChrootFS struct {
+	Root string
+}
+
+func (c ChrootFS) Stat(name string) (fs.FileInfo, error) {
+	resolved, err := c.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	return stat(resolved)
+}
+
+func (c ChrootFS) Lstat(name string) (fs.FileInfo, error) { return lstat(c.host(name)) }
+func (c ChrootFS) Readlink(name string) (string, error)   { return readlink(c.host(name)) }
+func (c ChrootFS) Remove(name string) error               { return os.Remove(c.host(name)) }
+
+func (c ChrootFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, err := c.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	return readDir(resolved)
+}
+
+func (c ChrootFS) Open(name string) (fs.File, error) {
+	resolved, err := c.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(resolved)
+}
+
+// host returns the host path of name with its directory resolved from Root,
+// leaving its last element alone.
+func (c ChrootFS) host(name string) string {
+	dir, err := c.EvalSymlinks(filepath.Dir(name))
+	if err != nil {
+		return name
+	}
+	return filepath.Join(dir, filepath.Base(name))
+}
+
+// inside returns name as an absolute path relative to Root, or false if name
+// is not below Root.
+func (c ChrootFS) inside(name string) (string, bool) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(c.Root, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.Join(string(filepath.Separator), rel), true
+}
+
+// linkDest returns the host path the destination dest of a symbolic link
+// in the directory dir refers to.
+func (c ChrootFS) linkDest(dir, dest string) string {
+	inner, ok := c.inside(dir)
+	if !ok {
+		return osLinkDest(dir, dest)
+	}
+	if filepath.IsAbs(dest) {
+		inner = string(filepath.Separator)
+	}
+	return filepath.Join(c.Root, filepath.Join(inner, dest))
+}
+
+// EvalSymlinks returns the host path of name after resolving every symbolic
+// link in it from Root, one component at a time.
+func (c ChrootFS) EvalSymlinks(name string) (string, error) {
+	inner, ok := c.inside(name)
+	if !ok {
+		return OSFS{}.EvalSymlinks(name)
+	}
+	sep := string(filepath.Separator)
+	resolved := sep
+	rest := strings.Split(inner, sep)
+	links := 0
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, elem)
+		info, err := lstat(filepath.Join(c.Root, next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{Op: "EvalSymlinks", Path: name, Err: errors.New("too many links")}
+		}
+		dest, err := readlink(filepath.Join(c.Root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(dest) {
+			resolved = sep
+		}
+		rest = append(strings.Split(dest, sep), rest...)
+	}
+	return filepath.Join(c.Root, resolved), nil
+}
+
+// osLinkDest returns the path the destination dest of a symbolic link in
+// the directory dir refers to on the host.
+func osLinkDest(dir, dest string) string {
+	if filepath.IsAbs(dest) {
+		return filepath.Clean(dest)
+	}
+	return filepath.Join(dir, dest)
+}
+
+// FromFS adapts an io/fs file system, such as an fstest.MapFS or an embedded
+// tree, to FS. Symbolic links are supported when fsys implements
+// fs.ReadLinkFS; absolute link destinations are resolved from the root of fsys.
+// Search roots and targets must be valid fs.FS path names (e.g. "." or "etc/hosts").
+func FromFS(fsys fs.FS) FS {
+	return ioFS{fsys}
+}
+
+type ioFS struct {
+	fsys fs.FS
+}
+
+// Stat resolves name with EvalSymlinks, as fs.Stat does not resolve
+// absolute link destinations.
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	resolved, err := f.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.fsys, resolved)
+}
+
+func (f ioFS) Lstat(name string) (fs.FileInfo, error) {
+	return fs.Lstat(f.fsys, filepath.ToSlash(name))
+}
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.fsys, filepath.ToSlash(name))
+}
+
+func (f ioFS) Readlink(name string) (string, error) {
+	return fs.ReadLink(f.fsys, filepath.ToSlash(name))
+}
+
+func (f ioFS) Open(name string) (fs.File, error) {
+	return f.fsys.Open(filepath.ToSlash(name))
+}
+
+// EvalSymlinks resolves name one component at a time, substituting the
+// destination of each symbolic link it meets.
+func (f ioFS) EvalSymlinks(name string) (string, error) {
+	resolved := "."
+	rest := strings.Split(filepath.ToSlash(name), "/")
+	links := 0
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, elem)
+		info, err := fs.Lstat(f.fsys, next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{Op: "EvalSymlinks", Path: name, Err: errors.New("too many links")}
+		}
+		dest, err := fs.ReadLink(f.fsys, next)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(dest) {
+			resolved = "."
+		}
+		rest = append(strings.Split(dest, "/"), rest...)
+	}
+	return resolved, nil
+}
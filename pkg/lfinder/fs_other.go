@@ -0,0 +1,17 @@
+//go:build !windows
+
+package lfinder
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// On platforms other than Windows, the os package reports every kind of
+// symbolic link as such.
+func stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func readDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func readlink(name string) (string, error)       { return os.Readlink(name) }
+func evalSymlinks(name string) (string, error)   { return filepath.EvalSymlinks(name) }
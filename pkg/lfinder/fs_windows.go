@@ -0,0 +1,242 @@
+package lfinder
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// Reparse point tags and flags, from winnt.h.
+const (
+	ioReparseTagMountPoint = 0xA0000003
+	ioReparseTagSymlink    = 0xA000000C
+	symlinkFlagRelative    = 0x1
+
+	// errorCantResolveFilename is returned when opening a path whose
+	// reparse points loop.
+	errorCantResolveFilename syscall.Errno = 1921
+)
+
+var procGetFinalPathNameByHandleW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetFinalPathNameByHandleW")
+
+// fileInfo is the FileInfo of a file on the host. It reports NTFS
+// junctions (mount points), which the os package reports as irregular
+// files, as symbolic links so that they are matched like them, and reads the
+// identity of the file, which os.Lstat does not expose, on first use.
+type fileInfo struct {
+	fs.FileInfo
+	path     string
+	follow   bool
+	junction bool
+
+	once   sync.Once
+	handle *syscall.ByHandleFileInformation
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.junction {
+		return i.FileInfo.Mode()&^fs.ModeIrregular | fs.ModeSymlink
+	}
+	return i.FileInfo.Mode()
+}
+
+// handleInfo returns the information GetFileInformationByHandle reports
+// for the file, or nil if it cannot be opened.
+func (i *fileInfo) handleInfo() *syscall.ByHandleFileInformation {
+	i.once.Do(func() {
+		flags := uint32(syscall.FILE_FLAG_BACKUP_SEMANTICS)
+		if !i.follow {
+			flags |= syscall.FILE_FLAG_OPEN_REPARSE_POINT
+		}
+		h, err := openHandle(i.path, flags)
+		if err != nil {
+			return
+		}
+		defer syscall.CloseHandle(h)
+		var d syscall.ByHandleFileInformation
+		if syscall.GetFileInformationByHandle(h, &d) == nil {
+			i.handle = &d
+		}
+	})
+	return i.handle
+}
+
+// openHandle opens name without access rights, which is enough to query it.
+func openHandle(name string, flags uint32) (syscall.Handle, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	return syscall.CreateFile(p, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, flags, 0)
+}
+
+// stat is os.Stat, returning a *fileInfo.
+func stat(name string) (fs.FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info, path: name, follow: true}, nil
+}
+
+// lstat is os.Lstat, returning a *fileInfo.
+func lstat(name string) (fs.FileInfo, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	fi := &fileInfo{FileInfo: info, path: name}
+	if info.Mode()&fs.ModeIrregular != 0 {
+		tag, _, err := readReparsePoint(name)
+		fi.junction = err == nil && tag == ioReparseTagMountPoint
+	}
+	return fi, nil
+}
+
+// dirEntry is an entry returned by os.ReadDir, whose Info is a *fileInfo.
+type dirEntry struct {
+	fs.DirEntry
+	path string
+}
+
+func (e dirEntry) Info() (fs.FileInfo, error) { return lstat(e.path) }
+
+// readDir is os.ReadDir, with junctions reported as symbolic links and
+// entries whose Info is a *fileInfo.
+func readDir(name string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(name)
+	for i, entry := range entries {
+		path := filepath.Join(name, entry.Name())
+		if entry.Type()&fs.ModeIrregular != 0 {
+			if info, err := lstat(path); err == nil {
+				entries[i] = fs.FileInfoToDirEntry(info)
+				continue
+			}
+		}
+		entries[i] = dirEntry{entry, path}
+	}
+	return entries, err
+}
+
+// readlink returns the destination of the symbolic link or junction name.
+func readlink(name string) (string, error) {
+	_, dest, err := readReparsePoint(name)
+	return dest, err
+}
+
+// readReparsePoint reads the reparse point name with FSCTL_GET_REPARSE_POINT
+// and returns its tag and, for symbolic links and junctions, its
+// destination.
+func readReparsePoint(name string) (tag uint32, dest string, err error) {
+	h, err := openHandle(name, syscall.FILE_FLAG_OPEN_REPARSE_POINT|syscall.FILE_FLAG_BACKUP_SEMANTICS)
+	if err != nil {
+		return 0, "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]byte, syscall.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+	var n uint32
+	if err := syscall.DeviceIoControl(h, syscall.FSCTL_GET_REPARSE_POINT, nil, 0, &buf[0], uint32(len(buf)), &n, nil); err != nil {
+		return 0, "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	tag, dest, ok := parseReparsePoint(buf[:n])
+	if !ok {
+		return tag, "", &fs.PathError{Op: "readlink", Path: name, Err: errors.New("not a symbolic link or junction")}
+	}
+	return tag, dest, nil
+}
+
+// parseReparsePoint decodes a REPARSE_DATA_BUFFER, returning its tag and
+// the substitute name of a symbolic link or junction in the form used by
+// the Win32 API.
+func parseReparsePoint(buf []byte) (tag uint32, dest string, ok bool) {
+	if len(buf) < 8 {
+		return 0, "", false
+	}
+	tag = binary.LittleEndian.Uint32(buf)
+	data := buf[8:]
+	var pathBuffer int
+	relative := false
+	switch tag {
+	case ioReparseTagSymlink:
+		if len(data) < 12 {
+			return tag, "", false
+		}
+		pathBuffer = 12
+		relative = binary.LittleEndian.Uint32(data[8:])&symlinkFlagRelative != 0
+	case ioReparseTagMountPoint:
+		pathBuffer = 8
+	default:
+		return tag, "", false
+	}
+	if len(data) < pathBuffer {
+		return tag, "", false
+	}
+	off := int(binary.LittleEndian.Uint16(data))
+	size := int(binary.LittleEndian.Uint16(data[2:]))
+	name := data[pathBuffer:]
+	if off+size > len(name) {
+		return tag, "", false
+	}
+	u := make([]uint16, size/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(name[off+2*i:])
+	}
+	dest = string(utf16.Decode(u))
+	if relative {
+		return tag, dest, true
+	}
+	// Absolute destinations are NT paths: \??\C:\dir, \??\UNC\server\share,
+	// or \??\Volume{guid}\ for a mounted volume.
+	if rest, found := strings.CutPrefix(dest, `\??\`); found {
+		switch {
+		case strings.HasPrefix(rest, `UNC\`):
+			dest = `\\` + rest[len(`UNC\`):]
+		case strings.HasPrefix(rest, "Volume{"):
+			dest = `\\?\` + rest
+		default:
+			dest = rest
+		}
+	}
+	return tag, dest, true
+}
+
+// evalSymlinks returns the path name leads to once every symbolic link and
+// junction in it is resolved, as reported by GetFinalPathNameByHandle.
+// filepath.EvalSymlinks does not resolve junctions.
+func evalSymlinks(name string) (string, error) {
+	h, err := openHandle(name, syscall.FILE_FLAG_BACKUP_SEMANTICS)
+	if err != nil {
+		if err == errorCantResolveFilename {
+			err = errors.New("too many links")
+		}
+		return "", &fs.PathError{Op: "EvalSymlinks", Path: name, Err: err}
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	for {
+		n, _, err := procGetFinalPathNameByHandleW.Call(uintptr(h), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+		if n == 0 {
+			return "", &fs.PathError{Op: "EvalSymlinks", Path: name, Err: err}
+		}
+		if int(n) < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]uint16, n)
+	}
+	resolved := syscall.UTF16ToString(buf)
+	if rest, found := strings.CutPrefix(resolved, `\\?\UNC\`); found {
+		return `\\` + rest, nil
+	}
+	return strings.TrimPrefix(resolved, `\\?\`), nil
+}
@@ -0,0 +1,205 @@
+package lfinder
+
+import (
+	"bufio"
+	"cmp"
+	"html/template"
+	"io"
+	"slices"
+	"time"
+)
+
+// HTMLSink writes the results as a standalone HTML report: a summary with a
+// bar chart of the kinds of links found, followed by sortable tables of the
+// matches, the broken links and loops, the hard links grouped by inode, and
+// the paths that could not be examined. The report needs no external
+// resources, so it can be attached to a ticket as a single file. It is
+// written by Flush.
+type HTMLSink struct {
+	// Scan describes the search producing the results. If set, the report
+	// names its roots and targets and lists its errors.
+	Scan *ScanMetadata
+
+	w       io.Writer
+	results []Result
+}
+
+// NewHTMLSink returns an HTMLSink writing to w.
+func NewHTMLSink(w io.Writer) *HTMLSink {
+	return &HTMLSink{w: w}
+}
+
+// Emit records r for the report.
+func (s *HTMLSink) Emit(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+// htmlCluster is a set of hard links sharing an inode.
+type htmlCluster struct {
+	Device, Inode uint64
+	Nlink         uint64
+	Paths         []string
+}
+
+// htmlBar is a bar of the summary chart.
+type htmlBar struct {
+	Label   string
+	Count   int
+	Percent int
+}
+
+// Flush writes the report.
+func (s *HTMLSink) Flush() error {
+	data := struct {
+		Generated string
+		Duration  string
+		Roots     []string
+		Targets   []string
+		Matches   []Result
+		Broken    []Result
+		Clusters  []htmlCluster
+		Errors    []*ScanError
+		Bars      []htmlBar
+		Total     int
+	}{Generated: time.Now().Format(time.RFC1123), Total: len(s.results)}
+
+	clusters := make(map[fileKey]*htmlCluster)
+	var keys []fileKey
+	counts := make(map[Kind]int)
+	for _, r := range s.results {
+		counts[r.Kind]++
+		if r.Kind == KindBroken || r.Kind == KindLoop {
+			data.Broken = append(data.Broken, r)
+			continue
+		}
+		data.Matches = append(data.Matches, r)
+		if r.Kind == KindHardlink {
+			k := fileKey{r.Device, r.Inode}
+			c, ok := clusters[k]
+			if !ok {
+				c = &htmlCluster{Device: r.Device, Inode: r.Inode, Nlink: r.Nlink}
+				clusters[k] = c
+				keys = append(keys, k)
+			}
+			c.Paths = append(c.Paths, r.Path)
+		}
+	}
+	for _, k := range keys {
+		c := clusters[k]
+		slices.Sort(c.Paths)
+		data.Clusters = append(data.Clusters, *c)
+	}
+	slices.SortFunc(data.Clusters, func(a, b htmlCluster) int {
+		return cmp.Compare(len(b.Paths), len(a.Paths))
+	})
+	for _, kind := range []Kind{KindSymlink, KindHardlink, KindBroken, KindLoop} {
+		bar := htmlBar{Label: string(kind), Count: counts[kind]}
+		if len(s.results) > 0 {
+			bar.Percent = bar.Count * 100 / len(s.results)
+		}
+		data.Bars = append(data.Bars, bar)
+	}
+	if s.Scan != nil {
+		data.Roots, data.Targets = s.Scan.Roots, s.Scan.Targets
+		data.Duration = time.Since(s.Scan.Started).Round(time.Millisecond).String()
+		if s.Scan.Errors != nil {
+			data.Errors = slices.Collect(s.Scan.Errors.All())
+		}
+	}
+
+	w := bufio.NewWriter(s.w)
+	if err := htmlReport.Execute(w, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>lfinder report</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; } h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; }
+th:after { content: " \2195"; color: #aaa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font: 13px monospace; }
+.bar { display: flex; align-items: center; margin: 4px 0; }
+.bar span { width: 8em; } .bar b { width: 4em; text-align: right; margin-right: 8px; }
+.bar div { height: 14px; }
+.symlink { background: #2a9d8f; } .hardlink { background: #e9c46a; } .broken { background: #e76f51; } .loop { background: #9b5de5; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>lfinder report</h1>
+<p class="meta">Generated {{.Generated}}{{with .Duration}}; the search took {{.}}{{end}}.</p>
+{{with .Roots}}<p>Searched: {{range $i, $r := .}}{{if $i}}, {{end}}<code>{{$r}}</code>{{end}}</p>{{end}}
+{{with .Targets}}<p>Targets: {{range $i, $t := .}}{{if $i}}, {{end}}<code>{{$t}}</code>{{end}}</p>{{end}}
+
+<h2>Summary</h2>
+<p>{{.Total}} links found, {{len .Clusters}} hard link clusters, {{len .Errors}} paths not examined.</p>
+{{range .Bars}}<div class="bar"><span>{{.Label}}</span><b>{{.Count}}</b><div class="{{.Label}}" style="width: {{.Percent}}%"></div></div>
+{{end}}
+{{with .Matches}}
+<h2>Matches</h2>
+<table class="sortable">
+<thead><tr><th>Path</th><th>Kind</th><th>Target</th><th>Link target</th><th>Mode</th><th>Links</th><th>Size</th><th>Modified</th></tr></thead>
+<tbody>
+{{range .}}<tr><td><code>{{.Path}}</code></td><td>{{.Kind}}</td><td><code>{{.Target}}</code></td><td><code>{{.LinkTarget}}</code></td><td><code>{{.Mode}}</code></td><td class="num">{{.Nlink}}</td><td class="num">{{.Size}}</td><td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+{{with .Broken}}
+<h2>Broken links</h2>
+<table class="sortable">
+<thead><tr><th>Path</th><th>Problem</th><th>Link target</th><th>Loop</th><th>Modified</th></tr></thead>
+<tbody>
+{{range .}}<tr><td><code>{{.Path}}</code></td><td>{{if eq .Kind "loop"}}loop{{else}}missing destination{{end}}</td><td><code>{{.LinkTarget}}</code></td><td>{{range $i, $p := .Chain}}{{if $i}} &rarr; {{end}}<code>{{$p}}</code>{{end}}</td><td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+{{with .Clusters}}
+<h2>Hard link clusters</h2>
+<table class="sortable">
+<thead><tr><th>Device</th><th>Inode</th><th>Links</th><th>Found</th><th>Paths</th></tr></thead>
+<tbody>
+{{range .}}<tr><td class="num">{{.Device}}</td><td class="num">{{.Inode}}</td><td class="num">{{.Nlink}}</td><td class="num">{{len .Paths}}</td><td>{{range $i, $p := .Paths}}{{if $i}}<br>{{end}}<code>{{$p}}</code>{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+{{with .Errors}}
+<h2>Paths not examined</h2>
+<table class="sortable">
+<thead><tr><th>Path</th><th>Operation</th><th>Category</th><th>Error</th></tr></thead>
+<tbody>
+{{range .}}<tr><td><code>{{.Path}}</code></td><td>{{.Op}}</td><td>{{.Category}}</td><td>{{.Err}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th, _) {
+	th.addEventListener("click", function () {
+		var table = th.closest("table"), body = table.tBodies[0];
+		var col = Array.prototype.indexOf.call(th.parentNode.children, th);
+		var asc = th.dataset.order !== "asc";
+		th.dataset.order = asc ? "asc" : "desc";
+		var rows = Array.prototype.slice.call(body.rows);
+		rows.sort(function (a, b) {
+			var x = a.cells[col].textContent, y = b.cells[col].textContent;
+			var n = parseFloat(x) - parseFloat(y);
+			var c = isNaN(n) ? x.localeCompare(y) : n;
+			return asc ? c : -c;
+		});
+		rows.forEach(function (row) { body.appendChild(row); });
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,158 @@
+package lfinder
+
+import (
+	"bufio"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ignoreFiles are the files whose patterns are read in each directory with
+// Finder.Gitignore. The patterns of .ignore files, as read by ripgrep and
+// fd, take precedence over those of .gitignore files.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// ignoreRule is a pattern of an ignore file.
+type ignoreRule struct {
+	// re matches the paths relative to the directory of the ignore file,
+	// with / as the separator.
+	re      *regexp.Regexp
+	negate  bool // the pattern started with !, re-including paths
+	dirOnly bool // the pattern ended with /, matching directories only
+}
+
+// compileIgnore compiles a line of an ignore file, following gitignore(5):
+// a pattern with no slash but a trailing one matches a name at any depth,
+// and other patterns match paths relative to the directory of the file.
+// * and ? match within a path component, ** matches any number of them.
+// Blank lines, comments, and invalid patterns yield no rule.
+func compileIgnore(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	switch {
+	case line[0] == '!':
+		r.negate, line = true, line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	var b strings.Builder
+	b.WriteString(`^`)
+	if !anchored {
+		b.WriteString(`(?:.*/)?`)
+	}
+	for i := 0; i < len(line); i++ {
+		atStart := i == 0 || line[i-1] == '/'
+		switch c := line[i]; {
+		case atStart && strings.HasPrefix(line[i:], "**/"):
+			b.WriteString(`(?:.*/)?`)
+			i += 2
+		case atStart && line[i:] == "**":
+			b.WriteString(`.*`)
+			i++
+		case c == '*':
+			b.WriteString(`[^/]*`)
+		case c == '?':
+			b.WriteString(`[^/]`)
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				return ignoreRule{}, false
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`$`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// ignores holds the rules of the ignore files met during a walk. It is safe
+// for concurrent use.
+type ignores struct {
+	fsys  OpenFS
+	mu    sync.RWMutex
+	rules map[string][]ignoreRule // directory -> rules of its ignore files
+}
+
+func newIgnores(fsys OpenFS) *ignores {
+	return &ignores{fsys: fsys, rules: make(map[string][]ignoreRule)}
+}
+
+// load reads the ignore files of dir, if any. Unreadable files are passed
+// over.
+func (ig *ignores) load(dir string) {
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		f, err := ig.fsys.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if r, ok := compileIgnore(sc.Text()); ok {
+				rules = append(rules, r)
+			}
+		}
+		f.Close()
+	}
+	if len(rules) > 0 {
+		ig.mu.Lock()
+		ig.rules[dir] = rules
+		ig.mu.Unlock()
+	}
+}
+
+// ignored reports whether path, found below root, is ignored: whether the
+// last rule matching it, in the ignore files of the innermost directory
+// holding a matching one, does not re-include it.
+func (ig *ignores) ignored(root, path string, dir bool) bool {
+	ig.mu.RLock()
+	defer ig.mu.RUnlock()
+	for d := filepath.Dir(path); ; d = filepath.Dir(d) {
+		if rules := ig.rules[d]; rules != nil {
+			rel, err := filepath.Rel(d, path)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				for i := len(rules) - 1; i >= 0; i-- {
+					r := rules[i]
+					if (!r.dirOnly || dir) && r.re.MatchString(rel) {
+						return !r.negate
+					}
+				}
+			}
+		}
+		if d == root || filepath.Dir(d) == d {
+			return false
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package lfinder
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Index records the links in the trees below some roots, as matched by
+// LinkMatcher, so that the links to a file can be looked up without walking
+// the trees again, along with the modification time of the directories
+// walked, so that it can be brought up to date by reading only those that
+// changed. It is stored in a SQLite database by IndexDB.
+type Index struct {
+	// Roots lists the trees indexed.
+	Roots []string
+	// Built is when the walk indexing the trees started.
+	Built time.Time
+	// Links lists the symbolic links, with their resolved destination in
+	// Result.Target unless broken or caught in a loop, and the files with
+	// several hard links, sorted by path. Result.Err is not recorded.
+	Links []Result
+	// Dirs holds the modification time of each directory walked.
+	Dirs map[string]time.Time
+}
+
+// racyWindow is how long before Index.Built a directory must have last been
+// modified for its modification time to tell that it has not changed since:
+// it could otherwise have changed after it was read within the granularity
+// of the time stamps, as coarse as 2s on FAT.
+const racyWindow = 2 * time.Second
+
+// BuildIndex walks the trees below the roots and returns the Index of the
+// links found, or the context's error if the walk stopped early, as when
+// f.Timeout elapses. MaxResults is ignored. Paths that cannot be examined
+// are passed to f.OnError.
+func (f *Finder) BuildIndex(ctx context.Context) (*Index, error) {
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+	ix := &Index{Roots: f.roots(), Built: time.Now()}
+	results, dirs, err := f.indexTrees(ctx, ix.Roots, nil)
+	if err != nil {
+		return nil, err
+	}
+	ix.Links, ix.Dirs = results, dirs
+	slices.SortFunc(ix.Links, ByPath)
+	return ix, nil
+}
+
+// indexTrees walks the trees below roots, not descending into the
+// directories for which prune, if set, reports true, and returns the links
+// found and the modification times of the directories walked. A directory
+// whose modification time cannot be read is recorded with the zero time,
+// so that it is read again by the next update.
+func (f *Finder) indexTrees(ctx context.Context, roots []string, prune func(dir string) bool) ([]Result, map[string]time.Time, error) {
+	var mu sync.Mutex
+	dirs := make(map[string]time.Time)
+	g := *f
+	g.Root, g.Roots, g.MaxResults = "", roots, 0
+	g.visitDir = func(path string, d fs.DirEntry) bool {
+		if prune != nil && prune(path) {
+			return false
+		}
+		var mtime time.Time
+		if info, err := d.Info(); err == nil {
+			mtime = info.ModTime()
+		}
+		mu.Lock()
+		dirs[path] = mtime
+		mu.Unlock()
+		return true
+	}
+	var results []Result
+	for r := range g.Scan(ctx, LinkMatcher{FS: f.FS}) {
+		r.Err = nil
+		results = append(results, r)
+	}
+	return results, dirs, ctx.Err()
+}
+
+// UpdateIndex returns ix brought up to date with the trees below the roots,
+// which must be those ix was built with, or the context's error if a walk
+// stopped early. Only the directories whose modification time changed
+// since are read again, together with the new directories below them: a
+// directory is modified when entries are added to it, removed, or renamed.
+// The links recorded in the other directories are examined again, as their
+// destination or link count may have changed. Files that gained a hard link
+// in another directory are only found by a full rebuild, and so are links
+// below directories that are no longer skipped by the options of f. The
+// trees are walked in full, as by BuildIndex, when ix records no
+// directories, when f selects candidates by depth or ignore files, which
+// depends on the trees above the directories read, when it skips directories
+// tagged by marker files, whose addition need not modify the directory
+// walked, when it follows every link, or when the roots differ.
+func (f *Finder) UpdateIndex(ctx context.Context, ix *Index) (*Index, error) {
+	roots := f.roots()
+	if len(ix.Dirs) == 0 || f.MinDepth > 0 || f.MaxDepth > 0 || f.Gitignore || len(f.Markers) > 0 ||
+		f.Follow == FollowAlways || !slices.Equal(slices.Sorted(slices.Values(roots)), slices.Sorted(slices.Values(ix.Roots))) {
+		return f.BuildIndex(ctx)
+	}
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+	fsys := f.fs()
+	onError := f.OnError
+	if onError == nil {
+		onError = func(*ScanError) {}
+	}
+
+	// Directories that are gone are dropped along with their links: their
+	// parent was modified, and is read again. So are the directories above
+	// a modified one, for the walk to reach it.
+	next := &Index{Roots: ix.Roots, Built: time.Now(), Dirs: make(map[string]time.Time, len(ix.Dirs))}
+	var changed []string
+	for dir, mtime := range ix.Dirs {
+		info, err := fsys.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist), err == nil && !info.IsDir():
+		case err != nil, !info.ModTime().Equal(mtime), !mtime.Before(ix.Built.Add(-racyWindow)):
+			changed = append(changed, dir)
+		default:
+			next.Dirs[dir] = mtime
+		}
+	}
+	for _, dir := range changed {
+		for p := filepath.Dir(dir); p != dir; dir, p = p, filepath.Dir(p) {
+			if _, ok := next.Dirs[p]; !ok {
+				break
+			}
+			delete(next.Dirs, p)
+		}
+	}
+
+	links := LinkMatcher{FS: linkCaches()(fsys)}
+	for _, r := range ix.Links {
+		dir := filepath.Dir(r.Path)
+		if _, ok := next.Dirs[dir]; !ok {
+			if _, walked := ix.Dirs[dir]; walked {
+				continue
+			}
+		}
+		info, err := fsys.Lstat(r.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			onError(newScanError(r.Path, "lstat", err))
+			continue
+		}
+		result, ok, err := links.Match(r.Path, nil, info)
+		if err != nil {
+			onError(newScanError(r.Path, "readlink", err))
+			continue
+		}
+		if ok {
+			result.Err = nil
+			next.Links = append(next.Links, result)
+		}
+	}
+
+	if len(changed) > 0 {
+		results, dirs, err := f.indexTrees(ctx, roots, func(dir string) bool {
+			_, ok := next.Dirs[dir]
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		next.Links = append(next.Links, results...)
+		maps.Copy(next.Dirs, dirs)
+	}
+	slices.SortFunc(next.Links, ByPath)
+	return next, ctx.Err()
+}
@@ -0,0 +1,145 @@
+package lfinder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readDirFS is the host file system, recording the directories read.
+type readDirFS struct {
+	OSFS
+	mu   sync.Mutex
+	read []string
+}
+
+func (r *readDirFS) ReadDir(name string) ([]os.DirEntry, error) {
+	r.mu.Lock()
+	r.read = append(r.read, name)
+	r.mu.Unlock()
+	return r.OSFS.ReadDir(name)
+}
+
+// updateIndex indexes a hostTree whose directories were last modified an
+// hour ago, applies change to it, and updates the index. It returns the
+// tree, the updated index, and the directories read again relative to the
+// tree, sorted. It checks that the updated index holds what a new one
+// would.
+func updateIndex(t *testing.T, change func(dir string) error) (dir string, ix *Index, read []string) {
+	t.Helper()
+	dir, _ = hostTree(t)
+	old := time.Now().Add(-time.Hour)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := NewFinder(WithRoot(dir)).BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(built.Dirs) != 4 {
+		t.Errorf("indexed %d directories, want 4", len(built.Dirs))
+	}
+	if change != nil {
+		if err := change(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsys := &readDirFS{}
+	ix, err = NewFinder(WithRoot(dir), WithFS(fsys)).UpdateIndex(context.Background(), built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range fsys.read {
+		rel, _ := filepath.Rel(dir, d)
+		read = append(read, filepath.ToSlash(rel))
+	}
+	slices.Sort(read)
+
+	rebuilt, err := NewFinder(WithRoot(dir)).BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexed(t, dir, ix.Links), indexed(t, dir, rebuilt.Links); !slices.Equal(got, want) {
+		t.Errorf("updated index holds %q, rebuilt one %q", got, want)
+	}
+	return dir, ix, read
+}
+
+func TestUpdateIndexUnchanged(t *testing.T) {
+	dir, ix, read := updateIndex(t, nil)
+	if len(read) != 0 {
+		t.Errorf("read %q again, want nothing", read)
+	}
+	want := []string{
+		"a/abs symlink target",
+		"a/b/rel symlink target",
+		"a/chain symlink target",
+		"a/hard hardlink ",
+		"many/broken broken ",
+		"many/hard hardlink ",
+		"target hardlink ",
+	}
+	if got := indexed(t, dir, ix.Links); !slices.Equal(got, want) {
+		t.Errorf("updated index holds %q, want %q", got, want)
+	}
+}
+
+// TestUpdateIndexLinks checks that only the directories where a link was
+// added or removed, and those above them, are read again.
+func TestUpdateIndexLinks(t *testing.T) {
+	dir, ix, read := updateIndex(t, func(dir string) error {
+		if err := os.Symlink("../../target", filepath.Join(dir, "a/b/new")); err != nil {
+			return err
+		}
+		return os.Remove(filepath.Join(dir, "many/broken"))
+	})
+	if want := []string{".", "a", "a/b", "many"}; !slices.Equal(read, want) {
+		t.Errorf("read %q again, want %q", read, want)
+	}
+	want := []string{
+		"a/abs symlink target",
+		"a/b/new symlink target",
+		"a/b/rel symlink target",
+		"a/chain symlink target",
+		"a/hard hardlink ",
+		"many/hard hardlink ",
+		"target hardlink ",
+	}
+	if got := indexed(t, dir, ix.Links); !slices.Equal(got, want) {
+		t.Errorf("updated index holds %q, want %q", got, want)
+	}
+}
+
+// TestUpdateIndexDestination checks that the recorded links are examined
+// again even where their directories did not change: removing the target
+// only modifies the root, yet breaks the symlinks to it.
+func TestUpdateIndexDestination(t *testing.T) {
+	dir, ix, read := updateIndex(t, func(dir string) error {
+		return os.Remove(filepath.Join(dir, "target"))
+	})
+	if want := []string{"."}; !slices.Equal(read, want) {
+		t.Errorf("read %q again, want %q", read, want)
+	}
+	want := []string{
+		"a/abs broken ",
+		"a/b/rel broken ",
+		"a/chain broken ",
+		"a/hard hardlink ",
+		"many/broken broken ",
+		"many/hard hardlink ",
+	}
+	if got := indexed(t, dir, ix.Links); !slices.Equal(got, want) {
+		t.Errorf("updated index holds %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,299 @@
+package lfinder
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// indexVersion is the version of the schema of an IndexDB, kept in its
+// user_version, so that indexes written by incompatible versions are
+// rejected.
+const indexVersion = 1
+
+// indexSchema creates the tables of an IndexDB. Links are looked up by the
+// resolved destination of symbolic links and by the device and inode of
+// hard links, each through an index of its own.
+const indexSchema = `
+CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+CREATE TABLE roots (path TEXT NOT NULL);
+CREATE TABLE dirs (path TEXT PRIMARY KEY, mtime INTEGER);
+CREATE TABLE links (
+	path TEXT PRIMARY KEY,
+	kind TEXT NOT NULL,
+	target TEXT NOT NULL,
+	link_target TEXT NOT NULL,
+	chain TEXT NOT NULL,
+	depth INTEGER NOT NULL,
+	alias TEXT NOT NULL,
+	bind_mount TEXT NOT NULL,
+	device INTEGER NOT NULL,
+	inode INTEGER NOT NULL,
+	nlink INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	mtime INTEGER NOT NULL,
+	mode INTEGER NOT NULL,
+	uid INTEGER NOT NULL,
+	gid INTEGER NOT NULL
+);
+CREATE INDEX links_target ON links (target) WHERE kind = 'symlink';
+CREATE INDEX links_inode ON links (device, inode) WHERE kind = 'hardlink';
+`
+
+// linkColumns lists the columns of the links table in the order they are
+// written and read.
+const linkColumns = "path, kind, target, link_target, chain, depth, alias, bind_mount, device, inode, nlink, size, mtime, mode, uid, gid"
+
+// IndexDB is an Index stored in a SQLite database file, in which the links
+// to a file are looked up without reading the rest of the index.
+type IndexDB struct {
+	db *sql.DB
+}
+
+// OpenIndexDB opens the index stored at path, creating an empty one if the
+// file does not exist or is empty. It fails if the file is not an index, or
+// one written by an incompatible version.
+func OpenIndexDB(path string) (*IndexDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	d := &IndexDB{db}
+	if err := d.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// init checks the schema version of the database, and creates the schema
+// in an empty database.
+func (d *IndexDB) init() error {
+	var version int
+	if err := d.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return errors.New("not a lfinder index")
+	}
+	if version == indexVersion {
+		return nil
+	}
+	var tables int
+	if err := d.db.QueryRow("SELECT count(*) FROM sqlite_schema").Scan(&tables); err != nil {
+		return errors.New("not a lfinder index")
+	}
+	switch {
+	case version == 0 && tables == 0:
+	case version == 0:
+		return errors.New("not a lfinder index")
+	default:
+		return fmt.Errorf("index format %d not supported, rebuild the index", version)
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(indexSchema); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", indexVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database.
+func (d *IndexDB) Close() error {
+	return d.db.Close()
+}
+
+// Save replaces the contents of the database with ix, in a single
+// transaction.
+func (d *IndexDB) Save(ix *Index) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"meta", "roots", "dirs", "links"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO meta (key, value) VALUES ('built', ?)", ix.Built.Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	for _, root := range ix.Roots {
+		if _, err := tx.Exec("INSERT INTO roots (path) VALUES (?)", root); err != nil {
+			return err
+		}
+	}
+	dirs, err := tx.Prepare("INSERT INTO dirs (path, mtime) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer dirs.Close()
+	for dir, mtime := range ix.Dirs {
+		var ns any // a zero time is stored as NULL
+		if !mtime.IsZero() {
+			ns = mtime.UnixNano()
+		}
+		if _, err := dirs.Exec(dir, ns); err != nil {
+			return err
+		}
+	}
+	links, err := tx.Prepare("INSERT INTO links (" + linkColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer links.Close()
+	for _, r := range ix.Links {
+		// SQLite integers are signed: device and inode numbers are stored
+		// as their bit patterns.
+		_, err := links.Exec(r.Path, string(r.Kind), r.Target, r.LinkTarget, strings.Join(r.Chain, "\x00"), r.Depth,
+			r.Alias, r.BindMount, int64(r.Device), int64(r.Inode), int64(r.Nlink), r.Size, r.ModTime.UnixNano(),
+			int64(r.Mode), int64(r.UID), int64(r.GID))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Load reads the whole index, as updating it requires.
+func (d *IndexDB) Load() (*Index, error) {
+	ix := &Index{Dirs: make(map[string]time.Time)}
+	var built string
+	if err := d.db.QueryRow("SELECT value FROM meta WHERE key = 'built'").Scan(&built); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("the index is empty; build it with lfinder index build")
+		}
+		return nil, err
+	}
+	var err error
+	if ix.Built, err = time.Parse(time.RFC3339Nano, built); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+
+	if ix.Roots, err = d.Roots(); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query("SELECT path, mtime FROM dirs")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dir string
+		var ns sql.NullInt64
+		if err := rows.Scan(&dir, &ns); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var mtime time.Time
+		if ns.Valid {
+			mtime = time.Unix(0, ns.Int64)
+		}
+		ix.Dirs[dir] = mtime
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	ix.Links, err = d.links("SELECT " + linkColumns + " FROM links ORDER BY path")
+	return ix, err
+}
+
+// Roots returns the roots of the trees indexed.
+func (d *IndexDB) Roots() ([]string, error) {
+	rows, err := d.db.Query("SELECT path FROM roots ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roots []string
+	for rows.Next() {
+		var root string
+		if err := rows.Scan(&root); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
+// Query returns the links recorded in the index to any of targets, resolved
+// on fsys as Finder.Find does with FollowRoots: symbolic links resolving to a
+// target and hard links sharing its device and inode, with the target they
+// refer to in Result.Target, sorted by path. The links are as they were when
+// the index was last saved. Each target is looked up through the indexes of
+// the database.
+func (d *IndexDB) Query(fsys FS, targets ...string) ([]Result, error) {
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	ts, err := newTargetSet(fsys, true, true, true, targets)
+	if err != nil {
+		return nil, err
+	}
+	// A link resolves to a single path and file, so each is found once:
+	// ts attributes a file given twice to its first path.
+	var results []Result
+	add := func(target string, links []Result) {
+		for _, r := range links {
+			r.Target = target
+			results = append(results, r)
+		}
+	}
+	for path, target := range ts.paths {
+		links, err := d.links("SELECT "+linkColumns+" FROM links WHERE kind = 'symlink' AND target = ?", path)
+		if err != nil {
+			return nil, err
+		}
+		add(target, links)
+	}
+	for key, target := range ts.inodes {
+		links, err := d.links("SELECT "+linkColumns+" FROM links WHERE kind = 'hardlink' AND device = ? AND inode = ?",
+			int64(key.dev), int64(key.ino))
+		if err != nil {
+			return nil, err
+		}
+		add(target, links)
+	}
+	slices.SortFunc(results, ByPath)
+	return results, nil
+}
+
+// links returns the links selected by query with args.
+func (d *IndexDB) links(query string, args ...any) ([]Result, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var links []Result
+	for rows.Next() {
+		var r Result
+		var kind, chain string
+		var dev, ino, nlink, mtime, mode, uid, gid int64
+		err := rows.Scan(&r.Path, &kind, &r.Target, &r.LinkTarget, &chain, &r.Depth, &r.Alias, &r.BindMount,
+			&dev, &ino, &nlink, &r.Size, &mtime, &mode, &uid, &gid)
+		if err != nil {
+			return nil, err
+		}
+		r.Kind = Kind(kind)
+		if chain != "" {
+			r.Chain = strings.Split(chain, "\x00")
+		}
+		r.Device, r.Inode, r.Nlink = uint64(dev), uint64(ino), uint64(nlink)
+		r.ModTime, r.Mode = time.Unix(0, mtime), fs.FileMode(mode)
+		r.UID, r.GID = uint32(uid), uint32(gid)
+		links = append(links, r)
+	}
+	return links, rows.Err()
+}
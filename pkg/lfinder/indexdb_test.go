@@ -0,0 +1,111 @@
+package lfinder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// indexed returns the path, kind, and target of links relative to dir,
+// sorted.
+func indexed(t *testing.T, dir string, links []Result) []string {
+	t.Helper()
+	var rel []Result
+	for _, r := range links {
+		p, err := filepath.Rel(dir, r.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Path = filepath.ToSlash(p)
+		if r.Target != "" {
+			if r.Target, err = filepath.Rel(dir, r.Target); err != nil {
+				t.Fatal(err)
+			}
+			r.Target = filepath.ToSlash(r.Target)
+		}
+		rel = append(rel, r)
+	}
+	return found(rel)
+}
+
+func TestIndexDB(t *testing.T) {
+	dir, target := hostTree(t)
+	ix, err := NewFinder(WithRoot(dir)).BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "index.db")
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Load(); err == nil {
+		t.Error("Load succeeded on an empty index")
+	}
+	if err := db.Save(ix); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenIndexDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	loaded, err := db.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded.Roots, ix.Roots) || !loaded.Built.Equal(ix.Built) || len(loaded.Dirs) != len(ix.Dirs) {
+		t.Errorf("loaded roots %q built %v with %d directories, want %q built %v with %d",
+			loaded.Roots, loaded.Built, len(loaded.Dirs), ix.Roots, ix.Built, len(ix.Dirs))
+	}
+	for dir, mtime := range ix.Dirs {
+		if !loaded.Dirs[dir].Equal(mtime) {
+			t.Errorf("directory %s modified at %v, want %v", dir, loaded.Dirs[dir], mtime)
+		}
+	}
+	if len(loaded.Links) != len(ix.Links) {
+		t.Fatalf("loaded %d links, want %d", len(loaded.Links), len(ix.Links))
+	}
+	for i, r := range loaded.Links {
+		want := ix.Links[i]
+		if r.Path != want.Path || r.Kind != want.Kind || r.Target != want.Target || r.LinkTarget != want.LinkTarget ||
+			!slices.Equal(r.Chain, want.Chain) || r.Device != want.Device || r.Inode != want.Inode ||
+			r.Nlink != want.Nlink || r.Mode != want.Mode || !r.ModTime.Equal(want.ModTime) {
+			t.Errorf("loaded %+v, want %+v", r, want)
+		}
+	}
+
+	results, err := db.Query(nil, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a/abs symlink target",
+		"a/b/rel symlink target",
+		"a/chain symlink target",
+		"a/hard hardlink target",
+		"many/hard hardlink target",
+		"target hardlink target",
+	}
+	if got := indexed(t, dir, results); !slices.Equal(got, want) {
+		t.Errorf("Query(%s) = %q, want %q", target, got, want)
+	}
+}
+
+func TestOpenIndexDBRejects(t *testing.T) {
+	dir := t.TempDir()
+	notIndex := filepath.Join(dir, "text")
+	if err := os.WriteFile(notIndex, []byte("not a database, but long enough to tell\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if db, err := OpenIndexDB(notIndex); err == nil {
+		db.Close()
+		t.Errorf("OpenIndexDB(%s) succeeded", notIndex)
+	}
+}
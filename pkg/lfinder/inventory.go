@@ -0,0 +1,176 @@
+package lfinder
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+)
+
+// LinkMatcher matches every link in a tree, for a census of the links
+// rather than a search for the links to a target: every symbolic link, and
+// every regular file, named pipe, socket, or device node with more than one
+// hard link.
+type LinkMatcher struct {
+	// FS is used to resolve links. Defaults to the host file system.
+	FS FS
+}
+
+// Match reports symbolic links as KindSymlink with their resolved
+// destination in Result.Target, or as KindBroken or KindLoop if they cannot
+// be resolved, and other files with several links as KindHardlink.
+func (m LinkMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		result, err := linkResult(fsys, path, info)
+		return result, err == nil, err
+	case info.Mode().IsRegular() || special(info.Mode()):
+		result := newResult(path, KindHardlink, info)
+		return result, result.Nlink > 1, nil
+	}
+	return Result{}, false, nil
+}
+
+func (LinkMatcher) matchesType(t FileType) bool { return t != TypeDir }
+
+func (m LinkMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (LinkMatcher) statFields() statFields { return 0 }
+
+// linkResult returns the Result for the symbolic link path: KindSymlink with
+// its resolved destination in Result.Target, or KindBroken or KindLoop if it
+// cannot be resolved.
+func linkResult(fsys FS, path string, info fs.FileInfo) (Result, error) {
+	resolved, err := evalLink(fsys, path)
+	var loop *LoopError
+	switch {
+	case errors.As(err, &loop):
+		return loopResult(fsys, path, info, loop), nil
+	case errors.Is(err, fs.ErrNotExist):
+		result := newResult(path, KindBroken, info)
+		result.LinkTarget, result.Err = fsys.Readlink(path)
+		return result, nil
+	case err != nil:
+		return Result{}, err
+	}
+	result := newResult(path, KindSymlink, info)
+	result.Target = resolved
+	result.LinkTarget, result.Err = fsys.Readlink(path)
+	return result, nil
+}
+
+// HardlinkGroup is a file with several hard links: the paths found for it
+// and its total number of links, which is larger than len(Paths) when some
+// of them lie outside the searched trees.
+type HardlinkGroup struct {
+	Device uint64   `json:"device"`
+	Inode  uint64   `json:"inode"`
+	Nlink  uint64   `json:"nlink"`
+	Paths  []string `json:"paths"`
+}
+
+// Inventory is the census of the links in a tree, as matched by LinkMatcher.
+type Inventory struct {
+	// HardlinkGroups lists the files with several hard links, largest
+	// groups first. The paths of each group are sorted.
+	HardlinkGroups []HardlinkGroup `json:"hardlink_groups"`
+	// Symlinks lists the symbolic links, including broken links and loops,
+	// sorted by path.
+	Symlinks []Result `json:"symlinks"`
+}
+
+// NewInventory groups results by kind, and hard links by device and inode.
+func NewInventory(results []Result) *Inventory {
+	inv := &Inventory{HardlinkGroups: []HardlinkGroup{}, Symlinks: []Result{}}
+	groups := make(map[fileKey]int)
+	for _, r := range results {
+		if r.Kind != KindHardlink {
+			inv.Symlinks = append(inv.Symlinks, r)
+			continue
+		}
+		k := fileKey{r.Device, r.Inode}
+		i, ok := groups[k]
+		if !ok {
+			i = len(inv.HardlinkGroups)
+			groups[k] = i
+			inv.HardlinkGroups = append(inv.HardlinkGroups, HardlinkGroup{Device: r.Device, Inode: r.Inode, Nlink: r.Nlink})
+		}
+		inv.HardlinkGroups[i].Paths = append(inv.HardlinkGroups[i].Paths, r.Path)
+	}
+	for _, g := range inv.HardlinkGroups {
+		slices.Sort(g.Paths)
+	}
+	slices.SortFunc(inv.HardlinkGroups, func(a, b HardlinkGroup) int {
+		return cmp.Or(cmp.Compare(len(b.Paths), len(a.Paths)), cmp.Compare(a.Device, b.Device), cmp.Compare(a.Inode, b.Inode))
+	})
+	slices.SortFunc(inv.Symlinks, ByPath)
+	return inv
+}
+
+// InventorySink collects the results of a LinkMatcher search and writes them
+// as an Inventory once the search is over: the hard link groups followed by
+// the symbolic links, as text or, if JSON is set, as a JSON object.
+type InventorySink struct {
+	// JSON writes the inventory as a JSON object with "hardlink_groups"
+	// and "symlinks" members.
+	JSON bool
+
+	w       io.Writer
+	results []Result
+}
+
+// NewInventorySink returns an InventorySink writing to w.
+func NewInventorySink(w io.Writer) *InventorySink {
+	return &InventorySink{w: w}
+}
+
+// Emit records r for the inventory.
+func (s *InventorySink) Emit(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+// Flush writes the inventory.
+func (s *InventorySink) Flush() error {
+	inv := NewInventory(s.results)
+	w := bufio.NewWriter(s.w)
+	if s.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(inv); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "Hard link groups (%d):\n", len(inv.HardlinkGroups))
+	for _, g := range inv.HardlinkGroups {
+		fmt.Fprintf(w, "  inode %d on device %d, %d links (%d found):\n", g.Inode, g.Device, g.Nlink, len(g.Paths))
+		for _, path := range g.Paths {
+			fmt.Fprintf(w, "    %s\n", path)
+		}
+	}
+	fmt.Fprintf(w, "Symlinks (%d):\n", len(inv.Symlinks))
+	for _, r := range inv.Symlinks {
+		switch {
+		case r.Kind != KindSymlink:
+			fmt.Fprintf(w, "  %s -> %s (%s)\n", r.Path, r.LinkTarget, r.Kind)
+		case r.Target != r.LinkTarget:
+			fmt.Fprintf(w, "  %s -> %s (%s)\n", r.Path, r.LinkTarget, r.Target)
+		default:
+			fmt.Fprintf(w, "  %s -> %s\n", r.Path, r.LinkTarget)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,174 @@
+package lfinder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IOLimit bounds the I/O a search puts on storage, so that a tree on a
+// server busy with other work can be searched without starving it. An
+// operation is the read of a directory or the examination of a file, mostly
+// a single Lstat.
+type IOLimit struct {
+	// Rate is the number of operations per second, on average. Zero means
+	// no limit.
+	Rate float64
+	// Burst is the number of operations that can run at once above Rate
+	// after a lull, as with a token bucket of that size. Defaults to one
+	// second's worth of Rate.
+	Burst int
+	// InFlight is the number of operations running at once, across the
+	// walk and every pool of workers. Zero means no limit.
+	InFlight int
+}
+
+// ParseIOLimit parses an I/O limit: comma-separated operations per second,
+// as in 500 or 500/s, burst=N, and inflight=N, as in 200,burst=1000 or
+// inflight=4.
+func ParseIOLimit(s string) (IOLimit, error) {
+	var l IOLimit
+	for field := range strings.SplitSeq(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			rate, err := strconv.ParseFloat(strings.TrimSuffix(key, "/s"), 64)
+			if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+				return IOLimit{}, fmt.Errorf("invalid rate %q", key)
+			}
+			l.Rate = rate
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return IOLimit{}, fmt.Errorf("invalid %s %q", key, value)
+		}
+		switch key {
+		case "burst":
+			l.Burst = n
+		case "inflight":
+			l.InFlight = n
+		default:
+			return IOLimit{}, fmt.Errorf("unknown I/O limit %q", key)
+		}
+	}
+	if l.Burst > 0 && l.Rate == 0 {
+		return IOLimit{}, errors.New("burst set without a rate")
+	}
+	return l, nil
+}
+
+// tokenBucket paces operations at rate per second, letting up to burst of
+// them through at once when the bucket has filled up during a lull.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, so that a search starts at full
+// speed.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := float64(burst)
+	if burst <= 0 {
+		b = max(1, rate)
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait before using it. The
+// tokens of the operations waiting are taken in advance, so that they run
+// in turn, spaced at the rate.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// ioGate applies an IOLimit to a search, and the pause of a Nice search
+// under I/O pressure.
+type ioGate struct {
+	bucket *tokenBucket    // nil without a rate
+	slots  chan struct{}   // nil without an in-flight limit
+	pause  atomic.Int64    // nanoseconds slept before each operation
+	done   <-chan struct{} // closed when the search stops
+}
+
+// newIOGate returns the gate applying l until done is closed, or nil if l
+// sets no limit.
+func newIOGate(l IOLimit, done <-chan struct{}) *ioGate {
+	if l.Rate <= 0 && l.InFlight <= 0 {
+		return nil
+	}
+	g := &ioGate{done: done}
+	if l.Rate > 0 {
+		g.bucket = newTokenBucket(l.Rate, l.Burst)
+	}
+	if l.InFlight > 0 {
+		g.slots = make(chan struct{}, l.InFlight)
+	}
+	return g
+}
+
+// acquire waits for an operation to be allowed to start, and returns the
+// function to call once it is over. A nil gate lets every operation through.
+// Once the search stops, operations are let through at once so that the
+// walk and the workers wind down.
+func (g *ioGate) acquire() func() {
+	if g == nil {
+		return func() {}
+	}
+	if pause := time.Duration(g.pause.Load()); pause > 0 {
+		t := time.NewTimer(pause)
+		select {
+		case <-t.C:
+		case <-g.done:
+			t.Stop()
+		}
+	}
+	if g.bucket != nil {
+		if wait := g.bucket.reserve(); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-g.done:
+				t.Stop()
+			}
+		}
+	}
+	if g.slots == nil {
+		return func() {}
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return func() { <-g.slots }
+	case <-g.done:
+		return func() {}
+	}
+}
+
+// gatedFS is an FS whose directory reads pass through an ioGate.
+type gatedFS struct {
+	FS
+	gate *ioGate
+}
+
+func (g gatedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	release := g.gate.acquire()
+	defer release()
+	return g.FS.ReadDir(name)
+}
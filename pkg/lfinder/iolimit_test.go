@@ -0,0 +1,23 @@
+package lfinder
+
+import "testing"
+
+func TestParseIOLimit(t *testing.T) {
+	for s, want := range map[string]IOLimit{
+		"500":             {Rate: 500},
+		"500/s":           {Rate: 500},
+		"0.5":             {Rate: 0.5},
+		"200,burst=1000":  {Rate: 200, Burst: 1000},
+		"inflight=4":      {InFlight: 4},
+		"100, inflight=8": {Rate: 100, InFlight: 8},
+	} {
+		if got, err := ParseIOLimit(s); err != nil || got != want {
+			t.Errorf("ParseIOLimit(%q) = %+v, %v; want %+v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "-5", "inf", "fast", "burst=10", "100,burst=0", "100,depth=3"} {
+		if got, err := ParseIOLimit(s); err == nil {
+			t.Errorf("ParseIOLimit(%q) = %+v, want an error", s, got)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package lfinder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JUnitSink writes the results as a JUnit XML report, so that CI systems can
+// show them as test results and fail a pipeline on regressions. Broken
+// links, symlink loops, and links escaping their tree are failed test cases;
+// other results, such as the links found to a target, are passing test cases
+// describing the link; paths that could not be examined are skipped test
+// cases. A search without results reports a single passing test case. The
+// report is written by Flush.
+type JUnitSink struct {
+	// Scan describes the search producing the results. If set, the report
+	// includes its duration and the paths that could not be examined.
+	Scan *ScanMetadata
+	// Name names the test suite. Defaults to "lfinder".
+	Name string
+
+	w       io.Writer
+	results []Result
+}
+
+// NewJUnitSink returns a JUnitSink writing to w.
+func NewJUnitSink(w io.Writer) *JUnitSink {
+	return &JUnitSink{w: w}
+}
+
+// Emit records r as a test case.
+func (s *JUnitSink) Emit(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Flush writes the report.
+func (s *JUnitSink) Flush() error {
+	suite := junitSuite{Name: s.Name, Time: "0"}
+	if suite.Name == "" {
+		suite.Name = "lfinder"
+	}
+	for _, r := range s.results {
+		c := junitCase{Name: r.Path, Classname: suite.Name + "." + string(r.Kind)}
+		if junitFails(r) {
+			c.Failure = &junitMessage{Message: junitSummary(r), Type: string(r.Kind), Text: junitDetails(r)}
+			suite.Failures++
+		} else {
+			c.SystemOut = junitSummary(r) + "\n" + junitDetails(r)
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if s.Scan != nil {
+		if !s.Scan.Started.IsZero() {
+			suite.Time = fmt.Sprintf("%.3f", time.Since(s.Scan.Started).Seconds())
+			suite.Timestamp = s.Scan.Started.Format("2006-01-02T15:04:05")
+		}
+		if s.Scan.Errors != nil {
+			for err := range s.Scan.Errors.All() {
+				suite.Cases = append(suite.Cases, junitCase{
+					Name:      err.Path,
+					Classname: suite.Name + ".error",
+					Skipped:   &junitMessage{Message: err.Error()},
+				})
+				suite.Skipped++
+			}
+		}
+	}
+	if len(suite.Cases) == 0 {
+		suite.Cases = append(suite.Cases, junitCase{Name: "no links found", Classname: suite.Name})
+	}
+	suite.Tests = len(suite.Cases)
+
+	report := junitSuites{
+		Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped, Time: suite.Time,
+		Suites: []junitSuite{suite},
+	}
+	if _, err := io.WriteString(s.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(s.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, "\n")
+	return err
+}
+
+// junitFails reports whether r is a failed test case: a broken link, a
+// symlink loop, or a link escaping its tree.
+func junitFails(r Result) bool {
+	return r.Kind == KindBroken || r.Kind == KindLoop || r.Kind == KindEscape
+}
+
+// junitSummary returns the one-line description of r, the failure message
+// of a failed test case.
+func junitSummary(r Result) string {
+	switch r.Kind {
+	case KindBroken:
+		return fmt.Sprintf("broken symlink to missing %s", r.LinkTarget)
+	case KindLoop:
+		return "symlink loop: " + strings.Join(r.Chain, " -> ")
+	case KindReflink, KindClone:
+		return "clone of " + r.Target
+	case KindShortcut:
+		return "shortcut to " + r.Target
+	case KindAlias:
+		return "alias of " + r.Target
+	case KindDuplicate:
+		return "copy of " + r.Target
+	case KindEscape:
+		return "symlink escaping to " + r.Target
+	case KindSymlink:
+		if r.Target != "" {
+			return "symlink to " + r.Target
+		}
+		return "symlink to " + r.LinkTarget
+	case KindHardlink:
+		if r.Target != "" {
+			return "hard link to " + r.Target
+		}
+		return "hard link"
+	}
+	return string(r.Kind)
+}
+
+// junitDetails returns the fields of r, the failure text of a failed test
+// case and the output of a passing one.
+func junitDetails(r Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "path: %s\nkind: %s\n", r.Path, r.Kind)
+	if r.Target != "" {
+		fmt.Fprintf(&b, "target: %s\n", r.Target)
+	}
+	if r.LinkTarget != "" {
+		fmt.Fprintf(&b, "link target: %s\n", r.LinkTarget)
+	}
+	fmt.Fprintf(&b, "inode: %d\ndevice: %d\nmodified: %s\n", r.Inode, r.Device, r.ModTime.Format(time.RFC3339))
+	if r.Err != nil {
+		fmt.Fprintf(&b, "error: %v\n", r.Err)
+	}
+	return b.String()
+}
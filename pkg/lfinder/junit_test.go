@@ -0,0 +1,89 @@
+package lfinder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/fs"
+	"testing"
+)
+
+// readJUnit decodes the report written by s, and returns it along with the
+// outcome of each test case by name: "failure", "skipped", or "" if it
+// passed.
+func readJUnit(t *testing.T, s *JUnitSink, b *bytes.Buffer) (junitSuites, map[string]string) {
+	t.Helper()
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var report junitSuites
+	if err := xml.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatalf("%s: %v", b.String(), err)
+	}
+	outcomes := make(map[string]string)
+	for _, c := range report.Suites[0].Cases {
+		switch {
+		case c.Failure != nil:
+			outcomes[c.Name] = "failure"
+		case c.Skipped != nil:
+			outcomes[c.Name] = "skipped"
+		default:
+			outcomes[c.Name] = ""
+		}
+	}
+	return report, outcomes
+}
+
+// TestJUnitSink checks that broken links, loops, and links escaping their
+// tree fail, while the links found by a search pass with a description.
+func TestJUnitSink(t *testing.T) {
+	var b bytes.Buffer
+	s := NewJUnitSink(&b)
+	for _, r := range sinkResults {
+		if err := s.Emit(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, outcomes := readJUnit(t, s, &b)
+	if report.Tests != 5 || report.Failures != 3 || report.Skipped != 0 {
+		t.Errorf("%d tests, %d failures, %d skipped; want 5, 3, 0", report.Tests, report.Failures, report.Skipped)
+	}
+	for name, want := range map[string]string{
+		"/srv/link":       "",
+		"/srv/hard, copy": "",
+		"/srv/dangling":   "failure",
+		"/srv/loop":       "failure",
+		"/srv/out":        "failure",
+	} {
+		if got, ok := outcomes[name]; !ok || got != want {
+			t.Errorf("%s: outcome %q, want %q", name, got, want)
+		}
+	}
+	for _, c := range report.Suites[0].Cases {
+		if c.Failure == nil && c.SystemOut == "" {
+			t.Errorf("passing case %s does not describe the link", c.Name)
+		}
+	}
+}
+
+func TestJUnitSinkErrors(t *testing.T) {
+	var b bytes.Buffer
+	s := NewJUnitSink(&b)
+	errs := &ErrorReport{}
+	errs.Add(&ScanError{Path: "/srv/private", Err: fs.ErrPermission})
+	s.Scan = &ScanMetadata{Errors: errs}
+	if err := s.Emit(sinkResults[0]); err != nil {
+		t.Fatal(err)
+	}
+	report, outcomes := readJUnit(t, s, &b)
+	if report.Tests != 2 || report.Skipped != 1 || outcomes["/srv/private"] != "skipped" {
+		t.Errorf("%d tests, %d skipped, outcomes %q; want /srv/private skipped", report.Tests, report.Skipped, outcomes)
+	}
+}
+
+func TestJUnitSinkEmpty(t *testing.T) {
+	var b bytes.Buffer
+	report, outcomes := readJUnit(t, NewJUnitSink(&b), &b)
+	if got, ok := outcomes["no links found"]; report.Tests != 1 || !ok || got != "" {
+		t.Errorf("%d tests, outcomes %q; want one passing case", report.Tests, outcomes)
+	}
+}
@@ -0,0 +1,136 @@
+package lfinder
+
+import (
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// linkCacher is implemented by matchers resolving symbolic links, so that a
+// search can have them resolve links through its linkCache.
+type linkCacher interface {
+	// cacheLinks returns the matcher resolving links on the FS returned by
+	// cache for its own.
+	cacheLinks(cache func(FS) FS) Matcher
+}
+
+// cacheLinks returns m resolving links through cache, if it resolves links.
+func cacheLinks(m Matcher, cache func(FS) FS) Matcher {
+	if c, ok := m.(linkCacher); ok {
+		return c.cacheLinks(cache)
+	}
+	return m
+}
+
+// linkCaches returns a function wrapping a file system in a linkCache, the
+// same one for every matcher of a search resolving links on it. File
+// systems other than OSFS and ChrootFS are left alone, as is the host file
+// system on Windows, where filepath.EvalSymlinks also resolves junctions
+// and the case of names.
+func linkCaches() func(FS) FS {
+	caches := make(map[FS]*linkCache)
+	return func(fsys FS) FS {
+		if fsys == nil {
+			fsys = OSFS{}
+		}
+		var dest func(dir, dest string) string
+		switch fsys := fsys.(type) {
+		case OSFS:
+			if runtime.GOOS == "windows" {
+				return fsys
+			}
+			dest = osLinkDest
+		case ChrootFS:
+			dest = fsys.linkDest
+		default:
+			return fsys
+		}
+		c, ok := caches[fsys]
+		if !ok {
+			c = &linkCache{FS: fsys, dest: dest}
+			caches[fsys] = c
+		}
+		return c
+	}
+}
+
+// linkCache is an FS resolving symbolic links from the canonical path of
+// their directory, which it records for the rest of the search: links are
+// mostly found side by side, and resolving each of them from scratch would
+// Lstat every directory above it again. Only the last element of a path,
+// and of each destination followed, is then looked at. A path it cannot
+// resolve that way, because it is missing, caught in a loop, or crosses an
+// irregular file such as a junction, is resolved by the file system, so
+// that errors are reported as without the cache.
+type linkCache struct {
+	FS
+	// dest returns the path the destination of a link in a directory
+	// refers to.
+	dest func(dir, dest string) string
+	dirs sync.Map // directory -> canonical path
+}
+
+func (c *linkCache) EvalSymlinks(name string) (string, error) {
+	links := maxSymlinks
+	if resolved, ok := c.resolve(name, &links); ok {
+		return resolved, nil
+	}
+	return c.FS.EvalSymlinks(name)
+}
+
+// resolve returns the canonical path of name, following at most links
+// symbolic links, or false if it cannot be resolved from the cache.
+func (c *linkCache) resolve(name string, links *int) (string, bool) {
+	for {
+		base := filepath.Base(name)
+		if base == "." || base == ".." {
+			// ".." may not lead above the root of a ChrootFS.
+			return "", false
+		}
+		dir, ok := c.dir(filepath.Dir(name), links)
+		if !ok {
+			return "", false
+		}
+		path := filepath.Join(dir, base)
+		info, err := c.FS.Lstat(path)
+		if err != nil || info.Mode()&fs.ModeIrregular != 0 {
+			return "", false
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return path, true
+		}
+		if *links == 0 {
+			return "", false
+		}
+		*links--
+		dest, err := c.FS.Readlink(path)
+		if err != nil {
+			return "", false
+		}
+		name = c.dest(dir, dest)
+	}
+}
+
+// dir returns the canonical path of the directory name, resolving it from
+// its parent unless it is in the cache, or is a root or dot-dot, left to the
+// file system.
+func (c *linkCache) dir(name string, links *int) (string, bool) {
+	if resolved, ok := c.dirs.Load(name); ok {
+		return resolved.(string), true
+	}
+	var resolved string
+	if base := filepath.Base(name); base == "." || base == ".." || filepath.Dir(name) == name {
+		var err error
+		if resolved, err = c.FS.EvalSymlinks(name); err != nil {
+			return "", false
+		}
+	} else {
+		var ok bool
+		if resolved, ok = c.resolve(name, links); !ok {
+			return "", false
+		}
+	}
+	c.dirs.Store(name, resolved)
+	return resolved, true
+}
@@ -0,0 +1,9 @@
+package lfinder
+
+// LinkNames returns the path of every hard link of the file at path,
+// including path itself, as recorded by the file system, without searching
+// for them. It is supported on Windows, where NTFS keeps the names of each
+// file.
+func LinkNames(path string) ([]string, error) {
+	return linkNames(path)
+}
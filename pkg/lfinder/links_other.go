@@ -0,0 +1,11 @@
+//go:build !windows
+
+package lfinder
+
+import "errors"
+
+// linkNames is not implemented on this platform: a file records its link
+// count but not the names of its links.
+func linkNames(path string) ([]string, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,57 @@
+package lfinder
+
+import (
+	"io/fs"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procFindFirstFileNameW = syscall.NewLazyDLL("kernel32.dll").NewProc("FindFirstFileNameW")
+	procFindNextFileNameW  = syscall.NewLazyDLL("kernel32.dll").NewProc("FindNextFileNameW")
+)
+
+// linkNames enumerates the names of the file at path with FindFirstFileNameW
+// and FindNextFileNameW. They are relative to the root of its volume, which
+// is taken from path.
+func linkNames(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := syscall.UTF16PtrFromString(abs)
+	if err != nil {
+		return nil, &fs.PathError{Op: "FindFirstFileName", Path: path, Err: err}
+	}
+	volume := filepath.VolumeName(abs)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	h, _, err := procFindFirstFileNameW.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+	if syscall.Handle(h) == syscall.InvalidHandle && err == syscall.ERROR_MORE_DATA {
+		buf = make([]uint16, size)
+		h, _, err = procFindFirstFileNameW.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+	}
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return nil, &fs.PathError{Op: "FindFirstFileName", Path: path, Err: err}
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+
+	var names []string
+	for {
+		names = append(names, volume+syscall.UTF16ToString(buf[:size]))
+		size = uint32(len(buf))
+		ok, _, err := procFindNextFileNameW.Call(h, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+		if ok == 0 && err == syscall.ERROR_MORE_DATA {
+			buf = make([]uint16, size)
+			ok, _, err = procFindNextFileNameW.Call(h, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+		}
+		if ok == 0 {
+			if err == syscall.ERROR_HANDLE_EOF {
+				return names, nil
+			}
+			return names, &fs.PathError{Op: "FindNextFileName", Path: path, Err: err}
+		}
+	}
+}
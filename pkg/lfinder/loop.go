@@ -0,0 +1,129 @@
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// LoopError reports a symbolic link whose resolution does not terminate
+// because it leads back to a link already followed, as in a -> b -> a or a
+// link to itself.
+type LoopError struct {
+	// Path is the link being resolved.
+	Path string
+	// Chain lists the links followed from Path, ending with the first one
+	// met a second time.
+	Chain []string
+	// Err is the error reported by FS.EvalSymlinks.
+	Err error
+}
+
+func (e *LoopError) Error() string {
+	return "symlink loop: " + strings.Join(e.Chain, " -> ")
+}
+
+func (e *LoopError) Unwrap() error { return e.Err }
+
+// isLoop reports whether err means that symbolic link resolution did not
+// terminate.
+func isLoop(err error) bool {
+	var loop *LoopError
+	// filepath.EvalSymlinks reports loops with a plain error value.
+	return errors.As(err, &loop) || errors.Is(err, syscall.ELOOP) || strings.Contains(err.Error(), "too many links")
+}
+
+// evalLink resolves the symbolic link path on fsys like FS.EvalSymlinks,
+// reporting a cycle as a *LoopError.
+func evalLink(fsys FS, path string) (string, error) {
+	resolved, err := fsys.EvalSymlinks(path)
+	if err != nil && isLoop(err) {
+		return "", &LoopError{Path: path, Chain: linkChain(fsys, path, maxSymlinks), Err: err}
+	}
+	return resolved, err
+}
+
+// linkChain follows the symbolic link path one link at a time, for at most
+// limit links, and returns the links met followed by the destination, or up
+// to and including the first link met twice. The directories of each link
+// are resolved so that a link reached through different paths is recognized.
+func linkChain(fsys FS, path string, limit int) []string {
+	canonical := func(name string) string {
+		if dir, err := fsys.EvalSymlinks(filepath.Dir(name)); err == nil {
+			return filepath.Join(dir, filepath.Base(name))
+		}
+		return name
+	}
+	current := canonical(path)
+	chain := []string{current}
+	seen := map[string]bool{current: true}
+	for range limit {
+		dest, err := fsys.Readlink(current)
+		if err != nil {
+			break
+		}
+		switch c := fsys.(type) {
+		case ChrootFS:
+			dest = c.linkDest(filepath.Dir(current), dest)
+		case *linkCache:
+			dest = c.dest(filepath.Dir(current), dest)
+		default:
+			dest = osLinkDest(filepath.Dir(current), dest)
+		}
+		next := canonical(dest)
+		chain = append(chain, next)
+		if seen[next] {
+			break
+		}
+		seen[next] = true
+		info, err := fsys.Lstat(next)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			break
+		}
+		current = next
+	}
+	return chain
+}
+
+// LoopMatcher matches symbolic links caught in a loop.
+type LoopMatcher struct {
+	// FS is used to resolve links. Defaults to the host file system.
+	FS FS
+}
+
+// Match reports path as KindLoop if it is a symbolic link whose resolution
+// does not terminate, with the links forming the loop in Result.Chain.
+func (m LoopMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return Result{}, false, nil
+	}
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	_, err := evalLink(fsys, path)
+	var loop *LoopError
+	if !errors.As(err, &loop) {
+		return Result{}, false, nil
+	}
+	return loopResult(fsys, path, info, loop), true, nil
+}
+
+func (LoopMatcher) matchesType(t FileType) bool { return t == TypeSymlink }
+
+func (m LoopMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (LoopMatcher) statFields() statFields { return 0 }
+
+// loopResult returns the KindLoop Result for the link path caught in loop.
+func loopResult(fsys FS, path string, info fs.FileInfo, loop *LoopError) Result {
+	result := newResult(path, KindLoop, info)
+	result.Chain = loop.Chain
+	result.LinkTarget, result.Err = fsys.Readlink(path)
+	return result
+}
@@ -0,0 +1,48 @@
+package lfinder
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+)
+
+// CacheDirTag is the name of the file marking a cache directory, following
+// the Cache Directory Tagging Specification honored by tar, Borg, and
+// restic. It marks its directory only when it starts with cacheDirSignature.
+const CacheDirTag = "CACHEDIR.TAG"
+
+// cacheDirSignature is the header of a valid CacheDirTag file.
+const cacheDirSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+
+// tagged returns the marker file among markers found in dir, if any.
+func (s *search) tagged(dir string) (string, bool) {
+	for _, name := range s.Markers {
+		path := filepath.Join(dir, name)
+		if _, err := s.fsys.Lstat(path); err != nil {
+			continue
+		}
+		if name != CacheDirTag || s.cacheDirTag(path) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// cacheDirTag reports whether the file at path starts with the signature of
+// a cache directory tag. It is assumed to when the FS cannot open files.
+func (s *search) cacheDirTag(path string) bool {
+	ofs, ok := s.fsys.(OpenFS)
+	if !ok {
+		return true
+	}
+	f, err := ofs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(cacheDirSignature))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, []byte(cacheDirSignature))
+}
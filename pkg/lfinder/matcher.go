@@ -0,0 +1,319 @@
+package lfinder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Matcher decides whether a candidate path found during the walk should be
+// reported. Match is called concurrently from the worker goroutines with the
+// entry produced by the walker and the result of Lstat on path. It returns
+// the Result to report and true on a match; an error means the candidate
+// could not be examined.
+type Matcher interface {
+	Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error)
+}
+
+// MatcherFunc adapts an ordinary function to the Matcher interface.
+type MatcherFunc func(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error)
+
+// Match calls fn(path, d, info).
+func (fn MatcherFunc) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	return fn(path, d, info)
+}
+
+// typeMatcher is implemented by matchers that only match files of some
+// types, so that the walker can pass over the others without handing them
+// to the workers.
+type typeMatcher interface {
+	// matchesType reports whether files of type t can match.
+	matchesType(t FileType) bool
+}
+
+// matchesType reports whether m can match files of type t: always, unless
+// m tells otherwise.
+func matchesType(m Matcher, t FileType) bool {
+	tm, ok := m.(typeMatcher)
+	return !ok || tm.matchesType(t)
+}
+
+// MatchAny returns a Matcher that tries each matcher in order and reports the
+// first match.
+func MatchAny(matchers ...Matcher) Matcher {
+	return anyMatcher(matchers)
+}
+
+// anyMatcher is the Matcher returned by MatchAny.
+type anyMatcher []Matcher
+
+func (ms anyMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	for _, m := range ms {
+		result, ok, err := m.Match(path, d, info)
+		if err != nil || ok {
+			return result, ok, err
+		}
+	}
+	return Result{}, false, nil
+}
+
+func (ms anyMatcher) matchesType(t FileType) bool {
+	return slices.ContainsFunc(ms, func(m Matcher) bool { return matchesType(m, t) })
+}
+
+func (ms anyMatcher) statFields() statFields {
+	var fields statFields
+	for _, m := range ms {
+		fields |= fieldsOf(m)
+	}
+	return fields
+}
+
+func (ms anyMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	cached := make(anyMatcher, len(ms))
+	for i, m := range ms {
+		cached[i] = cacheLinks(m, cache)
+	}
+	return cached
+}
+
+// SymlinkMatcher matches symbolic links that resolve to Target.
+type SymlinkMatcher struct {
+	// FS is used to resolve links. Defaults to the host file system.
+	FS FS
+	// Target is the resolved path links must point to.
+	Target string
+}
+
+// Match reports path if it is a symbolic link whose fully resolved
+// destination is m.Target. Dangling links are not matches.
+func (m SymlinkMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return Result{}, false, nil
+	}
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	resolved, err := evalLink(fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Result{}, false, nil
+	}
+	if err != nil || resolved != m.Target {
+		return Result{}, false, err
+	}
+	result := newResult(path, KindSymlink, info)
+	result.LinkTarget, result.Err = fsys.Readlink(path)
+	return result, true, nil
+}
+
+func (SymlinkMatcher) matchesType(t FileType) bool { return t == TypeSymlink }
+
+func (m SymlinkMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (SymlinkMatcher) statFields() statFields { return 0 }
+
+// BrokenSymlinkMatcher matches symbolic links whose destination does not exist.
+type BrokenSymlinkMatcher struct {
+	// FS is used to resolve links. Defaults to the host file system.
+	FS FS
+	// Loops also matches links caught in a loop, as KindLoop results.
+	// Otherwise they cannot be examined and fail with a *LoopError.
+	Loops bool
+}
+
+// Match reports path as KindBroken if it is a dangling symbolic link, or as
+// KindLoop if m.Loops is set and it is caught in a loop.
+func (m BrokenSymlinkMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return Result{}, false, nil
+	}
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	_, err := evalLink(fsys, path)
+	var loop *LoopError
+	if m.Loops && errors.As(err, &loop) {
+		return loopResult(fsys, path, info, loop), true, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return Result{}, false, err
+	}
+	result := newResult(path, KindBroken, info)
+	result.LinkTarget, result.Err = fsys.Readlink(path)
+	return result, true, nil
+}
+
+func (BrokenSymlinkMatcher) matchesType(t FileType) bool { return t == TypeSymlink }
+
+func (m BrokenSymlinkMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (BrokenSymlinkMatcher) statFields() statFields { return 0 }
+
+// InodeMatcher matches every path to the file with the given device and inode
+// numbers, such as a file reported by lsof, fsck, or a quota tool, even when
+// no path to it is known. The paths are reported as KindHardlink, since each
+// of them is a hard link to the file.
+type InodeMatcher struct {
+	Device uint64
+	Inode  uint64
+}
+
+// Match reports path if it is a link to the file.
+func (m InodeMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	dev, ino, ok := fileID(info)
+	if !ok || dev != m.Device || ino != m.Inode {
+		return Result{}, false, nil
+	}
+	return newResult(path, KindHardlink, info), true, nil
+}
+
+func (InodeMatcher) statFields() statFields { return 0 }
+
+// EscapeMatcher matches symbolic links resolving to a path outside Root,
+// such as links leaving a chroot, a container build context, or a web
+// document root.
+type EscapeMatcher struct {
+	// FS is used to resolve links. Defaults to the host file system.
+	FS FS
+	// Root is the canonical path of the tree links must stay in.
+	Root string
+}
+
+// Match reports path as KindEscape if it is a symbolic link whose resolved
+// destination lies outside m.Root; the destination is recorded in
+// Result.Target. Links that cannot be resolved are not matches.
+func (m EscapeMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return Result{}, false, nil
+	}
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	resolved, err := fsys.EvalSymlinks(path)
+	if err != nil || within(resolved, m.Root) {
+		return Result{}, false, nil
+	}
+	result := newResult(path, KindEscape, info)
+	result.Target = resolved
+	result.LinkTarget, result.Err = fsys.Readlink(path)
+	return result, true, nil
+}
+
+func (EscapeMatcher) matchesType(t FileType) bool { return t == TypeSymlink }
+
+func (m EscapeMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (EscapeMatcher) statFields() statFields { return 0 }
+
+// LinkPatternMatcher matches symbolic links whose destination, as stored in
+// the link, matches Pattern, whether or not it exists, e.g. to find the links
+// into a removed or renamed location. Links are reported as by LinkMatcher:
+// KindSymlink with their resolved destination, or KindBroken or KindLoop.
+type LinkPatternMatcher struct {
+	// FS is used to read links. Defaults to the host file system.
+	FS FS
+	// Pattern is matched against the raw destination of each link.
+	Pattern *regexp.Regexp
+}
+
+// Match reports path if it is a symbolic link whose destination matches
+// m.Pattern.
+func (m LinkPatternMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return Result{}, false, nil
+	}
+	fsys := m.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	dest, err := fsys.Readlink(path)
+	if err != nil || !m.Pattern.MatchString(dest) {
+		return Result{}, false, err
+	}
+	result, err := linkResult(fsys, path, info)
+	return result, err == nil, err
+}
+
+func (LinkPatternMatcher) matchesType(t FileType) bool { return t == TypeSymlink }
+
+func (m LinkPatternMatcher) cacheLinks(cache func(FS) FS) Matcher {
+	m.FS = cache(m.FS)
+	return m
+}
+
+func (LinkPatternMatcher) statFields() statFields { return 0 }
+
+// CompileGlob compiles a shell pattern matched against a whole string into
+// a regular expression: * matches any run of characters, including /, ?
+// matches one character, and [...] matches a character class, negated by a
+// leading ! or ^. A backslash escapes the next character.
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString(`^(?s:`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("glob %q: missing ]", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\\`, `\\\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`)$`)
+	return regexp.Compile(b.String())
+}
+
+// HardlinkMatcher matches regular files, named pipes, sockets, and device
+// nodes sharing the device and inode numbers Device and Inode.
+type HardlinkMatcher struct {
+	Device uint64
+	Inode  uint64
+}
+
+// Match reports path if it is a regular or special file with device m.Device
+// and inode m.Inode. File systems that expose no inode numbers never match.
+func (m HardlinkMatcher) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if !info.Mode().IsRegular() && !special(info.Mode()) {
+		return Result{}, false, nil
+	}
+	if dev, ino, ok := fileID(info); !ok || dev != m.Device || ino != m.Inode {
+		return Result{}, false, nil
+	}
+	return newResult(path, KindHardlink, info), true, nil
+}
+
+func (HardlinkMatcher) matchesType(t FileType) bool { return t != TypeDir && t != TypeSymlink }
+
+func (HardlinkMatcher) statFields() statFields { return 0 }
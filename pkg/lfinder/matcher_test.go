@@ -0,0 +1,277 @@
+package lfinder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// matchAll returns the paths in linkTree that m matches, with their kind.
+func matchAll(t *testing.T, m Matcher) map[string]Kind {
+	t.Helper()
+	fsys := FromFS(linkTree)
+	matched := make(map[string]Kind)
+	for path := range linkTree {
+		info, err := fsys.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := m.Match(path, fs.FileInfoToDirEntry(info), info)
+		if err != nil && isLoop(err) {
+			// Matchers not reporting loops cannot examine them.
+			continue
+		}
+		if err != nil {
+			t.Errorf("Match(%s): %v", path, err)
+			continue
+		}
+		if ok {
+			matched[path] = r.Kind
+		}
+	}
+	return matched
+}
+
+func TestSymlinkMatcher(t *testing.T) {
+	got := matchAll(t, SymlinkMatcher{FS: FromFS(linkTree), Target: "etc/hosts"})
+	want := map[string]Kind{"etc/link": KindSymlink, "abs": KindSymlink, "chain": KindSymlink, "dir/escape": KindSymlink}
+	if !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+// hardlinks creates a file with a second hard link, another file, and a
+// symlink to the first one, and returns their paths.
+func hardlinks(t *testing.T) (file, link, other, symlink string) {
+	t.Helper()
+	dir := t.TempDir()
+	file = filepath.Join(dir, "file")
+	link = filepath.Join(dir, "link")
+	other = filepath.Join(dir, "other")
+	symlink = filepath.Join(dir, "symlink")
+	for _, name := range []string{file, other} {
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(file, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	if err := os.Symlink(file, symlink); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	return file, link, other, symlink
+}
+
+// matchPaths returns the paths m matches, checking that they are reported
+// as hard links.
+func matchPaths(t *testing.T, m Matcher, paths ...string) []string {
+	t.Helper()
+	var matched []string
+	for _, path := range paths {
+		info, err := OSFS{}.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := m.Match(path, fs.FileInfoToDirEntry(info), info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			continue
+		}
+		if r.Kind != KindHardlink {
+			t.Errorf("%s: kind %s, want %s", path, r.Kind, KindHardlink)
+		}
+		matched = append(matched, path)
+	}
+	return matched
+}
+
+func TestHardlinkMatcher(t *testing.T) {
+	file, link, other, symlink := hardlinks(t)
+	info, err := OSFS{}.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, ino, ok := fileID(info)
+	if !ok {
+		t.Skip("no inode numbers")
+	}
+	got := matchPaths(t, HardlinkMatcher{Device: dev, Inode: ino}, file, link, other, symlink)
+	if want := []string{file, link}; !slices.Equal(got, want) {
+		t.Errorf("matched %q, want %q", got, want)
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	fsys := FromFS(linkTree)
+	m := MatchAny(SymlinkMatcher{FS: fsys, Target: "dir/file"}, SymlinkMatcher{FS: fsys, Target: "etc/hosts"})
+	got := matchAll(t, m)
+	want := map[string]Kind{"dir/inside": KindSymlink, "etc/link": KindSymlink, "abs": KindSymlink, "chain": KindSymlink, "dir/escape": KindSymlink}
+	if !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+func TestBrokenSymlinkMatcher(t *testing.T) {
+	got := matchAll(t, BrokenSymlinkMatcher{FS: FromFS(linkTree)})
+	if want := map[string]Kind{"broken": KindBroken}; !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+func TestLoopMatcher(t *testing.T) {
+	fsys := FromFS(fstest.MapFS{
+		"self":   {Data: []byte("self"), Mode: fs.ModeSymlink},
+		"a":      {Data: []byte("dir/b"), Mode: fs.ModeSymlink},
+		"dir/b":  {Data: []byte("../a"), Mode: fs.ModeSymlink},
+		"into":   {Data: []byte("a"), Mode: fs.ModeSymlink},
+		"broken": {Data: []byte("missing"), Mode: fs.ModeSymlink},
+		"file":   {},
+	})
+	chains := make(map[string][]string)
+	for _, path := range []string{"self", "a", "dir/b", "into", "broken", "file"} {
+		info, err := fsys.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := LoopMatcher{FS: fsys}.Match(path, fs.FileInfoToDirEntry(info), info)
+		if err != nil {
+			t.Fatalf("Match(%s): %v", path, err)
+		}
+		if ok {
+			if r.Kind != KindLoop {
+				t.Errorf("%s: kind %s, want %s", path, r.Kind, KindLoop)
+			}
+			chains[path] = r.Chain
+		}
+	}
+	want := map[string][]string{
+		"self":  {"self", "self"},
+		"a":     {"a", "dir/b", "a"},
+		"dir/b": {"dir/b", "a", "dir/b"},
+		"into":  {"into", "a", "dir/b", "a"},
+	}
+	if !maps.EqualFunc(chains, want, slices.Equal) {
+		t.Errorf("loops %q, want %q", chains, want)
+	}
+}
+
+// TestBrokenSymlinkLoops checks that the audit matcher reports loops along
+// with dangling links when asked to.
+func TestBrokenSymlinkLoops(t *testing.T) {
+	got := matchAll(t, BrokenSymlinkMatcher{FS: FromFS(linkTree), Loops: true})
+	if want := map[string]Kind{"broken": KindBroken, "loop": KindLoop}; !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+// TestEscapeMatcher audits dir, where escape leads out to etc/hosts while
+// inside stays in.
+func TestEscapeMatcher(t *testing.T) {
+	fsys := FromFS(linkTree)
+	f := NewFinder(WithFS(fsys), WithRoot("dir"))
+	var got []string
+	for r := range f.Scan(context.Background(), EscapeMatcher{FS: fsys, Root: "dir"}) {
+		got = append(got, fmt.Sprintf("%s %s %s %s", r.Path, r.Kind, r.Target, r.LinkTarget))
+	}
+	if want := []string{"dir/escape escape etc/hosts ../etc/hosts"}; !slices.Equal(got, want) {
+		t.Errorf("found %q, want %q", got, want)
+	}
+
+	// Where a link lies does not matter, only where it leads: every link
+	// to etc/hosts stays in etc.
+	all := matchAll(t, EscapeMatcher{FS: fsys, Root: "etc"})
+	if want := map[string]Kind{"dir/inside": KindEscape}; !maps.Equal(all, want) {
+		t.Errorf("matched %v, want %v", all, want)
+	}
+}
+
+// TestHardlinkMatcherDevice checks that a file with the inode number sought
+// on another device, or another file, is not a hard link to the file.
+func TestHardlinkMatcherDevice(t *testing.T) {
+	file, link, other, _ := hardlinks(t)
+	info, err := OSFS{}.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, ino, ok := fileID(info)
+	if !ok {
+		t.Skip("no inode numbers")
+	}
+	if got := matchPaths(t, HardlinkMatcher{Device: dev + 1, Inode: ino}, file, link, other); len(got) != 0 {
+		t.Errorf("matched %q on another device", got)
+	}
+	info, err = OSFS{}.Lstat(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherIno, _ := fileID(info)
+	got := matchPaths(t, HardlinkMatcher{Device: dev, Inode: otherIno}, file, link, other)
+	if want := []string{other}; !slices.Equal(got, want) {
+		t.Errorf("matched %q, want %q", got, want)
+	}
+}
+
+func TestLinkPatternMatcher(t *testing.T) {
+	fsys := FromFS(linkTree)
+	got := matchAll(t, LinkPatternMatcher{FS: fsys, Pattern: regexp.MustCompile(`^\.\./`)})
+	if want := map[string]Kind{"dir/escape": KindSymlink}; !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+	// The destination need not exist.
+	got = matchAll(t, LinkPatternMatcher{FS: fsys, Pattern: regexp.MustCompile(`^missing$`)})
+	if want := map[string]Kind{"broken": KindBroken}; !maps.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	match := func(pattern, s string) bool {
+		t.Helper()
+		re, err := CompileGlob(pattern)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q): %v", pattern, err)
+		}
+		return re.MatchString(s)
+	}
+	for pattern, s := range map[string]string{
+		"*.so":   "lib/libc.so",
+		"/opt/*": "/opt/app/bin/tool",
+		"lib?.a": "libm.a",
+		"[!a]*":  "bin",
+		"[^a]*":  "bin",
+		"[a-c]x": "cx",
+		`\*`:     "*",
+		"*\n*":   "a\nb",
+		"a.b":    "a.b",
+		"(a|b)+": "(a|b)+",
+	} {
+		if !match(pattern, s) {
+			t.Errorf("%q does not match %q", pattern, s)
+		}
+	}
+	for pattern, s := range map[string]string{
+		"*.so":   "libc.so.6",
+		"lib?.a": "libcc.a",
+		"[!a]*":  "abc",
+		"[a-c]x": "dx",
+		`\*`:     "a",
+		"a.b":    "axb",
+	} {
+		if match(pattern, s) {
+			t.Errorf("%q matches %q", pattern, s)
+		}
+	}
+	if _, err := CompileGlob("[abc"); err == nil {
+		t.Error("CompileGlob([abc) succeeded")
+	}
+}
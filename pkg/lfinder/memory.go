@@ -0,0 +1,73 @@
+package lfinder
+
+import (
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// memoryInterval is how often the heap is measured against MaxMemory.
+const memoryInterval = 50 * time.Millisecond
+
+// heapMetric is the memory held by heap objects, live or not yet swept.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// memoryGate holds the walk while the heap exceeds Finder.MaxMemory.
+type memoryGate struct {
+	limit  uint64
+	heap   atomic.Uint64 // bytes, as last measured
+	over   atomic.Bool
+	warned atomic.Bool
+}
+
+// watch measures the heap every memoryInterval until done is closed. A
+// heap over the limit is collected before it is taken to be: once the walk
+// is held, little else would get the collector to run.
+func (g *memoryGate) watch(done <-chan struct{}) {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	ticker := time.NewTicker(memoryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		metrics.Read(sample)
+		if sample[0].Value.Uint64() > g.limit {
+			runtime.GC()
+			metrics.Read(sample)
+		}
+		g.heap.Store(sample[0].Value.Uint64())
+		g.over.Store(sample[0].Value.Uint64() > g.limit)
+	}
+}
+
+// throttle holds the walk while the heap exceeds MaxMemory and candidates
+// or results are queued, so that the workers and the caller work through
+// them before the walk reads more directories. When the heap stays over
+// the limit with nothing queued, the memory is not the search's to free,
+// as with results kept by the caller, and the budget is given up.
+func (s *search) throttle(ctx context.Context) {
+	g := s.memory
+	if g == nil || g.warned.Load() || !g.over.Load() {
+		return
+	}
+	held := false
+	for g.over.Load() && s.pools.queued() > 0 {
+		if !held {
+			s.log.Debug("memory budget exceeded, holding the walk", "heap", g.heap.Load(), "max_memory", s.MaxMemory)
+			held = true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(memoryInterval):
+		}
+	}
+	if g.over.Load() && g.warned.CompareAndSwap(false, true) {
+		s.log.Warn("memory budget exceeded with nothing queued, giving it up", "heap", g.heap.Load(), "max_memory", s.MaxMemory)
+	}
+}
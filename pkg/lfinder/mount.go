@@ -0,0 +1,54 @@
+package lfinder
+
+import (
+	"path"
+	"slices"
+)
+
+// Mount is a file system mounted on the host.
+type Mount struct {
+	// ID identifies the mount, and ParentID the mount it is mounted on.
+	ID, ParentID int
+	// Device is the device number of the file system, as in Result.Device.
+	Device uint64
+	// Path is the mount point.
+	Path string
+	// Root is the directory of the file system mounted at Path: "/" unless
+	// a subdirectory is bind mounted.
+	Root string
+	// Type is the file system type, such as "ext4" or "nfs4".
+	Type string
+	// Source is the mounted device or remote share, such as "/dev/sda1" or
+	// "server:/export".
+	Source string
+}
+
+// Mounts returns the file systems mounted on the host, in mount order.
+// It is supported on Linux.
+func Mounts() ([]Mount, error) {
+	return mounts()
+}
+
+// networkFSTypes are the types of the file systems that Network reports as
+// served over the network. FUSE file systems have the type fuse.<name>.
+var networkFSTypes = []string{
+	"nfs", "nfs4", "cifs", "smb3", "smbfs", "ncpfs", "afs", "9p", "ceph",
+	"glusterfs", "lustre", "gpfs", "davfs", "webdav", "afpfs",
+	"fuse.sshfs", "fuse.glusterfs", "fuse.cephfs", "fuse.rclone", "fuse.s3fs",
+	"fuse.davfs2", "fuse.gcsfuse",
+}
+
+// Network reports whether m is a network file system, such as NFS, CIFS,
+// or SSHFS, where walking is slow and can hang when the server is gone.
+func (m Mount) Network() bool {
+	return slices.Contains(networkFSTypes, m.Type)
+}
+
+// typeMatches reports whether the type of m matches one of patterns, as
+// path.Match does.
+func (m Mount) typeMatches(patterns []string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, m.Type)
+		return ok
+	})
+}
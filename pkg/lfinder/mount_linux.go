@@ -0,0 +1,110 @@
+package lfinder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// mounts reads the mount table of the process from /proc/self/mountinfo.
+func mounts() ([]Mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []Mount
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		m, err := parseMountInfo(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("/proc/self/mountinfo: %v", err)
+		}
+		list = append(list, m)
+	}
+	return list, sc.Err()
+}
+
+// rotational reports whether m is a file system on a spinning disk, as
+// told by the queue of its block device, or of the disk of its partition.
+func rotational(m Mount) bool {
+	major := m.Device>>8&0xfff | m.Device>>32&0xfffff000
+	minor := m.Device&0xff | m.Device>>12&0xffffff00
+	dev := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+	for _, queue := range []string{dev + "/queue/rotational", dev + "/../queue/rotational"} {
+		if b, err := os.ReadFile(queue); err == nil {
+			return strings.TrimSpace(string(b)) == "1"
+		}
+	}
+	return false
+}
+
+// parseMountInfo parses a line of /proc/self/mountinfo, such as
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// holding the mount and parent ids, the device, the root, the mount point,
+// the mount options, optional fields ended by "-", the file system type,
+// the source, and the super block options.
+func parseMountInfo(line string) (Mount, error) {
+	fields := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if len(fields) < 5 || sep < 0 || sep+2 >= len(fields) {
+		return Mount{}, fmt.Errorf("malformed line %q", line)
+	}
+	id, err1 := strconv.Atoi(fields[0])
+	parent, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return Mount{}, fmt.Errorf("malformed mount id in %q", line)
+	}
+	major, minor, ok := strings.Cut(fields[2], ":")
+	maj, err1 := strconv.ParseUint(major, 10, 32)
+	min, err2 := strconv.ParseUint(minor, 10, 32)
+	if !ok || err1 != nil || err2 != nil {
+		return Mount{}, fmt.Errorf("malformed device %q", fields[2])
+	}
+	return Mount{
+		ID:       id,
+		ParentID: parent,
+		Device:   mkdev(maj, min),
+		Root:     unescapeMount(fields[3]),
+		Path:     unescapeMount(fields[4]),
+		Type:     fields[sep+1],
+		Source:   unescapeMount(fields[sep+2]),
+	}, nil
+}
+
+// mkdev returns the device number with the given major and minor numbers,
+// as stat reports it.
+func mkdev(major, minor uint64) uint64 {
+	return (major&0xfffff000)<<32 | (major&0xfff)<<8 | (minor&0xffffff00)<<12 | minor&0xff
+}
+
+// unescapeMount decodes the octal escapes, such as \040 for a space, that
+// the kernel writes for white space and backslashes in mount table fields.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package lfinder
+
+import "errors"
+
+// mounts is not implemented on this platform.
+func mounts() ([]Mount, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// rotational is not implemented on this platform.
+func rotational(m Mount) bool {
+	return false
+}
@@ -0,0 +1,195 @@
+package lfinder
+
+import (
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// Normalization is a Unicode normalization form paths are compared in, so
+// that a name written with precomposed characters, as typed on most
+// systems, matches the same name with combining marks, as HFS+ stores it.
+type Normalization string
+
+const (
+	// NormNone compares paths byte by byte.
+	NormNone Normalization = ""
+	// NormNFC compares paths in Normalization Form C, with precomposed
+	// characters.
+	NormNFC Normalization = "nfc"
+	// NormNFD compares paths in Normalization Form D, with combining marks,
+	// the form HFS+ stores names in.
+	NormNFD Normalization = "nfd"
+)
+
+// normalize returns path in the form n.
+func (n Normalization) normalize(path string) string {
+	switch n {
+	case NormNFC:
+		return nfc(path)
+	case NormNFD:
+		return nfd(path)
+	}
+	return path
+}
+
+// Hangul syllables decompose algorithmically, from [Unicode] 3.12.
+const (
+	hangulBase   = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulTCount = 28
+	hangulNCount = 21 * hangulTCount
+	hangulCount  = 19 * hangulNCount
+)
+
+var (
+	compositionsOnce sync.Once
+	compositions     map[[2]rune]rune // pair of characters -> primary composite
+)
+
+// isASCII reports whether s holds only ASCII characters, which need no
+// normalization.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// nfd returns s in Normalization Form D: every character fully decomposed
+// and the combining marks following a starter in canonical order.
+func nfd(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return string(decompose(s))
+}
+
+// nfc returns s in Normalization Form C: decomposed, then with every pair
+// of a starter and a following character that is not blocked from it
+// replaced by their primary composite.
+func nfc(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return string(compose(decompose(s)))
+}
+
+// decompose returns the canonical decomposition of s in canonical order.
+func decompose(s string) []rune {
+	var out []rune
+	for _, r := range s {
+		out = decomposeRune(out, r)
+	}
+	// Sort each run of non-starters by combining class, keeping the order
+	// of marks of the same class.
+	for i := 0; i < len(out); {
+		if combiningClass(out[i]) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(out) && combiningClass(out[j]) != 0 {
+			j++
+		}
+		run := out[i:j]
+		sort.SliceStable(run, func(a, b int) bool { return combiningClass(run[a]) < combiningClass(run[b]) })
+		i = j
+	}
+	return out
+}
+
+// decomposeRune appends the full canonical decomposition of r to out.
+func decomposeRune(out []rune, r rune) []rune {
+	if s := r - hangulBase; s >= 0 && s < hangulCount {
+		out = append(out, hangulLBase+s/hangulNCount, hangulVBase+s%hangulNCount/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			out = append(out, hangulTBase+t)
+		}
+		return out
+	}
+	d, ok := canonicalDecompositions[r]
+	if !ok {
+		return append(out, r)
+	}
+	for _, c := range d {
+		out = decomposeRune(out, c)
+	}
+	return out
+}
+
+// compose applies canonical composition to the decomposed runes in place.
+func compose(runes []rune) []rune {
+	compositionsOnce.Do(initCompositions)
+	if len(runes) == 0 {
+		return runes
+	}
+	out := runes[:1]
+	starter := 0
+	if combiningClass(runes[0]) != 0 {
+		starter = -1
+	}
+	lastClass := -1
+	for _, r := range runes[1:] {
+		class := int(combiningClass(r))
+		if starter >= 0 && lastClass < class {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+			lastClass = -1
+		} else {
+			lastClass = class
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// composePair returns the primary composite of a and b, if any.
+func composePair(a, b rune) (rune, bool) {
+	if l := a - hangulLBase; l >= 0 && l < 19 {
+		if v := b - hangulVBase; v >= 0 && v < 21 {
+			return hangulBase + (l*21+v)*hangulTCount, true
+		}
+	}
+	if s := a - hangulBase; s >= 0 && s < hangulCount && s%hangulTCount == 0 {
+		if t := b - hangulTBase; t > 0 && t < hangulTCount {
+			return a + t, true
+		}
+	}
+	c, ok := compositions[[2]rune{a, b}]
+	return c, ok
+}
+
+// initCompositions builds the table of primary composites from the
+// two-character decompositions that are not excluded from composition.
+func initCompositions() {
+	excluded := make(map[rune]bool, len(compositionExclusions))
+	for _, r := range compositionExclusions {
+		excluded[r] = true
+	}
+	compositions = make(map[[2]rune]rune, len(canonicalDecompositions))
+	for r, d := range canonicalDecompositions {
+		if len(d) == 2 && !excluded[r] {
+			compositions[[2]rune{d[0], d[1]}] = r
+		}
+	}
+}
+
+// combiningClass returns the canonical combining class of r, zero for
+// starters.
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool { return combiningClasses[i][1] >= r })
+	if i < len(combiningClasses) && combiningClasses[i][0] <= r {
+		return uint8(combiningClasses[i][2])
+	}
+	return 0
+}
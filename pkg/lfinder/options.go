@@ -0,0 +1,290 @@
+package lfinder
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// Option configures a Finder created by NewFinder.
+type Option func(*Finder)
+
+// NewFinder returns a Finder configured by opts. Without options it searches
+// the whole host file system from "/" for both symlinks and hard links.
+func NewFinder(opts ...Option) *Finder {
+	f := &Finder{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithRoot sets the path to start the search from.
+func WithRoot(root string) Option {
+	return func(f *Finder) { f.Root = root }
+}
+
+// WithRoots adds paths to search along with the root.
+func WithRoots(roots ...string) Option {
+	return func(f *Finder) { f.Roots = append(f.Roots, roots...) }
+}
+
+// WithWorkers sets the number of goroutines examining files concurrently.
+func WithWorkers(n int) Option {
+	return func(f *Finder) { f.Workers = n }
+}
+
+// WithEngine selects how directories are read.
+func WithEngine(e Engine) Option {
+	return func(f *Finder) { f.Engine = e }
+}
+
+// WithAdaptiveWorkers resizes the pool of goroutines examining files during
+// the search to the latency of the storage.
+func WithAdaptiveWorkers() Option {
+	return func(f *Finder) { f.AdaptiveWorkers = true }
+}
+
+// WithQueueSize sets the number of candidates and results queued between
+// the walk, the workers, and the caller.
+func WithQueueSize(n int) Option {
+	return func(f *Finder) { f.QueueSize = n }
+}
+
+// WithMaxMemory holds the walk while the heap exceeds n bytes.
+func WithMaxMemory(n int64) Option {
+	return func(f *Finder) { f.MaxMemory = n }
+}
+
+// WithIOLimit bounds the I/O of the search.
+func WithIOLimit(l IOLimit) Option {
+	return func(f *Finder) { f.IOLimit = l }
+}
+
+// WithNice runs the search in the background, easy on other work.
+func WithNice() Option {
+	return func(f *Finder) { f.Nice = true }
+}
+
+// WithSkipDirs adds directories whose subtrees are not searched, as paths or
+// patterns.
+func WithSkipDirs(dirs ...string) Option {
+	return func(f *Finder) { f.SkipDirs = append(f.SkipDirs, dirs...) }
+}
+
+// WithTimeout bounds the duration of each search. Zero means no limit.
+func WithTimeout(d time.Duration) Option {
+	return func(f *Finder) { f.Timeout = d }
+}
+
+// WithMaxResults stops the search after n results. Zero means no limit.
+func WithMaxResults(n int) Option {
+	return func(f *Finder) { f.MaxResults = n }
+}
+
+// WithSymlinksOnly restricts the search to symbolic links.
+func WithSymlinksOnly() Option {
+	return func(f *Finder) { f.SymlinksOnly, f.HardlinksOnly = true, false }
+}
+
+// WithHardlinksOnly restricts the search to hard links.
+func WithHardlinksOnly() Option {
+	return func(f *Finder) { f.SymlinksOnly, f.HardlinksOnly = false, true }
+}
+
+// WithInto also matches symbolic links resolving to any path below a
+// target directory.
+func WithInto() Option {
+	return func(f *Finder) { f.Into = true }
+}
+
+// WithCaseInsensitive compares the paths of links and targets regardless
+// of case.
+func WithCaseInsensitive() Option {
+	return func(f *Finder) { f.CaseInsensitive = true }
+}
+
+// WithNormalization compares the paths of links and targets in the Unicode
+// normalization form n.
+func WithNormalization(n Normalization) Option {
+	return func(f *Finder) { f.Normalization = n }
+}
+
+// WithTypes restricts the candidates examined to files of the given types.
+func WithTypes(types ...FileType) Option {
+	return func(f *Finder) { f.Types = types }
+}
+
+// WithDepth bounds the depth below each root of the candidates examined to
+// between min and max. A zero max means no limit.
+func WithDepth(min, max int) Option {
+	return func(f *Finder) { f.MinDepth, f.MaxDepth = min, max }
+}
+
+// WithSize bounds the size in bytes of the candidates examined, other than
+// directories and symbolic links, to between min and max. A zero max means
+// no limit.
+func WithSize(min, max int64) Option {
+	return func(f *Finder) { f.MinSize, f.MaxSize = min, max }
+}
+
+// WithModTime restricts the candidates examined to those modified after
+// newer and before older. A zero time sets no bound.
+func WithModTime(newer, older time.Time) Option {
+	return func(f *Finder) { f.NewerThan, f.OlderThan = newer, older }
+}
+
+// WithOwner restricts the candidates examined to those whose owner and
+// group are selected by owner and group.
+func WithOwner(owner, group IDFilter) Option {
+	return func(f *Finder) { f.Owner, f.Group = owner, group }
+}
+
+// WithPerm restricts the candidates examined to those whose permissions are
+// selected by perm, in a directory whose permissions are selected by dirPerm.
+func WithPerm(perm, dirPerm PermFilter) Option {
+	return func(f *Finder) { f.Perm, f.DirPerm = perm, dirPerm }
+}
+
+// WithMinNlink restricts the candidates examined, other than directories, to
+// those with at least n hard links.
+func WithMinNlink(n uint64) Option {
+	return func(f *Finder) { f.MinNlink = n }
+}
+
+// WithGitignore passes over the paths ignored by .gitignore and .ignore
+// files.
+func WithGitignore() Option {
+	return func(f *Finder) { f.Gitignore = true }
+}
+
+// WithMarkers adds the names of marker files whose presence in a directory
+// keeps it from being searched.
+func WithMarkers(names ...string) Option {
+	return func(f *Finder) { f.Markers = append(f.Markers, names...) }
+}
+
+// WithDirTimeout bounds the time spent walking the subtree of each directory
+// below a root.
+func WithDirTimeout(d time.Duration) Option {
+	return func(f *Finder) { f.DirTimeout = d }
+}
+
+// WithSkipHidden passes over the files and directories whose name starts
+// with a dot.
+func WithSkipHidden() Option {
+	return func(f *Finder) { f.SkipHidden = true }
+}
+
+// WithInclude restricts the candidates examined, other than directories, to
+// the paths matching one of patterns.
+func WithInclude(patterns ...*regexp.Regexp) Option {
+	return func(f *Finder) { f.Include = patterns }
+}
+
+// WithExclude passes over the paths matching one of patterns.
+func WithExclude(patterns ...*regexp.Regexp) Option {
+	return func(f *Finder) { f.Exclude = patterns }
+}
+
+// WithFS sets the file system to search.
+func WithFS(fsys FS) Option {
+	return func(f *Finder) { f.FS = fsys }
+}
+
+// WithProgress calls fn with a Progress snapshot every interval while a
+// search runs. A zero interval selects DefaultProgressInterval.
+func WithProgress(fn func(Progress), interval time.Duration) Option {
+	return func(f *Finder) { f.OnProgress, f.ProgressInterval = fn, interval }
+}
+
+// WithLogger sets the logger receiving diagnostics about the search.
+func WithLogger(l *slog.Logger) Option {
+	return func(f *Finder) { f.Logger = l }
+}
+
+// WithDedup drops results whose device, inode, and path were already
+// reported during the same search.
+func WithDedup() Option {
+	return func(f *Finder) { f.Dedup = true }
+}
+
+// WithSameContent also matches regular files whose contents are identical to
+// a target's.
+func WithSameContent() Option {
+	return func(f *Finder) { f.SameContent = true }
+}
+
+// WithVerifyContent compares files matched by WithSameContent byte by byte.
+func WithVerifyContent() Option {
+	return func(f *Finder) { f.VerifyContent = true }
+}
+
+// WithReflinks also matches regular files sharing storage with a target.
+func WithReflinks() Option {
+	return func(f *Finder) { f.Reflinks = true }
+}
+
+// WithShortcuts also finds Windows shell links (.lnk files) leading to the
+// targets.
+func WithShortcuts() Option {
+	return func(f *Finder) { f.Shortcuts = true }
+}
+
+// WithAliases also finds macOS Finder alias files leading to the targets.
+func WithAliases() Option {
+	return func(f *Finder) { f.Aliases = true }
+}
+
+// WithLinkType restricts the results to symbolic links storing an absolute
+// or a relative destination.
+func WithLinkType(t LinkType) Option {
+	return func(f *Finder) { f.LinkType = t }
+}
+
+// WithMaxChain bounds the number of links followed when expanding the chain
+// of a symlink resolving through other symlinks.
+func WithMaxChain(n int) Option {
+	return func(f *Finder) { f.MaxChain = n }
+}
+
+// WithFollow selects the symbolic links that are followed.
+func WithFollow(m FollowMode) Option {
+	return func(f *Finder) { f.Follow = m }
+}
+
+// WithOneFileSystem keeps the search on the file system of each search root.
+func WithOneFileSystem() Option {
+	return func(f *Finder) { f.OneFileSystem = true }
+}
+
+// WithFirmlinks sets the directories that appear at two paths, as returned
+// by Firmlinks.
+func WithFirmlinks(firmlinks ...Firmlink) Option {
+	return func(f *Finder) { f.Firmlinks = firmlinks }
+}
+
+// WithSkipNetwork does not descend into the mount points of network file
+// systems listed in Finder.Mounts.
+func WithSkipNetwork() Option {
+	return func(f *Finder) { f.SkipNetwork = true }
+}
+
+// WithFSTypes selects the file systems listed in Finder.Mounts that are
+// descended into by type: those matching one of include, if any, and none
+// of exclude.
+func WithFSTypes(include, exclude []string) Option {
+	return func(f *Finder) { f.IncludeFSTypes, f.ExcludeFSTypes = include, exclude }
+}
+
+// WithMounts sets the file systems mounted on the host, as returned by
+// Mounts, to recognize bind mounts.
+func WithMounts(mounts ...Mount) Option {
+	return func(f *Finder) { f.Mounts = mounts }
+}
+
+// WithSkipLoops passes over symbolic links caught in a loop instead of
+// reporting them as errors.
+func WithSkipLoops() Option {
+	return func(f *Finder) { f.SkipLoops = true }
+}
@@ -0,0 +1,16 @@
+package lfinder
+
+import "slices"
+
+// IDFilter selects files by their numeric owner or group.
+type IDFilter struct {
+	// IDs lists the user or group IDs selected.
+	IDs []uint32
+	// Not selects the files whose ID is none of IDs instead.
+	Not bool
+}
+
+// Match reports whether id is selected. An empty filter selects every ID.
+func (f IDFilter) Match(id uint32) bool {
+	return len(f.IDs) == 0 || slices.Contains(f.IDs, id) != f.Not
+}
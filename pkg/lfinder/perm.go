@@ -0,0 +1,160 @@
+package lfinder
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// PermMatch is how a PermFilter compares permission bits.
+type PermMatch int
+
+// Permission matches, as written in find -perm.
+const (
+	// PermNone selects every file.
+	PermNone PermMatch = iota
+	// PermExact selects the files whose permissions are exactly the bits,
+	// as with -perm mode.
+	PermExact
+	// PermAll selects the files having all of the bits set, as with
+	// -perm -mode.
+	PermAll
+	// PermAny selects the files having any of the bits set, as with
+	// -perm /mode.
+	PermAny
+)
+
+// PermFilter selects files by permission bits, as find -perm does.
+type PermFilter struct {
+	// Bits are the permission bits, as given to chmod(1): 0755 for rwxr-xr-x,
+	// with 04000, 02000, and 01000 for setuid, setgid, and sticky.
+	Bits uint32
+	// How is how the permissions of a file compare to Bits. The zero
+	// filter selects every file.
+	How PermMatch
+}
+
+// ParsePerm parses a permission filter as accepted by find -perm: an octal
+// mode such as 644 or a symbolic one such as u=rw,go=r, prefixed by - to
+// select the files having all of its bits set, or by / to select those
+// having any of them set, as in -o+w or /6000.
+func ParsePerm(s string) (PermFilter, error) {
+	p := PermFilter{How: PermExact}
+	mode := s
+	switch {
+	case strings.HasPrefix(s, "-"):
+		p.How, mode = PermAll, s[1:]
+	case strings.HasPrefix(s, "/"):
+		p.How, mode = PermAny, s[1:]
+	}
+	if mode != "" && strings.Trim(mode, "01234567") == "" {
+		bits, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || bits > 0o7777 {
+			return PermFilter{}, fmt.Errorf("invalid mode %q", s)
+		}
+		p.Bits = uint32(bits)
+		return p, nil
+	}
+	bits, err := parseSymbolicMode(mode)
+	if err != nil {
+		return PermFilter{}, fmt.Errorf("invalid mode %q: %v", s, err)
+	}
+	p.Bits = bits
+	return p, nil
+}
+
+// parseSymbolicMode applies a symbolic mode, such as u+rwx,go=rx, to a mode
+// with no bit set, as find -perm does.
+func parseSymbolicMode(s string) (uint32, error) {
+	var mode uint32
+	for _, clause := range strings.Split(s, ",") {
+		i := strings.IndexAny(clause, "+-=")
+		if i < 0 {
+			return 0, fmt.Errorf("missing +, -, or = in %q", clause)
+		}
+		var who uint32
+		for _, c := range clause[:i] {
+			switch c {
+			case 'u':
+				who |= 0o4700
+			case 'g':
+				who |= 0o2070
+			case 'o':
+				who |= 0o1007
+			case 'a':
+				who |= 0o7777
+			default:
+				return 0, fmt.Errorf("unknown class %q", c)
+			}
+		}
+		if who == 0 {
+			who = 0o7777
+		}
+		// A clause can hold several operations, as in u+r-w.
+		for rest := clause[i:]; rest != ""; {
+			op := rest[0]
+			j := strings.IndexAny(rest[1:], "+-=") + 1
+			if j == 0 {
+				j = len(rest)
+			}
+			var perm uint32
+			for _, c := range rest[1:j] {
+				switch c {
+				case 'r':
+					perm |= 0o444
+				case 'w':
+					perm |= 0o222
+				case 'x', 'X':
+					perm |= 0o111
+				case 's':
+					perm |= 0o6000
+				case 't':
+					perm |= 0o1000
+				default:
+					return 0, fmt.Errorf("unknown permission %q", c)
+				}
+			}
+			perm &= who
+			switch op {
+			case '+':
+				mode |= perm
+			case '-':
+				mode &^= perm
+			case '=':
+				mode = mode&^who | perm
+			}
+			rest = rest[j:]
+		}
+	}
+	return mode, nil
+}
+
+// Match reports whether a file with mode is selected.
+func (p PermFilter) Match(mode fs.FileMode) bool {
+	bits := unixPerm(mode)
+	switch p.How {
+	case PermExact:
+		return bits == p.Bits
+	case PermAll:
+		return bits&p.Bits == p.Bits
+	case PermAny:
+		return p.Bits == 0 || bits&p.Bits != 0
+	}
+	return true
+}
+
+// unixPerm returns the permission bits of mode as chmod(1) numbers them.
+func unixPerm(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
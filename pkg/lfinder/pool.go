@@ -0,0 +1,332 @@
+package lfinder
+
+import (
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultWorkers returns the number of workers for a search of roots when
+// Workers is not set: twice GOMAXPROCS, and at least DefaultWorkers, for
+// local storage; four times as many when a root lies on a network file
+// system, whose round trips keep each goroutine waiting; and half of
+// DefaultWorkers when every root lies on a spinning disk, which more
+// concurrent requests only make seek. A Nice search has niceWorkers.
+func (f *Finder) defaultWorkers(roots []string) int {
+	if f.Nice {
+		return niceWorkers
+	}
+	n := max(DefaultWorkers, 2*runtime.GOMAXPROCS(0))
+	spinning := len(f.Mounts) > 0
+	for _, root := range roots {
+		m, ok := mountOf(f.Mounts, root)
+		if !ok {
+			spinning = false
+			continue
+		}
+		if m.Network() {
+			return 4 * n
+		}
+		spinning = spinning && rotational(m)
+	}
+	if spinning {
+		return DefaultWorkers / 2
+	}
+	return n
+}
+
+// deviceWorkers returns the number of workers of the pool for the file
+// system m: Workers if set, or else as defaultWorkers picks it for a root
+// on m.
+func (f *Finder) deviceWorkers(m Mount) int {
+	if f.Workers > 0 {
+		return f.Workers
+	}
+	if f.Nice {
+		return niceWorkers
+	}
+	n := max(DefaultWorkers, 2*runtime.GOMAXPROCS(0))
+	switch {
+	case m.Network():
+		return 4 * n
+	case rotational(m):
+		return DefaultWorkers / 2
+	}
+	return n
+}
+
+// deviceMounts returns the mount points of Mounts below roots, by their path
+// in the walk, which is relative when the root is, if the trees below roots
+// span several devices, which calls for a worker pool per device; or nil if
+// they do not. A mount hides the earlier ones at the same mount point.
+func (f *Finder) deviceMounts(roots []string) map[string]Mount {
+	devs := make(map[uint64]bool)
+	mounts := make(map[string]Mount)
+	for _, root := range roots {
+		if m, ok := mountOf(f.Mounts, root); ok {
+			devs[m.Device] = true
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		for _, m := range f.Mounts {
+			if rel, err := filepath.Rel(abs, m.Path); err == nil && within(m.Path, abs) {
+				devs[m.Device] = true
+				mounts[filepath.Join(root, rel)] = m
+			}
+		}
+	}
+	if len(devs) < 2 {
+		return nil
+	}
+	return mounts
+}
+
+// mountOf returns the mount of mounts that path lies on: the last one at the
+// longest mount point containing it.
+func mountOf(mounts []Mount, path string) (Mount, bool) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Mount{}, false
+	}
+	var found Mount
+	ok := false
+	for _, m := range mounts {
+		if within(path, m.Path) && (!ok || len(m.Path) >= len(found.Path)) {
+			found, ok = m, true
+		}
+	}
+	return found, ok
+}
+
+const (
+	// adaptInterval is how often an adaptive pool is resized.
+	adaptInterval = 250 * time.Millisecond
+	// maxAdaptiveWorkers bounds the size of an adaptive pool.
+	maxAdaptiveWorkers = 256
+	// slowStat is the mean Lstat latency above which the workers are taken
+	// to wait on storage rather than on the CPU: page cache hits take a few
+	// microseconds.
+	slowStat = 100 * time.Microsecond
+	// adaptHold is the number of intervals an adaptive pool keeps its size
+	// after growing did not pay off.
+	adaptHold = 8
+)
+
+// statClock measures the latency of the Lstat calls of the workers, for an
+// adaptive pool.
+type statClock struct {
+	calls atomic.Int64
+	total atomic.Int64 // nanoseconds
+}
+
+// record records a call that started at start.
+func (c *statClock) record(start time.Time) {
+	c.calls.Add(1)
+	c.total.Add(int64(time.Since(start)))
+}
+
+// reset returns the number of calls recorded and their total duration, and
+// starts over.
+func (c *statClock) reset() (int64, time.Duration) {
+	return c.calls.Swap(0), time.Duration(c.total.Swap(0))
+}
+
+// poolSizer sizes an adaptive worker pool by hill climbing. While the
+// workers wait on storage, the pool grows, as long as each step raises the
+// rate of Lstat calls by a tenth: past the concurrency the device serves,
+// more workers only lengthen its queue, so the last step is then undone.
+// Once the calls are served from the cache, the pool shrinks back to its
+// initial size.
+type poolSizer struct {
+	base, size int
+	prev       int     // size before the last growth
+	rate       float64 // calls per second over the last interval
+	grew       bool    // the last interval followed a growth
+	hold       int     // intervals left before growing again
+}
+
+// next returns the size of the pool after an interval of d in which calls
+// took total.
+func (p *poolSizer) next(calls int64, total, d time.Duration) int {
+	if calls == 0 {
+		// The workers wait on the walker.
+		return p.size
+	}
+	latency := total / time.Duration(calls)
+	rate := float64(calls) / d.Seconds()
+	grew, prev := p.grew, p.rate
+	p.grew, p.rate = false, rate
+	switch {
+	case grew && rate < prev*1.1:
+		p.size, p.hold = p.prev, adaptHold
+	case latency < slowStat && p.size > p.base:
+		p.size = max(p.base, p.size-max(1, p.size/4))
+	case p.hold > 0:
+		p.hold--
+	case latency >= slowStat && p.size < maxAdaptiveWorkers:
+		p.prev, p.grew = p.size, true
+		p.size = min(maxAdaptiveWorkers, p.size+max(1, p.size/2))
+	}
+	return p.size
+}
+
+// workerPool is a pool of goroutines examining the candidates sent on jobs.
+type workerPool struct {
+	jobs chan job
+	// quit retires a worker when sent on.
+	quit chan struct{}
+	// clock measures the Lstat calls of the workers; nil unless
+	// AdaptiveWorkers is set.
+	clock *statClock
+}
+
+// pools holds the worker pools of a search: a single one, or with several
+// devices searched, one per device, started as the walk reaches it, so that
+// a slow device, such as an NFS mount, does not starve the others of
+// workers. Every pool sends its matches on results.
+type pools struct {
+	s       *search
+	results chan<- Result
+	wg      *sync.WaitGroup
+	walked  <-chan struct{} // closed once the walk is over
+	// mounts holds the mount points, by path, with a pool per device.
+	mounts map[string]Mount
+
+	// single is the pool of a search of a single device.
+	single *workerPool
+
+	mu    sync.RWMutex
+	byDev map[uint64]*workerPool
+}
+
+// newPools returns the pools of s for a search of roots by workers
+// goroutines, whose workers are counted in wg.
+func (s *search) newPools(roots []string, workers int, results chan<- Result, wg *sync.WaitGroup, walked <-chan struct{}) *pools {
+	p := &pools{s: s, results: results, wg: wg, walked: walked, mounts: s.deviceMounts(roots)}
+	if p.mounts == nil {
+		p.single = p.start(workers)
+	} else {
+		p.byDev = make(map[uint64]*workerPool)
+	}
+	return p
+}
+
+// start starts a pool of n workers.
+func (p *pools) start(n int) *workerPool {
+	wp := &workerPool{jobs: make(chan job, p.s.queueSize()), quit: make(chan struct{})}
+	start := func() { p.wg.Go(func() { p.s.worker(wp, p.results) }) }
+	for range n {
+		start()
+	}
+	if p.s.AdaptiveWorkers {
+		wp.clock = &statClock{}
+		p.wg.Go(func() { p.s.adaptWorkers(wp, n, start, p.walked) })
+	}
+	return wp
+}
+
+// label labels the directories with the mount they lie on, with a pool per
+// device, as a labelFunc: a mount point with its mount, a root with the
+// mount holding it, and other directories with the label of their parent.
+// Links followed to another device are thus examined by the pool of the
+// device of the link.
+func (p *pools) label(parent any, root, path string, d fs.DirEntry) any {
+	if p.mounts == nil {
+		return nil
+	}
+	if m, ok := p.mounts[path]; ok {
+		return m
+	}
+	if parent == nil {
+		m, _ := mountOf(p.s.Mounts, path)
+		return m
+	}
+	return parent
+}
+
+// pool returns the pool examining the entries labeled with label, starting
+// it if needed.
+func (p *pools) pool(label any) *workerPool {
+	if p.single != nil {
+		return p.single
+	}
+	m, _ := label.(Mount)
+	p.mu.RLock()
+	wp, ok := p.byDev[m.Device]
+	p.mu.RUnlock()
+	if ok {
+		return wp
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if wp, ok = p.byDev[m.Device]; !ok {
+		n := p.s.deviceWorkers(m)
+		p.s.log.Info("starting worker pool", "mount", m.Path, "type", m.Type, "workers", n)
+		wp = p.start(n)
+		p.byDev[m.Device] = wp
+	}
+	return wp
+}
+
+// queued returns the number of candidates waiting for the workers of every
+// pool, and of results waiting for the caller.
+func (p *pools) queued() int {
+	n := len(p.results)
+	if p.single != nil {
+		return n + len(p.single.jobs)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, wp := range p.byDev {
+		n += len(wp.jobs)
+	}
+	return n
+}
+
+// close closes the jobs of every pool, once the walk is over.
+func (p *pools) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.single != nil {
+		close(p.single.jobs)
+	}
+	for _, wp := range p.byDev {
+		close(wp.jobs)
+	}
+}
+
+// adaptWorkers resizes the pool wp of n workers every adaptInterval until
+// done is closed, starting workers with start.
+func (s *search) adaptWorkers(wp *workerPool, n int, start func(), done <-chan struct{}) {
+	sizer := &poolSizer{base: n, size: n}
+	ticker := time.NewTicker(adaptInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		calls, total := wp.clock.reset()
+		size := sizer.next(calls, total, adaptInterval)
+		if size == n {
+			continue
+		}
+		s.log.Debug("resizing worker pool", "workers", size, "stats", calls, "latency", total/time.Duration(max(calls, 1)))
+		for ; n < size; n++ {
+			start()
+		}
+		for ; n > size; n-- {
+			select {
+			case wp.quit <- struct{}{}:
+			case <-done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package lfinder
+
+import "time"
+
+const (
+	// pressureInterval is how often the I/O pressure is measured for a
+	// Nice search.
+	pressureInterval = 500 * time.Millisecond
+	// highPressure and lowPressure are the shares of time some tasks of
+	// the system stalled on I/O above which the operations of a Nice
+	// search are slowed down, and below which they are sped up again.
+	highPressure = 0.10
+	lowPressure  = 0.02
+	// minPause and maxPause bound the pause before each operation of a
+	// Nice search under I/O pressure.
+	minPause = time.Millisecond
+	maxPause = 100 * time.Millisecond
+)
+
+// nextPause returns the pause before each operation after an interval in
+// which tasks stalled on I/O for the share stalled of the time: doubled
+// while the pressure stays high, and halved, down to none, once it is low.
+func nextPause(pause time.Duration, stalled float64) time.Duration {
+	switch {
+	case stalled > highPressure:
+		return min(maxPause, max(minPause, 2*pause))
+	case stalled < lowPressure && pause <= minPause:
+		return 0
+	case stalled < lowPressure:
+		return pause / 2
+	}
+	return pause
+}
+
+// watchPressure paces the operations of a Nice search by the I/O pressure
+// of the system, as told by ioStall, until done is closed. Where it cannot
+// be measured, the search is left alone.
+func (s *search) watchPressure(done <-chan struct{}) {
+	last, err := ioStall()
+	if err != nil {
+		s.log.Info("I/O pressure unavailable, not pacing the search by it", "err", err)
+		return
+	}
+	if s.io == nil {
+		s.io = &ioGate{done: done}
+	}
+	g := s.io
+	go func() {
+		ticker := time.NewTicker(pressureInterval)
+		defer ticker.Stop()
+		then := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			stall, err := ioStall()
+			if err != nil {
+				return
+			}
+			now := time.Now()
+			stalled := float64(stall-last) / float64(now.Sub(then))
+			last, then = stall, now
+			prev := time.Duration(g.pause.Load())
+			if pause := nextPause(prev, stalled); pause != prev {
+				s.log.Debug("I/O pressure changed, pacing the search", "stalled", stalled, "pause", pause)
+				g.pause.Store(int64(pause))
+			}
+		}
+	}()
+}
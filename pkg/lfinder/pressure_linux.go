@@ -0,0 +1,41 @@
+package lfinder
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ioStall returns the total time some tasks of the system have stalled on
+// I/O, from the "some" line of /proc/pressure/io, which kernels built with
+// pressure stall information provide.
+func ioStall() (time.Duration, error) {
+	f, err := os.Open("/proc/pressure/io")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if v, ok := strings.CutPrefix(field, "total="); ok {
+				us, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return 0, err
+				}
+				return time.Duration(us) * time.Microsecond, nil
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no total I/O stall time in /proc/pressure/io")
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package lfinder
+
+import (
+	"errors"
+	"time"
+)
+
+// ioStall is not implemented on this platform.
+func ioStall() (time.Duration, error) {
+	return 0, errors.ErrUnsupported
+}
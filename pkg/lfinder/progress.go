@@ -0,0 +1,166 @@
+package lfinder
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProgressInterval is how often Finder.OnProgress is called when
+// Finder.ProgressInterval is not set.
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// Progress is a snapshot of a running search, passed to Finder.OnProgress.
+type Progress struct {
+	// DirsVisited is the number of directories entered by the walker.
+	DirsVisited int64
+	// FilesExamined is the number of non-directory entries examined.
+	FilesExamined int64
+	// Matches is the number of results produced so far.
+	Matches int64
+	// Symlinks, Hardlinks, and BrokenLinks count the results of each of
+	// the built-in kinds.
+	Symlinks    int64
+	Hardlinks   int64
+	BrokenLinks int64
+	// Errors is the number of paths that could not be examined.
+	Errors int64
+	// SkippedDirs is the number of directories not descended into because
+	// they are listed in Finder.SkipDirs or, with Finder.OneFileSystem,
+	// belong to another file system.
+	SkippedDirs int64
+	// AbandonedDirs is the number of directories whose walk was given up
+	// after Finder.DirTimeout.
+	AbandonedDirs int64
+	// SkippedMounts lists the mount points not descended into because of
+	// Finder.SkipNetwork or the file system types selected, which
+	// SkippedDirs counts too.
+	SkippedMounts []string
+	// CurrentPath is the directory the walker entered most recently.
+	CurrentPath string
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration
+	// EntriesPerSec is the average number of entries (directories and files)
+	// processed per second.
+	EntriesPerSec float64
+	// Done is set on the final snapshot, sent once the search has stopped.
+	Done bool
+	// Complete is set once every hard link of the targets has been found,
+	// as counted by their link counts, which stops a search for hard links
+	// only without walking the rest of the trees.
+	Complete bool
+}
+
+// MarshalJSON encodes p as an object with snake_case members, giving
+// Elapsed as a duration string such as "1.5s".
+func (p Progress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DirsVisited   int64    `json:"dirs_visited"`
+		FilesExamined int64    `json:"files_examined"`
+		Matches       int64    `json:"matches"`
+		Symlinks      int64    `json:"symlinks"`
+		Hardlinks     int64    `json:"hardlinks"`
+		BrokenLinks   int64    `json:"broken_links"`
+		Errors        int64    `json:"errors"`
+		SkippedDirs   int64    `json:"skipped_dirs"`
+		AbandonedDirs int64    `json:"abandoned_dirs"`
+		SkippedMounts []string `json:"skipped_mounts,omitempty"`
+		CurrentPath   string   `json:"current_path,omitempty"`
+		Elapsed       string   `json:"elapsed"`
+		EntriesPerSec float64  `json:"entries_per_sec"`
+		Done          bool     `json:"done"`
+		Complete      bool     `json:"complete"`
+	}{p.DirsVisited, p.FilesExamined, p.Matches, p.Symlinks, p.Hardlinks, p.BrokenLinks,
+		p.Errors, p.SkippedDirs, p.AbandonedDirs, p.SkippedMounts, p.CurrentPath, p.Elapsed.String(), p.EntriesPerSec, p.Done, p.Complete})
+}
+
+// counters tracks the progress of a single search. Its fields are updated
+// concurrently by the walker and the workers.
+type counters struct {
+	start     time.Time
+	dirs      atomic.Int64
+	files     atomic.Int64
+	matches   atomic.Int64
+	symlinks  atomic.Int64
+	hardlinks atomic.Int64
+	broken    atomic.Int64
+	errors    atomic.Int64
+	skipped   atomic.Int64
+	abandoned atomic.Int64
+	current   atomic.Pointer[string]
+	complete  atomic.Bool
+
+	mu     sync.Mutex
+	mounts []string // mount points skipped
+}
+
+func newCounters() *counters {
+	return &counters{start: time.Now()}
+}
+
+// match counts a result of the given kind.
+func (c *counters) match(kind Kind) {
+	c.matches.Add(1)
+	switch kind {
+	case KindSymlink:
+		c.symlinks.Add(1)
+	case KindHardlink:
+		c.hardlinks.Add(1)
+	case KindBroken:
+		c.broken.Add(1)
+	}
+}
+
+// skipMount records that the mount point path was not descended into.
+func (c *counters) skipMount(path string) {
+	c.mu.Lock()
+	c.mounts = append(c.mounts, path)
+	c.mu.Unlock()
+}
+
+// snapshot returns the current state of c.
+func (c *counters) snapshot() Progress {
+	p := Progress{
+		DirsVisited:   c.dirs.Load(),
+		FilesExamined: c.files.Load(),
+		Matches:       c.matches.Load(),
+		Symlinks:      c.symlinks.Load(),
+		Hardlinks:     c.hardlinks.Load(),
+		BrokenLinks:   c.broken.Load(),
+		Errors:        c.errors.Load(),
+		SkippedDirs:   c.skipped.Load(),
+		AbandonedDirs: c.abandoned.Load(),
+		Elapsed:       time.Since(c.start),
+		Complete:      c.complete.Load(),
+	}
+	c.mu.Lock()
+	p.SkippedMounts = slices.Clone(c.mounts)
+	c.mu.Unlock()
+	if current := c.current.Load(); current != nil {
+		p.CurrentPath = *current
+	}
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.EntriesPerSec = float64(p.DirsVisited+p.FilesExamined) / secs
+	}
+	return p
+}
+
+// report calls fn with a snapshot of c every interval until stop is closed,
+// then once more with Done set.
+func (c *counters) report(fn func(Progress), interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fn(c.snapshot())
+		case <-stop:
+			p := c.snapshot()
+			p.Done = true
+			fn(p)
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package lfinder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// extent is a range of physical storage holding part of a file.
+type extent struct {
+	physical, length uint64
+	// shared is set when the range also belongs to another file, as it does
+	// after a copy-on-write clone.
+	shared bool
+}
+
+// reflinkIndex matches regular files sharing storage with a target, such as
+// the copy-on-write clones made by cp --reflink on btrfs or XFS, reported as
+// KindReflink, or by clonefile(2) on APFS, reported as KindClone. Only the
+// shared extents of the targets are kept: a target without any has no
+// clones, and candidates are only examined on the devices of the targets.
+type reflinkIndex struct {
+	targets []reflinkTarget
+	devices map[uint64]bool
+}
+
+// reflinkTarget is a target compared by storage.
+type reflinkTarget struct {
+	path    string
+	dev     uint64
+	extents []extent
+}
+
+// newReflinkIndex reads the extents of the regular targets. Extents can only
+// be read on the host file system, and only where the platform supports it.
+func newReflinkIndex(fsys FS, targets []string) (*reflinkIndex, error) {
+	switch fsys.(type) {
+	case OSFS, ChrootFS:
+	default:
+		return nil, errors.New("detecting reflinks: only supported on the host file system")
+	}
+	r := &reflinkIndex{devices: make(map[uint64]bool)}
+	for _, target := range targets {
+		info, err := fsys.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("accessing target file: %w", err)
+		}
+		dev, _, ok := fileID(info)
+		if !ok || !info.Mode().IsRegular() {
+			continue
+		}
+		extents, err := fileExtents(target)
+		if err != nil {
+			return nil, fmt.Errorf("detecting reflinks: %w", err)
+		}
+		shared := sharedExtents(extents)
+		if len(shared) == 0 {
+			continue
+		}
+		r.targets = append(r.targets, reflinkTarget{path: target, dev: dev, extents: shared})
+		r.devices[dev] = true
+	}
+	return r, nil
+}
+
+// match returns the first target sharing storage with the regular file at
+// path on device dev.
+func (r *reflinkIndex) match(path string, dev uint64) (string, bool, error) {
+	if !r.devices[dev] {
+		return "", false, nil
+	}
+	extents, err := fileExtents(path)
+	if err != nil {
+		return "", false, err
+	}
+	shared := sharedExtents(extents)
+	if len(shared) == 0 {
+		return "", false, nil
+	}
+	for _, t := range r.targets {
+		if t.dev == dev && overlap(t.extents, shared) {
+			return t.path, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// sharedExtents returns the extents of extents that are shared.
+func sharedExtents(extents []extent) []extent {
+	var shared []extent
+	for _, e := range extents {
+		if e.shared {
+			shared = append(shared, e)
+		}
+	}
+	return shared
+}
+
+// overlap reports whether a range of a intersects a range of b.
+func overlap(a, b []extent) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.physical < y.physical+y.length && y.physical < x.physical+x.length {
+				return true
+			}
+		}
+	}
+	return false
+}
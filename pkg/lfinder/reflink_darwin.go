@@ -0,0 +1,68 @@
+package lfinder
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fLog2PhysExt is F_LOG2PHYS_EXT, from sys/fcntl.h.
+const fLog2PhysExt = 65
+
+// sharedKind is the kind of the results of a reflinkIndex: APFS calls the
+// files made by clonefile(2) or cp -c clones.
+const sharedKind = KindClone
+
+// fileExtents returns the extents of the file at path, as reported by
+// fcntl F_LOG2PHYS_EXT, which maps a range of the file to the device. APFS
+// does not tell which ranges are shared with a clone, so every extent is
+// reported as possibly shared, and clones are told apart from other files by
+// their common physical ranges only.
+func fileExtents(path string) ([]extent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var extents []extent
+	for off := int64(0); off < info.Size(); {
+		// struct log2phys is packed: l2p_flags (uint32), then l2p_contigbytes
+		// and l2p_devoffset (off_t), which hold the length and the file
+		// offset of the range on input, and its length and device offset on
+		// output.
+		var l2p [20]byte
+		binary.LittleEndian.PutUint64(l2p[4:], uint64(info.Size()-off))
+		binary.LittleEndian.PutUint64(l2p[12:], uint64(off))
+		var errno syscall.Errno
+		err := conn.Control(func(fd uintptr) {
+			_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fLog2PhysExt, uintptr(unsafe.Pointer(&l2p[0])))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if errno != 0 {
+			if len(extents) > 0 {
+				// A hole, or data not yet allocated: the rest cannot be mapped.
+				return extents, nil
+			}
+			return nil, &os.PathError{Op: "fcntl F_LOG2PHYS_EXT", Path: path, Err: errno}
+		}
+		length := int64(binary.LittleEndian.Uint64(l2p[4:]))
+		if length <= 0 {
+			break
+		}
+		extents = append(extents, extent{physical: binary.LittleEndian.Uint64(l2p[12:]), length: uint64(length), shared: true})
+		off += length
+	}
+	return extents, nil
+}
@@ -0,0 +1,88 @@
+package lfinder
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// sharedKind is the kind of the results of a reflinkIndex.
+const sharedKind = KindReflink
+
+// FIEMAP ioctl, from linux/fiemap.h.
+const (
+	fsIocFiemap = 0xc020660b
+
+	fiemapExtentLast       = 0x1
+	fiemapExtentUnknown    = 0x2
+	fiemapExtentDelalloc   = 0x4
+	fiemapExtentDataInline = 0x200
+	fiemapExtentShared     = 0x2000
+
+	// fiemapBatch is the number of extents requested per ioctl.
+	fiemapBatch = 128
+)
+
+type fiemapHeader struct {
+	Start         uint64
+	Length        uint64
+	Flags         uint32
+	MappedExtents uint32
+	ExtentCount   uint32
+	_             uint32
+}
+
+type fiemapExtent struct {
+	Logical  uint64
+	Physical uint64
+	Length   uint64
+	_        [2]uint64
+	Flags    uint32
+	_        [3]uint32
+}
+
+// fileExtents returns the extents of the file at path, as reported by the
+// FIEMAP ioctl. Extents without a known physical location, such as data not
+// yet written out or stored inline in the inode, are left out.
+func fileExtents(path string) ([]extent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var req struct {
+		hdr     fiemapHeader
+		extents [fiemapBatch]fiemapExtent
+	}
+	var extents []extent
+	for start := uint64(0); ; {
+		req.hdr = fiemapHeader{Start: start, Length: ^uint64(0) - start, ExtentCount: fiemapBatch}
+		var errno syscall.Errno
+		err := conn.Control(func(fd uintptr) {
+			_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, fsIocFiemap, uintptr(unsafe.Pointer(&req)))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if errno != 0 {
+			return nil, &os.PathError{Op: "fiemap", Path: path, Err: errno}
+		}
+		if req.hdr.MappedExtents == 0 {
+			return extents, nil
+		}
+		for _, e := range req.extents[:req.hdr.MappedExtents] {
+			if e.Flags&(fiemapExtentUnknown|fiemapExtentDelalloc|fiemapExtentDataInline) == 0 {
+				extents = append(extents, extent{physical: e.Physical, length: e.Length, shared: e.Flags&fiemapExtentShared != 0})
+			}
+			if e.Flags&fiemapExtentLast != 0 {
+				return extents, nil
+			}
+			start = e.Logical + e.Length
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package lfinder
+
+import "errors"
+
+// sharedKind is the kind of the results of a reflinkIndex.
+const sharedKind = KindReflink
+
+// fileExtents is not implemented on this platform: the extents of a file
+// are not exposed through the standard library.
+func fileExtents(path string) ([]extent, error) {
+	return nil, errors.ErrUnsupported
+}
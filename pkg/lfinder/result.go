@@ -0,0 +1,136 @@
+package lfinder
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// Kind describes how a matched path refers to the target.
+type Kind string
+
+// Result kinds reported by the Finder.
+const (
+	KindSymlink  Kind = "symlink"
+	KindHardlink Kind = "hardlink"
+	// KindBroken marks a symlink whose destination does not exist.
+	KindBroken Kind = "broken"
+	// KindLoop marks a symlink whose resolution leads back to itself.
+	KindLoop Kind = "loop"
+	// KindEscape marks a symlink resolving to a path outside the tree it
+	// belongs to.
+	KindEscape Kind = "escape"
+	// KindDuplicate marks a separate file whose contents are identical to
+	// those of the target, a candidate for becoming a link.
+	KindDuplicate Kind = "duplicate"
+	// KindReflink marks a separate file sharing storage with the target,
+	// such as a copy-on-write clone made by cp --reflink.
+	KindReflink Kind = "reflink"
+	// KindClone marks a separate file sharing storage with the target on
+	// APFS, as made by clonefile(2) or cp -c on macOS.
+	KindClone Kind = "clone"
+	// KindShortcut marks a Windows shell link (.lnk file) leading to the
+	// target. Its LinkTarget is the destination it records.
+	KindShortcut Kind = "shortcut"
+	// KindAlias marks a macOS Finder alias file leading to the target. Its
+	// LinkTarget is the destination path it records.
+	KindAlias Kind = "alias"
+)
+
+// Result describes a single link to the target. Formatting is left to the
+// Sink that receives it.
+type Result struct {
+	// Path is the location of the link.
+	Path string `json:"path"`
+	// Kind is the type of link found at Path.
+	Kind Kind `json:"kind"`
+	// Target is the target file the link refers to, as passed to
+	// Finder.Find or Finder.Results. LinkMatcher sets it to the resolved
+	// destination of symlinks; it is empty for results of custom matchers.
+	Target string `json:"target,omitempty"`
+	// LinkTarget is the raw target of a symlink as stored on disk.
+	LinkTarget string `json:"link_target,omitempty"`
+	// Chain lists the links forming the loop of a KindLoop result, starting
+	// with Path and ending with the first link met twice. For a symlink
+	// resolving through other symlinks, it lists the links followed from
+	// Path and ends with the destination.
+	Chain []string `json:"chain,omitempty"`
+	// Depth is the number of links followed in Chain to reach the
+	// destination of a symlink resolving through other symlinks.
+	Depth int `json:"depth,omitempty"`
+	// Alias is another path to the file at Path through a macOS firmlink,
+	// such as /System/Volumes/Data/Users/me/f for /Users/me/f.
+	Alias string `json:"alias,omitempty"`
+	// BindMount is the mount point of the bind mount Path was reached
+	// through, if any.
+	BindMount string `json:"bind_mount,omitempty"`
+	// Inode and Device identify the file at Path (the link itself for
+	// symlinks). On Windows, they are its file index and the serial number
+	// of its volume.
+	Inode  uint64 `json:"inode"`
+	Device uint64 `json:"device"`
+	// Size and ModTime are taken from the Lstat of Path.
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Mode, Nlink, UID, and GID are also taken from the Lstat of Path:
+	// the type and permission bits, the number of hard links, and the
+	// numeric owner and group.
+	Mode  fs.FileMode `json:"-"`
+	Nlink uint64      `json:"nlink"`
+	UID   uint32      `json:"uid"`
+	GID   uint32      `json:"gid"`
+	// Err records a problem met while examining a path that otherwise matched,
+	// such as an unreadable link target.
+	Err error `json:"-"`
+}
+
+// MarshalJSON encodes r, representing Mode in ls -l notation and Err by its
+// message, and adding the LinkType of symbolic links.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	var errMsg string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return json.Marshal(struct {
+		result
+		LinkType LinkType `json:"link_type,omitempty"`
+		Mode     string   `json:"mode"`
+		Error    string   `json:"error,omitempty"`
+	}{result(r), r.LinkType(), lsMode(r.Mode), errMsg})
+}
+
+// LinkType classifies the destination stored in a symbolic link.
+type LinkType string
+
+// Link types reported by Result.LinkType.
+const (
+	// LinkAbsolute marks a link storing an absolute path, which breaks
+	// when the tree holding its destination is moved.
+	LinkAbsolute LinkType = "absolute"
+	// LinkRelative marks a link storing a path relative to its directory,
+	// which survives moving a tree holding both the link and its
+	// destination.
+	LinkRelative LinkType = "relative"
+)
+
+// LinkType returns whether the symbolic link r stores an absolute or a
+// relative path, or "" if r has no LinkTarget or is a shortcut or an alias.
+func (r Result) LinkType() LinkType {
+	switch {
+	case r.LinkTarget == "" || r.Kind == KindShortcut || r.Kind == KindAlias:
+		return ""
+	case filepath.IsAbs(r.LinkTarget):
+		return LinkAbsolute
+	}
+	return LinkRelative
+}
+
+// newResult returns a Result for path populated from its Lstat info.
+func newResult(path string, kind Kind, info fs.FileInfo) Result {
+	r := Result{Path: path, Kind: kind, Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode()}
+	r.Device, r.Inode, _ = fileID(info)
+	r.Nlink, r.UID, r.GID, _ = fileOwner(info)
+	return r
+}
@@ -0,0 +1,270 @@
+package lfinder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxShortcutSize bounds the data read from a .lnk file. Shell links are a
+// few kilobytes at most; larger files are not parsed past that.
+const maxShortcutSize = 64 << 10
+
+// shellLinkCLSID is the class identifier every shell link header holds.
+var shellLinkCLSID = []byte{0x01, 0x14, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+
+// Shell link flags, from [MS-SHLLINK] 2.1.1.
+const (
+	hasLinkTargetIDList = 1 << 0
+	hasLinkInfo         = 1 << 1
+	hasName             = 1 << 2
+	hasRelativePath     = 1 << 3
+	isUnicode           = 1 << 7
+
+	volumeIDAndLocalBasePath               = 1 << 0
+	commonNetworkRelativeLinkAndPathSuffix = 1 << 1
+)
+
+var errNotShortcut = errors.New("not a shell link")
+
+// isShortcut reports whether path names a Windows shell link.
+func isShortcut(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".lnk")
+}
+
+// shortcut is the destination recorded in a shell link.
+type shortcut struct {
+	// Target is the absolute path of the destination, on a local volume or
+	// a network share, as recorded in the LinkInfo structure.
+	Target string
+	// Relative is the path of the destination relative to the shell link.
+	Relative string
+}
+
+// readShortcut reads the shell link path on fsys.
+func readShortcut(fsys OpenFS, path string) (shortcut, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return shortcut{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxShortcutSize))
+	if err != nil {
+		return shortcut{}, err
+	}
+	return parseShortcut(data)
+}
+
+// parseShortcut decodes the destination of a shell link, as laid out in
+// [MS-SHLLINK]: the header, an optional item ID list, which is skipped, the
+// optional LinkInfo structure, and the string data holding the relative path.
+func parseShortcut(data []byte) (shortcut, error) {
+	if len(data) < 0x4c || binary.LittleEndian.Uint32(data) != 0x4c || !bytes.Equal(data[4:20], shellLinkCLSID) {
+		return shortcut{}, errNotShortcut
+	}
+	flags := binary.LittleEndian.Uint32(data[0x14:])
+	pos := 0x4c
+	if flags&hasLinkTargetIDList != 0 {
+		size, ok := uint16At(data, pos)
+		if !ok {
+			return shortcut{}, errNotShortcut
+		}
+		pos += 2 + int(size)
+	}
+
+	var s shortcut
+	if flags&hasLinkInfo != 0 {
+		size, ok := uint32At(data, pos)
+		if !ok || pos+int(size) > len(data) {
+			return shortcut{}, errNotShortcut
+		}
+		s.Target = parseLinkInfo(data[pos : pos+int(size)])
+		pos += int(size)
+	}
+
+	unicode := flags&isUnicode != 0
+	for _, flag := range []uint32{hasName, hasRelativePath} {
+		if flags&flag == 0 {
+			continue
+		}
+		str, n, ok := countedString(data, pos, unicode)
+		if !ok {
+			return shortcut{}, errNotShortcut
+		}
+		if flag == hasRelativePath {
+			s.Relative = str
+		}
+		pos += n
+	}
+	return s, nil
+}
+
+// parseLinkInfo returns the path recorded in a LinkInfo structure: its
+// local base path or network share name, followed by its common path
+// suffix.
+func parseLinkInfo(info []byte) string {
+	headerSize, _ := uint32At(info, 4)
+	flags, _ := uint32At(info, 8)
+	localOffset, _ := uint32At(info, 16)
+	networkOffset, _ := uint32At(info, 20)
+	suffixOffset, _ := uint32At(info, 24)
+
+	suffix := cString(info, int(suffixOffset))
+	if headerSize >= 0x24 {
+		if off, ok := uint32At(info, 32); ok && off != 0 {
+			suffix = utf16CString(info, int(off))
+		}
+	}
+
+	switch {
+	case flags&volumeIDAndLocalBasePath != 0:
+		base := cString(info, int(localOffset))
+		if headerSize >= 0x24 {
+			if off, ok := uint32At(info, 28); ok && off != 0 {
+				base = utf16CString(info, int(off))
+			}
+		}
+		return base + suffix
+	case flags&commonNetworkRelativeLinkAndPathSuffix != 0 && int(networkOffset) < len(info):
+		link := info[networkOffset:]
+		nameOffset, _ := uint32At(link, 8)
+		name := cString(link, int(nameOffset))
+		if nameOffset > 0x14 {
+			if off, ok := uint32At(link, 20); ok && off != 0 {
+				name = utf16CString(link, int(off))
+			}
+		}
+		if name == "" || suffix == "" {
+			return name
+		}
+		return name + `\` + suffix
+	}
+	return ""
+}
+
+func uint16At(b []byte, off int) (uint16, bool) {
+	if off < 0 || off+2 > len(b) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b[off:]), true
+}
+
+func uint32At(b []byte, off int) (uint32, bool) {
+	if off < 0 || off+4 > len(b) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b[off:]), true
+}
+
+// cString returns the NUL-terminated string at off in b, in the system
+// code page, read as Latin-1.
+func cString(b []byte, off int) string {
+	if off <= 0 || off >= len(b) {
+		return ""
+	}
+	b = b[off:]
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return latin1(b)
+}
+
+// latin1 decodes b as Latin-1.
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// utf16CString returns the NUL-terminated UTF-16 string at off in b.
+func utf16CString(b []byte, off int) string {
+	var u []uint16
+	for ; off > 0 && off+2 <= len(b); off += 2 {
+		c := binary.LittleEndian.Uint16(b[off:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// countedString returns a StringData string at off in b, prefixed by its
+// length in characters, and the number of bytes it takes.
+func countedString(b []byte, off int, unicode bool) (string, int, bool) {
+	count, ok := uint16At(b, off)
+	if !ok {
+		return "", 0, false
+	}
+	size := int(count)
+	if unicode {
+		size *= 2
+	}
+	if off+2+size > len(b) {
+		return "", 0, false
+	}
+	data := b[off+2 : off+2+size]
+	if !unicode {
+		return latin1(data), 2 + size, true
+	}
+	u := make([]uint16, count)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(u)), 2 + size, true
+}
+
+// destinations returns the host paths the shell link at path may lead to:
+// its target path, if absolute on this host, and its relative path, with
+// backslashes taken as separators. Shell links to virtual folders, such as
+// the Control Panel, have neither.
+func (s shortcut) destinations(path string) []string {
+	var paths []string
+	if s.Target != "" && filepath.IsAbs(s.Target) {
+		paths = append(paths, s.Target)
+	}
+	if s.Relative != "" {
+		rel := filepath.FromSlash(strings.ReplaceAll(s.Relative, `\`, "/"))
+		paths = append(paths, filepath.Join(filepath.Dir(path), rel))
+	}
+	return paths
+}
+
+// dest returns the destination of s as recorded in the shell link.
+func (s shortcut) dest() string {
+	if s.Target != "" {
+		return s.Target
+	}
+	return s.Relative
+}
+
+// matchShortcut matches the shell link path if it leads to a target.
+func (ts *targetSet) matchShortcut(path string, info fs.FileInfo) (Result, bool, error) {
+	s, err := readShortcut(ts.shortcuts, path)
+	if errors.Is(err, errNotShortcut) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, err
+	}
+	for _, dest := range s.destinations(path) {
+		resolved, err := ts.fsys.EvalSymlinks(dest)
+		if err != nil {
+			continue
+		}
+		if target, ok := ts.lookup(resolved); ok {
+			result := newResult(path, KindShortcut, info)
+			result.Target = target
+			result.LinkTarget = s.dest()
+			return result, true, nil
+		}
+	}
+	return Result{}, false, nil
+}
@@ -0,0 +1,553 @@
+package lfinder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sink receives the results of a search. Emit is called once per result, in
+// the order the results are produced; Flush is called once after the last
+// result so buffered output can be written.
+type Sink interface {
+	Emit(Result) error
+	Flush() error
+}
+
+// Copy emits every result of src to dst and flushes dst. It stops consuming
+// src, which stops the search, at the first error. It returns the number of
+// results emitted.
+func Copy(dst Sink, src iter.Seq[Result]) (int, error) {
+	n := 0
+	for result := range src {
+		if err := dst.Emit(result); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, dst.Flush()
+}
+
+// MultiSink returns a Sink that duplicates each result to all of sinks.
+func MultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (ms multiSink) Emit(r Result) error {
+	for _, s := range ms {
+		if err := s.Emit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms multiSink) Flush() error {
+	var errs []error
+	for _, s := range ms {
+		errs = append(errs, s.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// TextSink writes one line per result in the lfinder command's text format.
+// Lines are written as they are emitted unless w buffers them.
+type TextSink struct {
+	// ShowTarget adds the target each result refers to, for searches with
+	// several targets, e.g. "/usr/bin/vi (symlink to /usr/bin/vim) -> vim".
+	ShowTarget bool
+	// Color highlights each path by kind with ANSI escape sequences: cyan
+	// for symlinks, yellow for hard links, red for broken links, magenta
+	// for links caught in a loop, bold red for links escaping their tree,
+	// green for duplicates, bold green for reflinks and clones, blue for shortcuts, and
+	// bold blue for aliases.
+	Color bool
+	// Long prefixes each line with the metadata of the link, like ls -l:
+	// permissions, link count, owner, group, size, modification time, and
+	// device number. Symbolic links are marked absolute or relative.
+	Long bool
+
+	w     io.Writer
+	names map[string]string // "u123" or "g123" -> user or group name
+}
+
+// kindColors are the ANSI colors of the kinds of results.
+var kindColors = map[Kind]string{
+	KindSymlink:   "\x1b[36m",
+	KindHardlink:  "\x1b[33m",
+	KindBroken:    "\x1b[31m",
+	KindLoop:      "\x1b[35m",
+	KindEscape:    "\x1b[1;31m",
+	KindDuplicate: "\x1b[32m",
+	KindReflink:   "\x1b[1;32m",
+	KindClone:     "\x1b[1;32m",
+	KindShortcut:  "\x1b[34m",
+	KindAlias:     "\x1b[1;34m",
+}
+
+// NewTextSink returns a TextSink writing to w.
+func NewTextSink(w io.Writer) *TextSink {
+	return &TextSink{w: w}
+}
+
+// NewConsoleSink returns a TextSink writing to standard output.
+func NewConsoleSink() *TextSink {
+	return NewTextSink(os.Stdout)
+}
+
+// Emit writes r on its own line.
+func (s *TextSink) Emit(r Result) error {
+	path, kind := r.Path, string(r.Kind)
+	if s.ShowTarget && r.Target != "" {
+		kind += " to " + r.Target
+	}
+	if t := r.LinkType(); s.Long && t != "" {
+		kind += ", " + string(t)
+	}
+	if r.Depth > 0 {
+		kind += fmt.Sprintf(", depth %d", r.Depth)
+	}
+	if s.Long && r.Alias != "" {
+		kind += ", also at " + r.Alias
+	}
+	if s.Long && r.BindMount != "" {
+		kind += ", via bind mount " + r.BindMount
+	}
+	if len(r.Chain) > 0 {
+		kind += ": " + strings.Join(r.Chain, " -> ")
+	}
+	if c, ok := kindColors[r.Kind]; ok && s.Color {
+		path = c + path + "\x1b[0m"
+	}
+	if s.Long {
+		path = fmt.Sprintf("%s %3d %-8s %-8s %8d %s %6d %s", lsMode(r.Mode), r.Nlink,
+			s.name("u", r.UID), s.name("g", r.GID), r.Size,
+			r.ModTime.Format("2006-01-02 15:04"), r.Device, path)
+	}
+	var err error
+	if r.Kind == KindSymlink || r.LinkTarget != "" {
+		_, err = fmt.Fprintf(s.w, "%s (%s) -> %s\n", path, kind, r.LinkTarget)
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s (%s)\n", path, kind)
+	}
+	return err
+}
+
+// name returns the name of the user ("u") or group ("g") with the numeric
+// id, or the number itself if it has no name. Names are looked up once.
+func (s *TextSink) name(kind string, id uint32) string {
+	key := kind + strconv.FormatUint(uint64(id), 10)
+	if name, ok := s.names[key]; ok {
+		return name
+	}
+	name := key[1:]
+	if kind == "u" {
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+	} else if g, err := user.LookupGroupId(name); err == nil {
+		name = g.Name
+	}
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	s.names[key] = name
+	return name
+}
+
+// Flush writes any lines buffered by the underlying writer.
+func (s *TextSink) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// PathSink writes only the path of each result, followed by a terminator
+// byte such as '\n' or, for paths containing newlines, '\x00'.
+type PathSink struct {
+	w          io.Writer
+	terminator byte
+}
+
+// NewPathSink returns a PathSink writing to w.
+func NewPathSink(w io.Writer, terminator byte) *PathSink {
+	return &PathSink{w: w, terminator: terminator}
+}
+
+// Emit writes the path of r and the terminator.
+func (s *PathSink) Emit(r Result) error {
+	_, err := io.WriteString(s.w, r.Path+string(s.terminator))
+	return err
+}
+
+// Flush writes any paths buffered by the underlying writer.
+func (s *PathSink) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// TemplateSink writes each result by executing a text/template with the
+// Result as data, followed by a newline unless the output of the template
+// already ends with one.
+type TemplateSink struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateSink returns a TemplateSink executing tmpl for each result.
+func NewTemplateSink(w io.Writer, tmpl *template.Template) *TemplateSink {
+	return &TemplateSink{w: w, tmpl: tmpl}
+}
+
+// Emit writes r as formatted by the template.
+func (s *TemplateSink) Emit(r Result) error {
+	var b bytes.Buffer
+	if err := s.tmpl.Execute(&b, r); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	_, err := s.w.Write(b.Bytes())
+	return err
+}
+
+// Flush writes any output buffered by the underlying writer.
+func (s *TemplateSink) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// FileSink writes one line per result to a file.
+type FileSink struct {
+	*TextSink
+	file *os.File
+}
+
+// NewFileSink creates or truncates the named file and returns a FileSink
+// writing to it. The file is closed by Flush.
+func NewFileSink(name string) (*FileSink, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{TextSink: NewTextSink(bufio.NewWriter(file)), file: file}, nil
+}
+
+// Flush writes any buffered lines and closes the file.
+func (s *FileSink) Flush() error {
+	err := s.TextSink.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// JSONSink writes results as a JSON array with one element per line. If
+// Scan is set, the array is the "results" member of an object that also
+// describes the search.
+type JSONSink struct {
+	// Scan describes the search producing the results. It must be set
+	// before the first result is emitted.
+	Scan *ScanMetadata
+
+	w     *bufio.Writer
+	count int
+}
+
+// NDJSONSink writes each result as a JSON object on its own line as soon as
+// it is emitted, so consumers can process results while the search runs.
+type NDJSONSink struct {
+	w io.Writer
+}
+
+// NewNDJSONSink returns an NDJSONSink writing to w.
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{w: w}
+}
+
+// Emit writes r followed by a newline.
+func (s *NDJSONSink) Emit(r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Flush writes any lines buffered by the underlying writer.
+func (s *NDJSONSink) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// csvHeader names the columns written by CSVSink.
+var csvHeader = []string{"path", "kind", "target", "link_target", "link_type", "inode", "device", "size", "mtime", "mode", "nlink", "uid", "gid", "error"}
+
+// CSVSink writes results as comma- or tab-separated values with a header
+// row, quoting fields as described in RFC 4180.
+type CSVSink struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVSink returns a CSVSink writing to w with fields separated by comma,
+// typically ',' or '\t'.
+func NewCSVSink(w io.Writer, comma rune) *CSVSink {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &CSVSink{w: cw}
+}
+
+// Emit writes r as a row, preceded by the header row if r is the first result.
+func (s *CSVSink) Emit(r Result) error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	var errMsg string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return s.w.Write([]string{
+		r.Path,
+		string(r.Kind),
+		r.Target,
+		r.LinkTarget,
+		string(r.LinkType()),
+		strconv.FormatUint(r.Inode, 10),
+		strconv.FormatUint(r.Device, 10),
+		strconv.FormatInt(r.Size, 10),
+		r.ModTime.Format(time.RFC3339Nano),
+		lsMode(r.Mode),
+		strconv.FormatUint(r.Nlink, 10),
+		strconv.FormatUint(uint64(r.UID), 10),
+		strconv.FormatUint(uint64(r.GID), 10),
+		errMsg,
+	})
+}
+
+// Flush writes the header row if nothing was emitted, then any buffered rows.
+func (s *CSVSink) Flush() error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *CSVSink) writeHeader() error {
+	if s.header {
+		return nil
+	}
+	s.header = true
+	return s.w.Write(csvHeader)
+}
+
+// ScanMetadata describes a search in the output of a JSONSink: the roots
+// and targets before the results, and the duration and errors after them.
+type ScanMetadata struct {
+	Roots   []string
+	Targets []string
+	// Started is when the search started; the duration is measured from it.
+	Started time.Time
+	// Errors collects the paths the search could not examine.
+	Errors *ErrorReport
+	// Summary, if set, is called once the results are written; the
+	// statistics it returns are written as the "summary" member.
+	Summary func() Progress
+}
+
+// NewJSONSink returns a JSONSink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: bufio.NewWriter(w)}
+}
+
+// Emit writes r as the next element of the array.
+func (s *JSONSink) Emit(r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if s.count == 0 {
+		sep = s.header() + "[\n"
+	}
+	s.count++
+	s.w.WriteString(sep)
+	_, err = s.w.Write(data)
+	return err
+}
+
+// Flush terminates the array and writes any buffered output.
+func (s *JSONSink) Flush() error {
+	if s.count == 0 {
+		s.w.WriteString(s.header() + "[]")
+	} else {
+		s.w.WriteString("\n]")
+	}
+	if err := s.trailer(); err != nil {
+		return err
+	}
+	s.w.WriteString("\n")
+	return s.w.Flush()
+}
+
+// header returns the text preceding the results array.
+func (s *JSONSink) header() string {
+	if s.Scan == nil {
+		return ""
+	}
+	roots, _ := json.Marshal(s.Scan.Roots)
+	header := fmt.Sprintf("{\n\"roots\": %s,\n", roots)
+	if len(s.Scan.Targets) > 0 {
+		targets, _ := json.Marshal(s.Scan.Targets)
+		header += fmt.Sprintf("\"targets\": %s,\n", targets)
+	}
+	return header + "\"results\": "
+}
+
+// trailer writes the members following the results array and closes the
+// object, if Scan is set.
+func (s *JSONSink) trailer() error {
+	if s.Scan == nil {
+		return nil
+	}
+	errs := []*ScanError{}
+	if s.Scan.Errors != nil {
+		errs = slices.AppendSeq(errs, s.Scan.Errors.All())
+	}
+	data, err := json.Marshal(errs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.w, ",\n\"duration\": %q,\n\"error_count\": %d,\n\"errors\": %s",
+		time.Since(s.Scan.Started).String(), len(errs), data)
+	if s.Scan.Summary != nil {
+		summary, err := json.Marshal(s.Scan.Summary())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.w, ",\n\"summary\": %s", summary)
+	}
+	s.w.WriteString("\n}")
+	return nil
+}
+
+// DOTSink writes the results as a Graphviz graph in the DOT language, e.g.
+// for rendering with "dot -Tsvg". Each symlink is an edge, labeled with its
+// raw link target, from the link to the target it resolves to; broken links
+// point to a dashed node naming their missing destination, and the links of
+// a loop are joined by magenta edges. Hard links
+// sharing an inode are drawn together in a cluster. The graph is written by
+// Flush, since clusters are only complete once the search is over.
+type DOTSink struct {
+	w       io.Writer
+	results []Result
+}
+
+// NewDOTSink returns a DOTSink writing to w.
+func NewDOTSink(w io.Writer) *DOTSink {
+	return &DOTSink{w: w}
+}
+
+// Emit records r for the graph.
+func (s *DOTSink) Emit(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+// Flush writes the graph.
+func (s *DOTSink) Flush() error {
+	var (
+		targets  []string
+		seen     = make(map[string]bool)
+		clusters = make(map[fileKey][]Result)
+		inodes   []fileKey
+	)
+	addTarget := func(t string) {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, r := range s.results {
+		addTarget(r.Target)
+		if r.Kind == KindHardlink {
+			k := fileKey{r.Device, r.Inode}
+			if _, ok := clusters[k]; !ok {
+				inodes = append(inodes, k)
+			}
+			clusters[k] = append(clusters[k], r)
+		}
+	}
+
+	w := bufio.NewWriter(s.w)
+	w.WriteString("digraph lfinder {\n\trankdir=LR;\n\tnode [shape=ellipse];\n")
+	for _, t := range targets {
+		fmt.Fprintf(w, "\t%s [shape=box, style=bold];\n", dotQuote(t))
+	}
+	for i, k := range inodes {
+		fmt.Fprintf(w, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n\t\tstyle=dashed;\n", i, dotQuote(fmt.Sprintf("inode %d", k.ino)))
+		members := make(map[string]bool)
+		for _, r := range clusters[k] {
+			for _, path := range []string{r.Target, r.Path} {
+				if path != "" && !members[path] {
+					members[path] = true
+					fmt.Fprintf(w, "\t\t%s;\n", dotQuote(path))
+				}
+			}
+		}
+		w.WriteString("\t}\n")
+	}
+	for _, r := range s.results {
+		switch {
+		case r.Kind == KindLoop:
+			for i := 1; i < len(r.Chain); i++ {
+				fmt.Fprintf(w, "\t%s -> %s [color=magenta];\n", dotQuote(r.Chain[i-1]), dotQuote(r.Chain[i]))
+			}
+		case r.Kind == KindBroken:
+			dest := r.LinkTarget + " (missing)"
+			fmt.Fprintf(w, "\t%s [style=dashed, color=red];\n", dotQuote(dest))
+			fmt.Fprintf(w, "\t%s -> %s [label=%s, style=dashed, color=red];\n", dotQuote(r.Path), dotQuote(dest), dotQuote(r.LinkTarget))
+		case r.Kind == KindReflink || r.Kind == KindClone:
+			fmt.Fprintf(w, "\t%s -> %s [label=\"shared storage\", style=dotted];\n", dotQuote(r.Path), dotQuote(r.Target))
+		case r.Kind == KindShortcut || r.Kind == KindAlias:
+			fmt.Fprintf(w, "\t%s -> %s [label=%s, style=dashed];\n", dotQuote(r.Path), dotQuote(r.Target), dotQuote(string(r.Kind)))
+		case r.Kind == KindDuplicate:
+			fmt.Fprintf(w, "\t%s -> %s [label=\"same content\", style=dotted];\n", dotQuote(r.Path), dotQuote(r.Target))
+		case r.Kind == KindSymlink && r.Target != "":
+			fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", dotQuote(r.Path), dotQuote(r.Target), dotQuote(r.LinkTarget))
+		case r.Kind == KindSymlink:
+			fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", dotQuote(r.Path), dotQuote(r.LinkTarget), dotQuote(r.LinkTarget))
+		}
+	}
+	w.WriteString("}\n")
+	return w.Flush()
+}
+
+// dotQuote returns s as a quoted DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
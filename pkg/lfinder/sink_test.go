@@ -0,0 +1,224 @@
+package lfinder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// sinkResults are results of every common kind, with paths that need
+// quoting in some formats.
+var sinkResults = []Result{
+	{Path: "/srv/link", Kind: KindSymlink, Target: "/srv/file", LinkTarget: "file", Mode: fs.ModeSymlink | 0o777, Inode: 7, Device: 1},
+	{Path: "/srv/hard, copy", Kind: KindHardlink, Target: "/srv/file", Mode: 0o644, Nlink: 2, Size: 12},
+	{Path: "/srv/dangling", Kind: KindBroken, LinkTarget: "gone", Mode: fs.ModeSymlink | 0o777, Err: errors.New("no such file")},
+	{Path: "/srv/loop", Kind: KindLoop, Chain: []string{"/srv/loop", "/srv/loop"}, Mode: fs.ModeSymlink | 0o777},
+	{Path: "/srv/out", Kind: KindEscape, Target: "/etc/passwd", LinkTarget: "../etc/passwd", Mode: fs.ModeSymlink | 0o777},
+}
+
+// emitAll emits sinkResults to s and flushes it.
+func emitAll(t *testing.T, s Sink) {
+	t.Helper()
+	n, err := Copy(s, slices.Values(sinkResults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(sinkResults) {
+		t.Fatalf("copied %d results, want %d", n, len(sinkResults))
+	}
+}
+
+func TestTextSink(t *testing.T) {
+	var b bytes.Buffer
+	emitAll(t, NewTextSink(&b))
+	want := "/srv/link (symlink) -> file\n" +
+		"/srv/hard, copy (hardlink)\n" +
+		"/srv/dangling (broken) -> gone\n" +
+		"/srv/loop (loop: /srv/loop -> /srv/loop)\n" +
+		"/srv/out (escape) -> ../etc/passwd\n"
+	if b.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	emitAll(t, &TextSink{w: &b, ShowTarget: true})
+	want = "/srv/link (symlink to /srv/file) -> file\n" +
+		"/srv/hard, copy (hardlink to /srv/file)\n" +
+		"/srv/dangling (broken) -> gone\n" +
+		"/srv/loop (loop: /srv/loop -> /srv/loop)\n" +
+		"/srv/out (escape to /etc/passwd) -> ../etc/passwd\n"
+	if b.String() != want {
+		t.Errorf("with targets, wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	var a, b bytes.Buffer
+	emitAll(t, MultiSink(NewTextSink(&a), NewTextSink(&b)))
+	if a.String() != b.String() || strings.Count(a.String(), "\n") != len(sinkResults) {
+		t.Errorf("wrote %q and %q", a.String(), b.String())
+	}
+}
+
+func TestTemplateSink(t *testing.T) {
+	for text, want := range map[string]string{
+		"{{.Kind}} {{.Path}}": "symlink /srv/link\nhardlink /srv/hard, copy\nbroken /srv/dangling\nloop /srv/loop\nescape /srv/out\n",
+		// A template ending the line itself does not get a second newline.
+		"{{.Path}}\n": "/srv/link\n/srv/hard, copy\n/srv/dangling\n/srv/loop\n/srv/out\n",
+		"{{if .LinkTarget}}{{.LinkTarget}}\n{{end}}": "file\n\ngone\n\n../etc/passwd\n",
+	} {
+		var b bytes.Buffer
+		emitAll(t, NewTemplateSink(&b, template.Must(template.New("").Parse(text))))
+		if b.String() != want {
+			t.Errorf("template %q wrote %q, want %q", text, b.String(), want)
+		}
+	}
+
+	var b bytes.Buffer
+	s := NewTemplateSink(&b, template.Must(template.New("").Parse("{{.Missing}}")))
+	if err := s.Emit(sinkResults[0]); err == nil {
+		t.Error("Emit with a template over a missing field succeeded")
+	}
+}
+
+func TestPathSink(t *testing.T) {
+	var b bytes.Buffer
+	emitAll(t, NewPathSink(&b, 0))
+	if want := "/srv/link\x00/srv/hard, copy\x00/srv/dangling\x00/srv/loop\x00/srv/out\x00"; b.String() != want {
+		t.Errorf("wrote %q, want %q", b.String(), want)
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	for _, comma := range []rune{',', '\t'} {
+		var b bytes.Buffer
+		emitAll(t, NewCSVSink(&b, comma))
+		r := csv.NewReader(&b)
+		r.Comma = comma
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(rows[0], csvHeader) {
+			t.Errorf("header %q, want %q", rows[0], csvHeader)
+		}
+		if len(rows) != len(sinkResults)+1 {
+			t.Fatalf("%d rows, want %d", len(rows), len(sinkResults)+1)
+		}
+		for i, row := range rows[1:] {
+			want := sinkResults[i]
+			if row[0] != want.Path || row[1] != string(want.Kind) || row[2] != want.Target || row[3] != want.LinkTarget {
+				t.Errorf("row %q, want %s", row, want.Path)
+			}
+		}
+		if link := rows[1]; link[4] != "relative" || link[5] != "7" || link[9] != "lrwxrwxrwx" {
+			t.Errorf("row %q, want a relative link with inode 7 and mode lrwxrwxrwx", link)
+		}
+		if got := rows[3][len(rows[3])-1]; got != "no such file" {
+			t.Errorf("error column %q", got)
+		}
+	}
+
+	// The header is written even without results.
+	var b bytes.Buffer
+	if err := NewCSVSink(&b, ',').Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(csvHeader, ",") + "\n"; b.String() != want {
+		t.Errorf("empty output %q, want %q", b.String(), want)
+	}
+}
+
+// jsonResult is a result as decoded from JSON output.
+type jsonResult struct {
+	Path     string   `json:"path"`
+	Kind     Kind     `json:"kind"`
+	Target   string   `json:"target"`
+	Chain    []string `json:"chain"`
+	LinkType string   `json:"link_type"`
+	Mode     string   `json:"mode"`
+	Error    string   `json:"error"`
+}
+
+// checkJSON compares results decoded from JSON output with sinkResults.
+func checkJSON(t *testing.T, got []jsonResult) {
+	t.Helper()
+	if len(got) != len(sinkResults) {
+		t.Fatalf("%d results, want %d", len(got), len(sinkResults))
+	}
+	for i, r := range got {
+		want := sinkResults[i]
+		var errMsg string
+		if want.Err != nil {
+			errMsg = want.Err.Error()
+		}
+		if r.Path != want.Path || r.Kind != want.Kind || r.Target != want.Target || !slices.Equal(r.Chain, want.Chain) ||
+			r.LinkType != string(want.LinkType()) || r.Mode != lsMode(want.Mode) || r.Error != errMsg {
+			t.Errorf("result %+v, want %+v", r, want)
+		}
+	}
+}
+
+func TestNDJSONSink(t *testing.T) {
+	var b bytes.Buffer
+	emitAll(t, NewNDJSONSink(&b))
+	var got []jsonResult
+	for line := range strings.Lines(b.String()) {
+		var r jsonResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		got = append(got, r)
+	}
+	checkJSON(t, got)
+}
+
+func TestJSONSink(t *testing.T) {
+	var b bytes.Buffer
+	emitAll(t, NewJSONSink(&b))
+	var got []jsonResult
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", b.String(), err)
+	}
+	checkJSON(t, got)
+
+	// Without results, the output is still a valid document.
+	b.Reset()
+	if err := NewJSONSink(&b).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "[]\n" {
+		t.Errorf("wrote %q, want %q", b.String(), "[]\n")
+	}
+}
+
+// TestJSONSinkScan checks that the scan metadata wraps the results in an
+// object describing the search.
+func TestJSONSinkScan(t *testing.T) {
+	var b bytes.Buffer
+	errs := &ErrorReport{}
+	errs.Add(&ScanError{Path: "/srv/private", Err: fs.ErrPermission})
+	s := NewJSONSink(&b)
+	s.Scan = &ScanMetadata{Roots: []string{"/srv"}, Targets: []string{"/srv/file"}, Started: time.Now(), Errors: errs}
+	emitAll(t, s)
+	var got struct {
+		Roots      []string     `json:"roots"`
+		Targets    []string     `json:"targets"`
+		Results    []jsonResult `json:"results"`
+		ErrorCount int          `json:"error_count"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", b.String(), err)
+	}
+	if !slices.Equal(got.Roots, []string{"/srv"}) || !slices.Equal(got.Targets, []string{"/srv/file"}) || got.ErrorCount != 1 {
+		t.Errorf("roots %q, targets %q, %d errors", got.Roots, got.Targets, got.ErrorCount)
+	}
+	checkJSON(t, got.Results)
+}
@@ -0,0 +1,38 @@
+package lfinder
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// Sorted returns a sequence yielding the results of seq ordered by compare.
+// It holds every result in memory and yields nothing until seq is exhausted,
+// so the search must complete before the first result is seen. Results
+// comparing equal keep the order of their paths, making the output
+// reproducible between runs.
+func Sorted(seq iter.Seq[Result], compare func(a, b Result) int) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		results := slices.Collect(seq)
+		slices.SortFunc(results, func(a, b Result) int {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.Path, b.Path)
+		})
+		for _, r := range results {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// ByPath orders results by path.
+func ByPath(a, b Result) int { return cmp.Compare(a.Path, b.Path) }
+
+// ByModTime orders results from the least to the most recently modified.
+func ByModTime(a, b Result) int { return a.ModTime.Compare(b.ModTime) }
+
+// BySize orders results from the smallest to the largest.
+func BySize(a, b Result) int { return cmp.Compare(a.Size, b.Size) }
@@ -0,0 +1,93 @@
+package lfinder
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// statFields is a set of the attributes of the candidates a search reads,
+// besides their type, device, inode, and link count, which it always does.
+type statFields uint8
+
+const (
+	statMode statFields = 1 << iota // permissions
+	statSize
+	statModTime
+	statOwner
+	statAll = statMode | statSize | statModTime | statOwner
+)
+
+// fieldMatcher is implemented by matchers that tell the attributes of the
+// candidates they read. Other matchers are taken to read them all.
+type fieldMatcher interface {
+	statFields() statFields
+}
+
+// fieldsOf returns the attributes of the candidates m reads.
+func fieldsOf(m Matcher) statFields {
+	if fm, ok := m.(fieldMatcher); ok {
+		return fm.statFields()
+	}
+	return statAll
+}
+
+// statFields returns the attributes of the candidates the search reads: those
+// the matcher reads, those the filters select candidates by, and with
+// visitDir set, the modification time of directories.
+func (s *search) statFields() statFields {
+	fields := fieldsOf(s.m)
+	if s.Perm.How != PermNone {
+		fields |= statMode
+	}
+	if s.MinSize > 0 || s.MaxSize > 0 {
+		fields |= statSize
+	}
+	if !s.NewerThan.IsZero() || !s.OlderThan.IsZero() {
+		fields |= statModTime
+	}
+	if len(s.Owner.IDs) > 0 || len(s.Group.IDs) > 0 {
+		fields |= statOwner
+	}
+	if s.visitDir != nil {
+		fields |= statModTime
+	}
+	return fields
+}
+
+// restat fills in the attributes of the match r that the Lstat of its
+// candidate left out.
+func (s *search) restat(r *Result) {
+	info, err := s.fsys.Lstat(r.Path)
+	if err != nil {
+		return
+	}
+	full := newResult(r.Path, r.Kind, info)
+	r.Size, r.ModTime, r.Mode = full.Size, full.ModTime, full.Mode
+	r.Nlink, r.UID, r.GID = full.Nlink, full.UID, full.GID
+}
+
+// leanFS is the host file system, fetching the Info of the entries of the
+// directories it reads with lstatFields.
+type leanFS struct {
+	OSFS
+	fields statFields
+}
+
+func (l leanFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := readDir(name)
+	for i, e := range entries {
+		entries[i] = leanEntry{e, name, l.fields}
+	}
+	return entries, err
+}
+
+// leanEntry is an entry read by leanFS.
+type leanEntry struct {
+	fs.DirEntry
+	dir    string
+	fields statFields
+}
+
+func (e leanEntry) Info() (fs.FileInfo, error) {
+	return lstatFields(filepath.Join(e.dir, e.Name()), e.fields)
+}
@@ -0,0 +1,184 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// statx flags and field masks, from linux/stat.h and linux/fcntl.h.
+const (
+	atFDCWD           = -100
+	atSymlinkNoFollow = 0x100
+	atNoAutomount     = 0x800
+
+	statxType       = 0x1
+	statxMode       = 0x2
+	statxNlink      = 0x4
+	statxUID        = 0x8
+	statxGID        = 0x10
+	statxMtime      = 0x40
+	statxIno        = 0x100
+	statxSize       = 0x200
+	statxBasicStats = 0x7ff
+)
+
+// sysStatx is the number of the statx system call, which the syscall
+// package does not define.
+var sysStatx = map[string]uintptr{
+	"amd64": 332, "arm64": 291, "riscv64": 291, "loong64": 291, "ppc64le": 383, "s390x": 379,
+}[runtime.GOARCH]
+
+// noStatx is set once statx turned out to be missing, before Linux 4.11.
+var noStatx atomic.Bool
+
+// statxMask returns the statx mask requesting fields: the type, inode, and
+// link count of a file, and the attributes in fields.
+func statxMask(fields statFields) uint32 {
+	if fields == statAll {
+		return statxBasicStats
+	}
+	mask := uint32(statxType | statxIno | statxNlink)
+	if fields&statMode != 0 {
+		mask |= statxMode
+	}
+	if fields&statSize != 0 {
+		mask |= statxSize
+	}
+	if fields&statModTime != 0 {
+		mask |= statxMtime
+	}
+	if fields&statOwner != 0 {
+		mask |= statxUID | statxGID
+	}
+	return mask
+}
+
+// lstatFields is lstat, fetching only the type, inode, and link count of
+// name, and the attributes in fields, with statx: the file system may then
+// leave the others out, as network file systems do rather than revalidate
+// them with the server.
+func lstatFields(name string, fields statFields) (fs.FileInfo, error) {
+	if fields == statAll || noStatx.Load() {
+		return lstat(name)
+	}
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	var b statxBuf
+	dirfd := atFDCWD
+	for {
+		_, _, errno := syscall.Syscall6(sysStatx, uintptr(dirfd), uintptr(unsafe.Pointer(p)),
+			atSymlinkNoFollow|atNoAutomount, uintptr(statxMask(fields)), uintptr(unsafe.Pointer(&b)), 0)
+		switch errno {
+		case 0:
+			return newStatxInfo(name, &b), nil
+		case syscall.EINTR:
+			continue
+		case syscall.ENOSYS:
+			noStatx.Store(true)
+			return os.Lstat(name)
+		}
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: errno}
+	}
+}
+
+// lacks reports whether info, as returned by lstatFields, lacks some of the
+// attributes in fields.
+func lacks(info fs.FileInfo, fields statFields) bool {
+	fi, ok := info.(*statxInfo)
+	return ok && fi.mask&statxMask(fields) != statxMask(fields)
+}
+
+// statxTimestamp is struct statx_timestamp.
+type statxTimestamp struct {
+	sec  int64
+	nsec uint32
+	_    int32
+}
+
+// statxBuf is struct statx.
+type statxBuf struct {
+	mask, blksize                    uint32
+	attributes                       uint64
+	nlink, uid, gid                  uint32
+	mode                             uint16
+	_                                uint16
+	ino, size, blocks, attributesMsk uint64
+	atime, btime, ctime, mtime       statxTimestamp
+	rdevMajor, rdevMinor             uint32
+	devMajor, devMinor               uint32
+	_                                [14]uint64
+}
+
+// statxInfo is the FileInfo of a statx call. Its Sys is a *syscall.Stat_t,
+// as for os.Lstat.
+type statxInfo struct {
+	name string
+	mode fs.FileMode
+	st   syscall.Stat_t
+	mask uint32 // the fields filled in
+}
+
+func newStatxInfo(path string, b *statxBuf) *statxInfo {
+	fi := &statxInfo{name: filepath.Base(path), mask: b.mask}
+	st := &fi.st
+	setInt(&st.Dev, mkdev(uint64(b.devMajor), uint64(b.devMinor)))
+	setInt(&st.Ino, b.ino)
+	setInt(&st.Nlink, uint64(b.nlink))
+	setInt(&st.Mode, uint64(b.mode))
+	setInt(&st.Uid, uint64(b.uid))
+	setInt(&st.Gid, uint64(b.gid))
+	setInt(&st.Rdev, mkdev(uint64(b.rdevMajor), uint64(b.rdevMinor)))
+	setInt(&st.Size, b.size)
+	setInt(&st.Blksize, uint64(b.blksize))
+	setInt(&st.Blocks, b.blocks)
+	st.Atim = syscall.NsecToTimespec(b.atime.sec*1e9 + int64(b.atime.nsec))
+	st.Mtim = syscall.NsecToTimespec(b.mtime.sec*1e9 + int64(b.mtime.nsec))
+	st.Ctim = syscall.NsecToTimespec(b.ctime.sec*1e9 + int64(b.ctime.nsec))
+
+	fi.mode = fs.FileMode(b.mode & 0o777)
+	switch b.mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		fi.mode |= fs.ModeDevice
+	case syscall.S_IFCHR:
+		fi.mode |= fs.ModeDevice | fs.ModeCharDevice
+	case syscall.S_IFDIR:
+		fi.mode |= fs.ModeDir
+	case syscall.S_IFIFO:
+		fi.mode |= fs.ModeNamedPipe
+	case syscall.S_IFLNK:
+		fi.mode |= fs.ModeSymlink
+	case syscall.S_IFSOCK:
+		fi.mode |= fs.ModeSocket
+	}
+	if b.mode&syscall.S_ISGID != 0 {
+		fi.mode |= fs.ModeSetgid
+	}
+	if b.mode&syscall.S_ISUID != 0 {
+		fi.mode |= fs.ModeSetuid
+	}
+	if b.mode&syscall.S_ISVTX != 0 {
+		fi.mode |= fs.ModeSticky
+	}
+	return fi
+}
+
+// setInt sets a field of syscall.Stat_t, whose integer types differ
+// between architectures.
+func setInt[T ~int32 | ~int64 | ~uint32 | ~uint64](p *T, v uint64) { *p = T(v) }
+
+func (fi *statxInfo) Name() string       { return fi.name }
+func (fi *statxInfo) Size() int64        { return int64(fi.st.Size) }
+func (fi *statxInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *statxInfo) ModTime() time.Time { return time.Unix(fi.st.Mtim.Unix()) }
+func (fi *statxInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *statxInfo) Sys() any           { return &fi.st }
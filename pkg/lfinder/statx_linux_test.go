@@ -0,0 +1,66 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// sameStat reports how got, as returned by statx, differs from want, as
+// returned by lstat, in the attributes fields asks for, or "".
+func sameStat(got, want fs.FileInfo, fields statFields) string {
+	g, w := got.Sys().(*syscall.Stat_t), want.Sys().(*syscall.Stat_t)
+	switch {
+	case got.Name() != want.Name():
+		return "name " + got.Name()
+	case got.Mode().Type() != want.Mode().Type():
+		return "type " + got.Mode().String()
+	case g.Dev != w.Dev || g.Ino != w.Ino || g.Nlink != w.Nlink:
+		return "device, inode, or link count"
+	case fields&statMode != 0 && got.Mode() != want.Mode():
+		return "mode " + got.Mode().String()
+	case fields&statSize != 0 && got.Size() != want.Size():
+		return "size"
+	case fields&statModTime != 0 && !got.ModTime().Equal(want.ModTime()):
+		return "modification time"
+	case fields&statOwner != 0 && (g.Uid != w.Uid || g.Gid != w.Gid):
+		return "owner"
+	}
+	return ""
+}
+
+func TestLstatFields(t *testing.T) {
+	dir := typedDir(t)
+	file := filepath.Join(dir, "file-with-a-rather-long-name-0000")
+	if err := os.WriteFile(file, []byte("contents"), 0o4750); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{file, filepath.Join(dir, "sub"), filepath.Join(dir, "link"), filepath.Join(dir, "fifo")}
+	for _, fields := range []statFields{0, statMode, statSize | statModTime, statOwner, statAll} {
+		for _, path := range paths {
+			want, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := lstatFields(path, fields)
+			if err != nil {
+				t.Fatalf("lstatFields(%s, %d): %v", path, fields, err)
+			}
+			if diff := sameStat(got, want, fields); diff != "" {
+				t.Errorf("lstatFields(%s, %d): wrong %s", path, fields, diff)
+			}
+			if lacks(got, fields) {
+				t.Errorf("lstatFields(%s, %d) lacks fields asked for", path, fields)
+			}
+		}
+	}
+	missing := filepath.Join(dir, "missing")
+	if _, err := lstatFields(missing, statMode); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("lstatFields(%s) = %v, want fs.ErrNotExist", missing, err)
+	}
+}
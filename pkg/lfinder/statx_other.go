@@ -0,0 +1,12 @@
+//go:build !linux || !(amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import "io/fs"
+
+// lstatFields is lstat on this platform, which fetches every attribute.
+func lstatFields(name string, fields statFields) (fs.FileInfo, error) { return lstat(name) }
+
+// lacks reports whether info lacks some of the attributes in fields, which
+// it never does on this platform.
+func lacks(info fs.FileInfo, fields statFields) bool { return false }
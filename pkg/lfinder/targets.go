@@ -0,0 +1,305 @@
+package lfinder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// targetSet matches links to any of several targets during a single walk.
+// Each target is resolved once, up front: symlinks are compared against the
+// canonical path of every target and hard links against their device and
+// inode numbers: inode numbers are only unique within a file system.
+// The Result of a match records the target it refers to. When two targets
+// are the same file, links to it are attributed to the first one.
+// With into set, symlinks resolving to a path below a target also match;
+// the innermost target containing the path is reported. Directories cannot
+// have hard links, so directory targets only match symlinks. With reflinks
+// or content set, regular files sharing storage or contents with a target
+// match too. With shortcuts set, so do Windows shell links (.lnk files)
+// leading to a target, and with aliases set, Finder alias files. Unless
+// follow is set, a target that is a symbolic link stands
+// for the link itself rather than for its destination: links resolving
+// through it and its own hard links match.
+type targetSet struct {
+	fsys      FS
+	symlinks  bool
+	hardlinks bool
+	into      bool
+	links     bool                  // some target is a symlink, not followed
+	reflinks  *reflinkIndex         // nil unless comparing storage
+	content   *contentIndex         // nil unless comparing contents
+	shortcuts OpenFS                // nil unless reading shell links
+	aliases   OpenFS                // nil unless reading Finder aliases
+	equiv     []func(string) string // other paths a path is known by
+	paths     map[string]string     // canonical path -> target
+	inodes    map[fileKey]string    // device and inode -> target
+	unfound   map[fileKey]uint64    // device and inode -> links not found yet
+	sizes     map[int64]bool        // sizes of the targets with an inode
+	left      uint64                // total of unfound
+}
+
+// fileKey identifies a file by its device and inode numbers.
+type fileKey struct{ dev, ino uint64 }
+
+// newTargetSet resolves targets on fsys. It fails if any target cannot be
+// accessed, or is a directory when only hard links are searched for.
+// Targets that are symbolic links are followed if follow is set.
+func newTargetSet(fsys FS, symlinks, hardlinks, follow bool, targets []string) (*targetSet, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no target file given")
+	}
+	ts := &targetSet{
+		fsys:      fsys,
+		symlinks:  symlinks,
+		hardlinks: hardlinks,
+		paths:     make(map[string]string, len(targets)),
+		inodes:    make(map[fileKey]string, len(targets)),
+		unfound:   make(map[fileKey]uint64, len(targets)),
+		sizes:     make(map[int64]bool, len(targets)),
+	}
+	for _, target := range targets {
+		info, canonical, err := ts.resolve(target, follow)
+		if err != nil {
+			return nil, fmt.Errorf("accessing target file: %w", err)
+		}
+		if _, ok := ts.paths[canonical]; !ok {
+			ts.paths[canonical] = target
+		}
+		if info.IsDir() {
+			if !symlinks {
+				return nil, fmt.Errorf("target %s is a directory, which cannot have hard links", target)
+			}
+			continue
+		}
+		if dev, ino, ok := fileID(info); ok {
+			if _, dup := ts.inodes[fileKey{dev, ino}]; !dup {
+				ts.inodes[fileKey{dev, ino}] = target
+				nlink, _, _, _ := fileOwner(info)
+				ts.unfound[fileKey{dev, ino}] = nlink
+				ts.left += nlink
+			}
+			ts.sizes[info.Size()] = true
+		}
+	}
+	return ts, nil
+}
+
+// resolve returns the FileInfo and canonical path of target, or of the link
+// itself if target is a symbolic link and follow is not set.
+func (ts *targetSet) resolve(target string, follow bool) (fs.FileInfo, string, error) {
+	info, err := ts.fsys.Lstat(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 && !follow {
+		dir, err := ts.fsys.EvalSymlinks(filepath.Dir(target))
+		if err != nil {
+			return nil, "", err
+		}
+		ts.links = true
+		return info, filepath.Join(dir, filepath.Base(target)), nil
+	}
+	if info, err = ts.fsys.Stat(target); err != nil {
+		return nil, "", err
+	}
+	canonical, err := ts.fsys.EvalSymlinks(target)
+	return info, canonical, err
+}
+
+// Match reports path if it is a symbolic link resolving to one of the
+// targets, a regular file, named pipe, socket, or device node on the same
+// device sharing the inode of one, or a
+// separate file sharing storage or contents with one, or a shell link or
+// Finder alias leading to one.
+func (ts *targetSet) Match(path string, d fs.DirEntry, info fs.FileInfo) (Result, bool, error) {
+	if ts.shortcuts != nil && info.Mode().IsRegular() && isShortcut(path) {
+		if result, ok, err := ts.matchShortcut(path, info); ok || err != nil {
+			return result, ok, err
+		}
+	}
+	if ts.aliases != nil && info.Mode().IsRegular() {
+		if result, ok, err := ts.matchAlias(path, info); ok || err != nil {
+			return result, ok, err
+		}
+	}
+	switch {
+	case ts.links && info.Mode()&fs.ModeSymlink != 0:
+		result, ok := ts.matchLink(path, info)
+		return result, ok, nil
+	case ts.symlinks && info.Mode()&fs.ModeSymlink != 0:
+		resolved, err := evalLink(ts.fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return Result{}, false, nil
+		}
+		if err != nil {
+			return Result{}, false, err
+		}
+		target, ok := ts.lookup(resolved)
+		if !ok {
+			return Result{}, false, nil
+		}
+		result := newResult(path, KindSymlink, info)
+		result.Target = target
+		result.LinkTarget, result.Err = ts.fsys.Readlink(path)
+		return result, true, nil
+	case ts.hardlinks && special(info.Mode()):
+		dev, ino, ok := fileID(info)
+		if target, linked := ts.inodes[fileKey{dev, ino}]; ok && linked {
+			result := newResult(path, KindHardlink, info)
+			result.Target = target
+			return result, true, nil
+		}
+	case (ts.hardlinks || ts.reflinks != nil || ts.content != nil) && info.Mode().IsRegular():
+		// Hard links have the size of their target: other files are not
+		// identified, which takes opening them on Windows.
+		if ts.sizes[info.Size()] || lacks(info, statSize) {
+			dev, ino, ok := fileID(info)
+			if target, linked := ts.inodes[fileKey{dev, ino}]; ok && linked {
+				result := newResult(path, KindHardlink, info)
+				result.Target = target
+				return result, ts.hardlinks, nil
+			}
+		}
+		if ts.reflinks != nil {
+			dev, _, _ := fileID(info)
+			target, ok, err := ts.reflinks.match(path, dev)
+			if err != nil {
+				return Result{}, false, err
+			}
+			if ok {
+				result := newResult(path, sharedKind, info)
+				result.Target = target
+				return result, true, nil
+			}
+		}
+		if ts.content == nil {
+			return Result{}, false, nil
+		}
+		target, ok, err := ts.content.match(path, info)
+		if !ok || err != nil {
+			return Result{}, false, err
+		}
+		result := newResult(path, KindDuplicate, info)
+		result.Target = target
+		return result, true, nil
+	}
+	return Result{}, false, nil
+}
+
+func (ts *targetSet) matchesType(t FileType) bool {
+	switch t {
+	case TypeDir:
+		return false
+	case TypeSymlink:
+		return ts.symlinks || ts.links
+	case TypeRegular:
+		return ts.hardlinks || ts.reflinks != nil || ts.content != nil || ts.shortcuts != nil || ts.aliases != nil
+	}
+	return ts.hardlinks
+}
+
+// statFields returns the attributes of the candidates ts reads: their size,
+// to compare contents and read aliases. Other files are only ruled out as
+// hard links by their size when it was fetched anyway.
+func (ts *targetSet) statFields() statFields {
+	if ts.content != nil || ts.aliases != nil {
+		return statSize
+	}
+	return 0
+}
+
+// cacheLinks resolves links through cache from now on: a targetSet is
+// built for a single search.
+func (ts *targetSet) cacheLinks(cache func(FS) FS) Matcher {
+	ts.fsys = cache(ts.fsys)
+	return ts
+}
+
+// matchLink matches the symbolic link path when some target is a link
+// itself: as a hard link of such a target, or as a link whose chain of links
+// passes through a target or ends at one.
+func (ts *targetSet) matchLink(path string, info fs.FileInfo) (Result, bool) {
+	dev, ino, ok := fileID(info)
+	if target, linked := ts.inodes[fileKey{dev, ino}]; ok && linked && ts.hardlinks {
+		result := newResult(path, KindHardlink, info)
+		result.Target = target
+		return result, true
+	}
+	if !ts.symlinks {
+		return Result{}, false
+	}
+	for _, p := range linkChain(ts.fsys, path, maxSymlinks)[1:] {
+		if target, ok := ts.lookup(p); ok {
+			result := newResult(path, KindSymlink, info)
+			result.Target = target
+			result.LinkTarget, result.Err = ts.fsys.Readlink(path)
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// complete counts the hard link r and reports whether every hard link of
+// the targets has been found, according to their link counts. It always
+// reports false unless the search is for hard links only, and is not safe
+// for concurrent use.
+func (ts *targetSet) complete(r Result) bool {
+	if ts.symlinks || ts.reflinks != nil || ts.content != nil || ts.left == 0 {
+		return false
+	}
+	k := fileKey{r.Device, r.Inode}
+	if r.Kind == KindHardlink && ts.unfound[k] > 0 {
+		ts.unfound[k]--
+		ts.left--
+	}
+	return ts.left == 0
+}
+
+// equate makes the paths that alt maps to another path compare equal to
+// it, as the two sides of a firmlink or the paths to a file through bind
+// mounts do: targets are also recorded at the latter.
+func (ts *targetSet) equate(alt func(string) string) {
+	ts.equiv = append(ts.equiv, alt)
+	for path, target := range ts.paths {
+		if other := alt(path); other != path {
+			if _, ok := ts.paths[other]; !ok {
+				ts.paths[other] = target
+			}
+		}
+	}
+}
+
+// lookup returns the target the canonical path resolved refers to: the
+// target at that path or, with into set, the innermost target directory
+// containing it. A path with other paths equated to it is also looked up
+// at those.
+func (ts *targetSet) lookup(resolved string) (string, bool) {
+	if target, ok := ts.lookupPath(resolved); ok {
+		return target, ok
+	}
+	for _, alt := range ts.equiv {
+		if other := alt(resolved); other != resolved {
+			if target, ok := ts.lookupPath(other); ok {
+				return target, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupPath is lookup without regard to equated paths.
+func (ts *targetSet) lookupPath(resolved string) (string, bool) {
+	if target, ok := ts.paths[resolved]; ok || !ts.into {
+		return target, ok
+	}
+	for dir := filepath.Dir(resolved); ; dir = filepath.Dir(dir) {
+		if target, ok := ts.paths[dir]; ok {
+			return target, true
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,238 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// io_uring system calls and constants, from linux/io_uring.h.
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+
+	ioringOffSQRing = 0
+	ioringOffSQEs   = 0x10000000
+
+	ioringFeatSingleMmap = 1 << 0
+	ioringEnterGetEvents = 1 << 0
+	ioringOpStatx        = 21
+)
+
+// statxRingEntries is the size of the submission queue of a ring, and so
+// the number of statx calls in flight at once.
+const statxRingEntries = 256
+
+// uringParams is struct io_uring_params.
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFD         uint32
+	_            [3]uint32
+	sqOff        uringSQOffsets
+	cqOff        uringCQOffsets
+}
+
+// uringSQOffsets is struct io_sqring_offsets.
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries uint32
+	flags, dropped, array, _          uint32
+	_                                 uint64
+}
+
+// uringCQOffsets is struct io_cqring_offsets.
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries uint32
+	overflow, cqes, flags, _          uint32
+	_                                 uint64
+}
+
+// uringSQE is struct io_uring_sqe, as filled for IORING_OP_STATX.
+type uringSQE struct {
+	opcode, flags uint8
+	ioprio        uint16
+	fd            int32
+	off           uint64 // the statx buffer
+	addr          uint64 // the path
+	len           uint32 // the statx mask
+	opFlags       uint32 // the statx flags
+	userData      uint64
+	_             [3]uint64
+}
+
+// uringCQE is struct io_uring_cqe.
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// statxRing is an io_uring submitting statx calls. It is not safe for
+// concurrent use.
+type statxRing struct {
+	fd         int
+	ring, sqes []byte
+	sqHead     *uint32
+	sqTail     *uint32
+	sqMask     uint32
+	sqArray    []uint32
+	cqHead     *uint32
+	cqTail     *uint32
+	cqMask     uint32
+	cqes       []uringCQE
+	entries    []uringSQE
+	bufs       []statxBuf
+	mask       uint32 // the statx mask of the fields fetched
+}
+
+// stranded holds the statx buffers and paths of the calls left in flight
+// by rings that could no longer be waited on, which the kernel may still
+// read and write: they are never freed.
+var stranded struct {
+	sync.Mutex
+	memory []any
+}
+
+// newStatxRing sets up an io_uring fetching fields, failing where the
+// kernel does not support it or forbids it, as seccomp profiles often do.
+func newStatxRing(fields statFields) (*statxRing, error) {
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIOURingSetup, statxRingEntries, uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := &statxRing{fd: int(fd), bufs: make([]statxBuf, statxRingEntries), mask: statxMask(fields)}
+	if p.features&ioringFeatSingleMmap == 0 {
+		r.close()
+		return nil, errors.New("io_uring: kernel too old")
+	}
+	size := max(p.sqOff.array+p.sqEntries*4, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	var err error
+	if r.ring, err = syscall.Mmap(r.fd, ioringOffSQRing, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		r.close()
+		return nil, err
+	}
+	sqeSize := int(p.sqEntries) * int(unsafe.Sizeof(uringSQE{}))
+	if r.sqes, err = syscall.Mmap(r.fd, ioringOffSQEs, sqeSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.ring[p.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.ring[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.ring[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.ring[p.sqOff.array])), p.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.ring[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.ring[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.ring[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&r.ring[p.cqOff.cqes])), p.cqEntries)
+	r.entries = unsafe.Slice((*uringSQE)(unsafe.Pointer(&r.sqes[0])), p.sqEntries)
+	return r, nil
+}
+
+// close releases the ring.
+func (r *statxRing) close() {
+	if r.sqes != nil {
+		syscall.Munmap(r.sqes)
+	}
+	if r.ring != nil {
+		syscall.Munmap(r.ring)
+	}
+	syscall.Close(r.fd)
+}
+
+// lstat calls statx, without following symbolic links, on each of paths,
+// statxRingEntries at a time, and stores the results in infos and errs. It
+// fails if the ring cannot be used, leaving the rest of infos unset.
+func (r *statxRing) lstat(paths []string, infos []fs.FileInfo, errs []error) error {
+	for start := 0; start < len(paths); start += statxRingEntries {
+		end := min(start+statxRingEntries, len(paths))
+		if err := r.batch(paths[start:end], infos[start:end], errs[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch submits a statx call for each of at most statxRingEntries paths
+// and waits for all of them. If submitting fails partway, it still waits
+// for the calls submitted, which write to r.bufs, before failing.
+func (r *statxRing) batch(paths []string, infos []fs.FileInfo, errs []error) error {
+	names := make([]*byte, len(paths))
+	defer runtime.KeepAlive(names)
+	tail := atomic.LoadUint32(r.sqTail)
+	for i, path := range paths {
+		name, err := syscall.BytePtrFromString(path)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+		r.bufs[i] = statxBuf{}
+		idx := (tail + uint32(i)) & r.sqMask
+		r.entries[idx] = uringSQE{
+			opcode:   ioringOpStatx,
+			fd:       atFDCWD,
+			off:      uint64(uintptr(unsafe.Pointer(&r.bufs[i]))),
+			addr:     uint64(uintptr(unsafe.Pointer(name))),
+			len:      r.mask,
+			opFlags:  atSymlinkNoFollow | atNoAutomount,
+			userData: uint64(i),
+		}
+		r.sqArray[idx] = idx
+	}
+	atomic.StoreUint32(r.sqTail, tail+uint32(len(paths)))
+
+	submitted, done := 0, 0
+	var failed error
+	for done < submitted || failed == nil && done < len(paths) {
+		submit := len(paths) - submitted
+		if failed != nil {
+			// Only wait for the calls in flight.
+			submit = 0
+		}
+		n, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), uintptr(submit), 1, ioringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR || errno == syscall.EAGAIN || errno == syscall.EBUSY {
+			continue
+		}
+		if errno != 0 {
+			switch {
+			case submitted == 0:
+				// Nothing was submitted: take the entries back.
+				atomic.StoreUint32(r.sqTail, tail)
+				return errno
+			case failed != nil:
+				// The calls in flight can no longer be waited for.
+				stranded.Lock()
+				stranded.memory = append(stranded.memory, r.bufs, names)
+				stranded.Unlock()
+				r.bufs = make([]statxBuf, statxRingEntries)
+				return failed
+			}
+			failed = errno
+			continue
+		}
+		submitted += int(n)
+		head := atomic.LoadUint32(r.cqHead)
+		for ; head != atomic.LoadUint32(r.cqTail); head++ {
+			cqe := r.cqes[head&r.cqMask]
+			i := int(cqe.userData)
+			if cqe.res < 0 {
+				errs[i] = &fs.PathError{Op: "lstat", Path: paths[i], Err: syscall.Errno(-cqe.res)}
+			} else {
+				infos[i] = newStatxInfo(paths[i], &r.bufs[i])
+			}
+			done++
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+	return failed
+}
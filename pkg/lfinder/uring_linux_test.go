@@ -0,0 +1,53 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatxRing(t *testing.T) {
+	r, err := newStatxRing(statAll)
+	if err != nil {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	defer r.close()
+	dir := typedDir(t)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More paths than the ring holds, and a missing one.
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	paths = append(paths, filepath.Join(dir, "missing"))
+	if len(paths) <= statxRingEntries {
+		t.Fatalf("only %d paths", len(paths))
+	}
+	infos, errs := make([]fs.FileInfo, len(paths)), make([]error, len(paths))
+	if err := r.lstat(paths, infos, errs); err != nil {
+		t.Skipf("io_uring cannot be used: %v", err)
+	}
+	for i, path := range paths[:len(paths)-1] {
+		want, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs[i] != nil {
+			t.Errorf("%s: %v", path, errs[i])
+			continue
+		}
+		if diff := sameStat(infos[i], want, statAll); diff != "" {
+			t.Errorf("%s: wrong %s", path, diff)
+		}
+	}
+	if last := errs[len(errs)-1]; !errors.Is(last, fs.ErrNotExist) {
+		t.Errorf("missing path: %v, want fs.ErrNotExist", last)
+	}
+}
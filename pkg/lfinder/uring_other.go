@@ -0,0 +1,19 @@
+//go:build !linux || !(amd64 || arm64 || riscv64 || loong64 || ppc64le || s390x)
+
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// statxRing is not implemented on this platform.
+type statxRing struct{}
+
+func newStatxRing(fields statFields) (*statxRing, error) { return nil, errors.ErrUnsupported }
+
+func (r *statxRing) close() {}
+
+func (r *statxRing) lstat(paths []string, infos []fs.FileInfo, errs []error) error {
+	return errors.ErrUnsupported
+}
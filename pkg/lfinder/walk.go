@@ -0,0 +1,423 @@
+package lfinder
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// walkFunc is called by walkTrees for every file and directory met, along
+// with the root it lies below and the label of its directory, or its own for
+// a directory. As with fs.WalkDirFunc, err is set when path cannot be read,
+// a directory for which it returns filepath.SkipDir is not descended into, a
+// file for which it does ends the walk of its directory, and
+// filepath.SkipAll ends the whole walk. It is called concurrently.
+type walkFunc func(root, path string, d fs.DirEntry, label any, err error) error
+
+// labelFunc returns the label of the directory path, given that of its
+// parent, or nil for a root. Labels carry what a walkFunc learns about a
+// directory down to the entries below it.
+type labelFunc func(parent any, root, path string, d fs.DirEntry) any
+
+// walkTrees walks the trees rooted at roots with workers goroutines, which
+// read directories in parallel: each one walks the directories it finds
+// depth first, and when it runs out of them, takes over directories found
+// by another. Directories are read through fsys. Symbolic links to
+// directories are followed as selected by follow: a root that is a link
+// unless follow is FollowNever, and every link met with FollowAlways. A
+// followed link is passed to fn as a directory. With FollowAlways, as with
+// find -L, a directory is not entered below itself, which keeps links to an
+// ancestor from looping. If budget is not nil, the subtrees below a root
+// that take longer than its timeout are abandoned. If label is not nil,
+// it labels each directory for fn.
+func walkTrees(fsys FS, roots []string, follow FollowMode, workers int, budget *dirBudget, label labelFunc, fn walkFunc) {
+	if label == nil {
+		label = func(any, string, string, fs.DirEntry) any { return nil }
+	}
+	w := &walker{fsys: fsys, follow: follow, fn: fn, label: label, budget: budget, queue: newDirQueue(workers)}
+	for i, root := range roots {
+		info, err := fsys.Lstat(root)
+		if err != nil {
+			if fn(root, root, nil, nil, err) == filepath.SkipAll {
+				return
+			}
+			continue
+		}
+		d := fs.FileInfoToDirEntry(info)
+		if follow != FollowNever {
+			d = w.deref(root, d)
+		}
+		l := label(nil, root, root, d)
+		if !d.IsDir() {
+			if fn(root, root, d, l, nil) == filepath.SkipAll {
+				return
+			}
+			continue
+		}
+		w.queue.push(i%workers, &dirNode{root: root, path: root, d: d, label: l})
+	}
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Go(func() {
+			for n := w.queue.pop(i); n != nil; n = w.queue.pop(i) {
+				w.walk(i, n)
+				w.queue.done()
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// cachedEntry is a directory entry read by the walker, whose Info is
+// fetched once, so that the filters of the walker and the worker examining
+// the entry share a single Lstat. It is safe for concurrent use.
+type cachedEntry struct {
+	fs.DirEntry
+	once sync.Once
+	info fs.FileInfo
+	err  error
+}
+
+func (e *cachedEntry) Info() (fs.FileInfo, error) {
+	e.once.Do(func() { e.info, e.err = e.DirEntry.Info() })
+	return e.info, e.err
+}
+
+// set sets the Info of e, unless it was fetched already.
+func (e *cachedEntry) set(info fs.FileInfo, err error) {
+	e.once.Do(func() { e.info, e.err = info, err })
+}
+
+// prefetcher is implemented by file systems that fetch the Info of the
+// entries of a directory together, faster than one by one.
+type prefetcher interface {
+	// prefetch sets the Info of the entries of dir it fetches.
+	prefetch(dir string, entries []*cachedEntry)
+}
+
+// dirBudget bounds the time spent walking each subtree below a root.
+type dirBudget struct {
+	timeout time.Duration
+	// abandon is called with each directory whose walk is given up.
+	abandon func(path string)
+}
+
+// errExpired is returned by readDir when the budget of the subtree being
+// walked ran out.
+var errExpired = errors.New("directory time budget exceeded")
+
+// dirNode is a directory waiting to be walked.
+type dirNode struct {
+	root, path string
+	d          fs.DirEntry
+	label      any
+	// top is the subtree just below the root holding the directory, whose
+	// budget bounds the walk of everything below it; nil without a budget
+	// and for the roots.
+	top *subtree
+	// parents are the directories above it, with FollowAlways.
+	parents *ancestor
+}
+
+// ancestor is a directory entered with FollowAlways, linked to the one
+// above it.
+type ancestor struct {
+	key    fileKey
+	parent *ancestor
+}
+
+// subtree is a directory just below a root, walked within a budget. A
+// subtree below it cannot take longer than it does, so only the budgets of
+// these directories run out first.
+type subtree struct {
+	path      string
+	deadline  time.Time // set when the directory is entered
+	abandoned atomic.Bool
+}
+
+// walker holds the state of walkTrees.
+type walker struct {
+	fsys   FS
+	follow FollowMode
+	fn     walkFunc
+	label  labelFunc
+	budget *dirBudget
+	queue  *dirQueue
+}
+
+// deref returns the entry for the directory a symbolic link at path points
+// to, or d itself if it is not such a link.
+func (w *walker) deref(path string, d fs.DirEntry) fs.DirEntry {
+	if d.Type()&fs.ModeSymlink == 0 {
+		return d
+	}
+	info, err := w.fsys.Stat(path)
+	if err != nil || !info.IsDir() {
+		return d
+	}
+	return fs.FileInfoToDirEntry(info)
+}
+
+// enter reports whether the directory n is entered: with FollowAlways,
+// whether it is not one of the directories above it, a loop. It returns the
+// parents of the directories below n.
+func (w *walker) enter(n *dirNode) (*ancestor, bool) {
+	if w.follow != FollowAlways {
+		return nil, true
+	}
+	info, err := n.d.Info()
+	if err != nil {
+		return n.parents, true
+	}
+	dev, ino, ok := fileID(info)
+	if !ok {
+		return n.parents, true
+	}
+	key := fileKey{dev, ino}
+	for a := n.parents; a != nil; a = a.parent {
+		if a.key == key {
+			return nil, false
+		}
+	}
+	return &ancestor{key: key, parent: n.parents}, true
+}
+
+// walk walks the directory n on behalf of goroutine i: it passes n to fn,
+// then the files it holds, and queues the directories it holds.
+func (w *walker) walk(i int, n *dirNode) {
+	if n.top != nil && n.top.path == n.path {
+		n.top.deadline = time.Now().Add(w.budget.timeout)
+	}
+	if w.expired(n) {
+		return
+	}
+	if err := w.fn(n.root, n.path, n.d, n.label, nil); err != nil {
+		if err == filepath.SkipAll {
+			w.queue.stop()
+		}
+		return
+	}
+	parents, ok := w.enter(n)
+	if !ok {
+		return
+	}
+
+	entries, err := w.readDir(n)
+	if err == errExpired {
+		w.expired(n)
+		return
+	}
+	if err != nil {
+		// Second call, to report the ReadDir error.
+		if w.fn(n.root, n.path, n.d, n.label, err) == filepath.SkipAll {
+			w.queue.stop()
+		}
+		return
+	}
+
+	cached := make([]*cachedEntry, len(entries))
+	for i, entry := range entries {
+		cached[i] = &cachedEntry{DirEntry: entry}
+	}
+	if p, ok := w.fsys.(prefetcher); ok {
+		p.prefetch(n.path, cached)
+	}
+	for _, c := range cached {
+		if w.expired(n) || w.queue.stopped.Load() {
+			return
+		}
+		var entry fs.DirEntry = c
+		path := filepath.Join(n.path, entry.Name())
+		if w.follow == FollowAlways {
+			entry = w.deref(path, entry)
+		}
+		if entry.IsDir() {
+			child := &dirNode{root: n.root, path: path, d: entry, label: w.label(n.label, n.root, path, entry), top: n.top, parents: parents}
+			if w.budget != nil && child.top == nil {
+				child.top = &subtree{path: path}
+			}
+			w.queue.push(i, child)
+			continue
+		}
+		switch w.fn(n.root, path, entry, n.label, nil) {
+		case filepath.SkipDir:
+			return
+		case filepath.SkipAll:
+			w.queue.stop()
+			return
+		}
+	}
+}
+
+// readDir reads the directory n, giving up once the budget of its subtree
+// runs out. The read is then left running: a hung network mount may never
+// answer.
+func (w *walker) readDir(n *dirNode) ([]fs.DirEntry, error) {
+	if n.top == nil {
+		return w.fsys.ReadDir(n.path)
+	}
+	type result struct {
+		entries []fs.DirEntry
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		entries, err := w.fsys.ReadDir(n.path)
+		done <- result{entries, err}
+	}()
+	timer := time.NewTimer(time.Until(n.top.deadline))
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.entries, r.err
+	case <-timer.C:
+		return nil, errExpired
+	}
+}
+
+// expired reports whether the budget of the subtree holding n ran out,
+// abandoning the subtree the first time.
+func (w *walker) expired(n *dirNode) bool {
+	t := n.top
+	if t == nil {
+		return false
+	}
+	if t.abandoned.Load() {
+		return true
+	}
+	if time.Now().Before(t.deadline) {
+		return false
+	}
+	if t.abandoned.CompareAndSwap(false, true) {
+		w.budget.abandon(t.path)
+	}
+	return true
+}
+
+// dirQueue holds the directories waiting to be walked, in a deque per
+// goroutine. Each goroutine takes the directory it queued last, which keeps
+// its walk depth first and its queue short, and when it has none, steals
+// the directory queued first by another, likely the root of a large
+// subtree.
+type dirQueue struct {
+	deques  []dirDeque
+	pending atomic.Int64 // directories queued or being walked
+	idle    atomic.Int64 // goroutines waiting for a directory
+	stopped atomic.Bool
+
+	mu   sync.Mutex
+	wake *sync.Cond
+}
+
+// dirDeque is the deque of directories queued by a goroutine.
+type dirDeque struct {
+	mu    sync.Mutex
+	nodes []*dirNode
+}
+
+func newDirQueue(workers int) *dirQueue {
+	q := &dirQueue{deques: make([]dirDeque, workers)}
+	q.wake = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues n on the deque of goroutine i.
+func (q *dirQueue) push(i int, n *dirNode) {
+	q.pending.Add(1)
+	d := &q.deques[i]
+	d.mu.Lock()
+	d.nodes = append(d.nodes, n)
+	d.mu.Unlock()
+	if q.idle.Load() > 0 {
+		q.mu.Lock()
+		q.wake.Signal()
+		q.mu.Unlock()
+	}
+}
+
+// pop returns the next directory for goroutine i to walk, waiting for one
+// while others are being walked, or nil once every directory has been
+// walked or the walk is stopped.
+func (q *dirQueue) pop(i int) *dirNode {
+	for {
+		if n := q.take(i); n != nil {
+			return n
+		}
+		q.mu.Lock()
+		q.idle.Add(1)
+		for !q.stopped.Load() && q.pending.Load() > 0 && q.empty() {
+			q.wake.Wait()
+		}
+		q.idle.Add(-1)
+		over := q.stopped.Load() || q.pending.Load() == 0
+		q.mu.Unlock()
+		if over {
+			return nil
+		}
+	}
+}
+
+// take removes the last directory of the deque of goroutine i, or else the
+// first one of another deque.
+func (q *dirQueue) take(i int) *dirNode {
+	if q.stopped.Load() {
+		return nil
+	}
+	d := &q.deques[i]
+	d.mu.Lock()
+	if k := len(d.nodes); k > 0 {
+		n := d.nodes[k-1]
+		d.nodes[k-1] = nil
+		d.nodes = d.nodes[:k-1]
+		d.mu.Unlock()
+		return n
+	}
+	d.mu.Unlock()
+	for j := 1; j < len(q.deques); j++ {
+		d := &q.deques[(i+j)%len(q.deques)]
+		d.mu.Lock()
+		if len(d.nodes) > 0 {
+			n := d.nodes[0]
+			d.nodes[0] = nil
+			d.nodes = d.nodes[1:]
+			d.mu.Unlock()
+			return n
+		}
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// empty reports whether every deque is empty.
+func (q *dirQueue) empty() bool {
+	for i := range q.deques {
+		d := &q.deques[i]
+		d.mu.Lock()
+		n := len(d.nodes)
+		d.mu.Unlock()
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// done records that a directory popped has been walked.
+func (q *dirQueue) done() {
+	if q.pending.Add(-1) == 0 {
+		q.mu.Lock()
+		q.wake.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// stop ends the walk, leaving the directories still queued.
+func (q *dirQueue) stop() {
+	q.mu.Lock()
+	q.stopped.Store(true)
+	q.wake.Broadcast()
+	q.mu.Unlock()
+}
@@ -0,0 +1,52 @@
+package lfinder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestFollowAlways checks that with FollowAlways a directory reached both
+// directly and through a symlink is walked under both paths, while a link
+// to an ancestor is not descended into.
+func TestFollowAlways(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "d", "e"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, dest := range map[string]string{
+		"d/e/l":  "../../target",
+		"d/e/up": "../..",
+		"dlink":  "d",
+	} {
+		if err := os.Symlink(dest, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		f := NewFinder(WithRoot(dir), WithFollow(FollowAlways), WithSymlinksOnly(), WithWorkers(workers))
+		report, err := f.Find(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range report.Results {
+			rel, err := filepath.Rel(dir, r.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		slices.Sort(got)
+		if want := []string{"d/e/l", "dlink/e/l"}; !slices.Equal(got, want) {
+			t.Errorf("%d workers: found %q, want %q", workers, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+// progressLine is a Sink shown while a search runs: it keeps a line
+// describing the progress of the search at the bottom of the terminal on
+// stderr, and clears it before each result is written to the Sink it wraps,
+// so that results printed to the same terminal are not garbled.
+type progressLine struct {
+	lfinder.Sink
+	tty   *os.File
+	total uint64 // estimated number of entries to examine, 0 if unknown
+
+	mu    sync.Mutex
+	shown bool
+	done  bool
+}
+
+// newProgressLine wraps sink with a progress line on stderr. The number of
+// entries to examine, which the ETA is computed from, is estimated from the
+// files in use on the file systems of roots, so the ETA is an upper bound
+// when a root is not the top of its file system.
+func newProgressLine(sink lfinder.Sink, roots []string) *progressLine {
+	p := &progressLine{Sink: sink, tty: os.Stderr}
+	devices := make(map[uint64]bool)
+	for _, root := range roots {
+		dev, ok := deviceOf(root)
+		if !ok || devices[dev] {
+			continue
+		}
+		devices[dev] = true
+		if n, ok := usedInodes(root); ok {
+			p.total += n
+		}
+	}
+	return p
+}
+
+// observe shows the progress of the search run by f.
+func (p *progressLine) observe(f *lfinder.Finder, cancel context.CancelFunc) {
+	onProgress := f.OnProgress
+	f.OnProgress = func(progress lfinder.Progress) {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+		p.update(progress)
+	}
+	f.ProgressInterval = 200 * time.Millisecond
+}
+
+// Emit clears the progress line and passes r on.
+func (p *progressLine) Emit(r lfinder.Result) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	return p.Sink.Emit(r)
+}
+
+// Flush removes the progress line and flushes the wrapped Sink.
+func (p *progressLine) Flush() error {
+	p.mu.Lock()
+	p.clear()
+	p.done = true
+	p.mu.Unlock()
+	return p.Sink.Flush()
+}
+
+// update redraws the line with progress, or removes it for good once the
+// search is done.
+func (p *progressLine) update(progress lfinder.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	if progress.Done {
+		p.clear()
+		p.done = true
+		return
+	}
+	text := fmt.Sprintf("%d dirs, %d files, %d matches, %.0f entries/s",
+		progress.DirsVisited, progress.FilesExamined, progress.Matches, progress.EntriesPerSec)
+	if eta, ok := p.eta(progress); ok {
+		text += ", ETA " + eta.String()
+	}
+	if progress.CurrentPath != "" {
+		text += " " + progress.CurrentPath
+	}
+	cols, _, err := terminalSize(p.tty)
+	if err != nil || cols <= 1 {
+		cols = 80
+	}
+	fmt.Fprint(p.tty, "\r"+truncate(strings.ReplaceAll(text, "\n", " "), cols-1)+"\x1b[K")
+	p.shown = true
+}
+
+// eta estimates the time left from the throughput so far.
+func (p *progressLine) eta(progress lfinder.Progress) (time.Duration, bool) {
+	seen := uint64(progress.DirsVisited + progress.FilesExamined)
+	if p.total <= seen || progress.EntriesPerSec <= 0 {
+		return 0, false
+	}
+	secs := float64(p.total-seen) / progress.EntriesPerSec
+	return time.Duration(secs * float64(time.Second)).Round(time.Second), true
+}
+
+// clear removes the line from the terminal. p.mu must be held.
+func (p *progressLine) clear() {
+	if p.shown {
+		fmt.Fprint(p.tty, "\r\x1b[K")
+		p.shown = false
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoTerminal is returned on platforms without terminal mode support.
+var errNoTerminal = errors.New("interactive mode is not supported on this platform")
+
+// terminalState is the saved mode of a terminal.
+type terminalState struct{}
+
+func makeRaw(tty *os.File) (*terminalState, error) {
+	return nil, errNoTerminal
+}
+
+func (s *terminalState) restore(tty *os.File) error {
+	return errNoTerminal
+}
+
+func terminalSize(tty *os.File) (cols, rows int, err error) {
+	return 0, 0, errNoTerminal
+}
+
+// isTTY reports false: there is no way to tell a terminal on this platform.
+func isTTY(f *os.File) bool { return false }
@@ -0,0 +1,63 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalState is the saved mode of a terminal, restored when the
+// interactive mode exits.
+type terminalState struct {
+	termios syscall.Termios
+}
+
+// ioctl performs the ioctl request on fd with the argument at arg.
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw puts the terminal tty into raw mode, so that keys are read one at
+// a time without being echoed, and returns its previous state.
+func makeRaw(tty *os.File) (*terminalState, error) {
+	var state terminalState
+	if err := ioctl(tty.Fd(), ioctlGetTermios, unsafe.Pointer(&state.termios)); err != nil {
+		return nil, err
+	}
+	raw := state.termios
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(tty.Fd(), ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// restore puts tty back into the mode saved by makeRaw.
+func (s *terminalState) restore(tty *os.File) error {
+	return ioctl(tty.Fd(), ioctlSetTermios, unsafe.Pointer(&s.termios))
+}
+
+// terminalSize returns the number of columns and rows of tty.
+func terminalSize(tty *os.File) (cols, rows int, err error) {
+	var ws struct{ rows, cols, xpixel, ypixel uint16 }
+	if err := ioctl(tty.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.cols), int(ws.rows), nil
+}
+
+// isTTY reports whether f is a terminal, as isatty(3) does: unlike
+// isTerminal, it tells a terminal from other character devices such as
+// /dev/null.
+func isTTY(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctl(f.Fd(), ioctlGetTermios, unsafe.Pointer(&termios)) == nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errNoTerminal is returned as terminal modes are not supported on Windows.
+var errNoTerminal = errors.New("interactive mode is not supported on this platform")
+
+// terminalState is the saved mode of a terminal.
+type terminalState struct{}
+
+func makeRaw(tty *os.File) (*terminalState, error) {
+	return nil, errNoTerminal
+}
+
+func (s *terminalState) restore(tty *os.File) error {
+	return errNoTerminal
+}
+
+func terminalSize(tty *os.File) (cols, rows int, err error) {
+	return 0, 0, errNoTerminal
+}
+
+// isTTY reports whether f is a console: unlike isTerminal, it tells a
+// console from other character devices such as NUL.
+func isTTY(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hemzaz/lfinder/pkg/lfinder"
+)
+
+// errQuit is returned by tuiSink.Emit once the user has quit, to stop the
+// search feeding it.
+var errQuit = errors.New("quit")
+
+// tuiKeys is the key help shown at the bottom of the screen.
+const tuiKeys = "↑/↓ move  space mark  o open dir  c copy path  e errors  q quit"
+
+// tuiSink is a Sink that shows the results of a search in a live-updating,
+// full-screen table on the terminal, together with the progress and errors of
+// the search. The paths marked by the user are printed on standard output
+// once the table is closed, so they can be piped into a follow-up command.
+type tuiSink struct {
+	tty   *os.File
+	state *terminalState
+	out   io.Writer // where the marked paths are printed
+
+	mu         sync.Mutex
+	results    []lfinder.Result
+	errors     []*lfinder.ScanError
+	marked     map[int]bool
+	cursor     int
+	offset     int // index of the first row shown
+	showErrors bool
+	progress   lfinder.Progress
+	status     string
+	done       bool
+
+	cancel   context.CancelFunc // stops the search
+	redraw   chan struct{}      // wakes the render loop
+	quit     chan struct{}      // closed when the user quits
+	quitOnce sync.Once
+	stopped  chan struct{} // closed when the render loop has exited
+}
+
+// newTUISink switches the controlling terminal to raw mode and the alternate
+// screen and starts drawing. The terminal is used rather than standard input
+// and output, which may be redirected; the marked paths are written to out.
+func newTUISink(out io.Writer) (*tuiSink, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("interactive mode needs a terminal: %v", err)
+	}
+	state, err := makeRaw(tty)
+	if err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("interactive mode needs a terminal: %v", err)
+	}
+	s := &tuiSink{
+		tty:     tty,
+		state:   state,
+		out:     out,
+		marked:  make(map[int]bool),
+		redraw:  make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	go s.readKeys()
+	go s.render()
+	s.update()
+	return s, nil
+}
+
+// observe shows the progress and errors of the search run by f, which
+// cancel stops when the user quits.
+func (s *tuiSink) observe(f *lfinder.Finder, cancel context.CancelFunc) {
+	s.cancel = cancel
+	onError := f.OnError
+	f.OnError = func(err *lfinder.ScanError) {
+		if onError != nil {
+			onError(err)
+		}
+		s.mu.Lock()
+		s.errors = append(s.errors, err)
+		s.mu.Unlock()
+		s.update()
+	}
+	onProgress := f.OnProgress
+	f.OnProgress = func(p lfinder.Progress) {
+		if onProgress != nil {
+			onProgress(p)
+		}
+		s.mu.Lock()
+		s.progress = p
+		s.mu.Unlock()
+		s.update()
+	}
+	f.ProgressInterval = 200 * time.Millisecond
+}
+
+// Emit adds r to the table. It returns errQuit once the user has quit.
+func (s *tuiSink) Emit(r lfinder.Result) error {
+	select {
+	case <-s.quit:
+		return errQuit
+	default:
+	}
+	s.mu.Lock()
+	s.results = append(s.results, r)
+	s.mu.Unlock()
+	s.update()
+	return nil
+}
+
+// Flush waits for the user to quit, restores the terminal, and prints the
+// marked paths.
+func (s *tuiSink) Flush() error {
+	s.mu.Lock()
+	s.done = true
+	s.mu.Unlock()
+	s.update()
+
+	<-s.quit
+	<-s.stopped
+	fmt.Fprint(s.tty, "\x1b[?25h\x1b[?1049l") // show cursor, leave alternate screen
+	err := s.state.restore(s.tty)
+	s.tty.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.results {
+		if s.marked[i] {
+			fmt.Fprintln(s.out, r.Path)
+		}
+	}
+	return err
+}
+
+// update asks the render loop to redraw the screen.
+func (s *tuiSink) update() {
+	select {
+	case s.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// render redraws the screen on request until the user quits. Requests
+// arriving while a frame is drawn are coalesced.
+func (s *tuiSink) render() {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.redraw:
+			s.draw()
+			time.Sleep(30 * time.Millisecond)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// draw writes one frame: a status header, the rows that fit on the screen,
+// and the key help.
+func (s *tuiSink) draw() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cols, rows, err := terminalSize(s.tty)
+	if err != nil || cols <= 0 || rows <= 0 {
+		cols, rows = 80, 24
+	}
+	height := max(rows-4, 1)
+	n := s.rows()
+	s.clamp()
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.cursor >= s.offset+height {
+		s.offset = s.cursor - height + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	p := s.progress
+	line(&b, cols, fmt.Sprintf("\x1b[1mlfinder\x1b[0m  %d matches  %d marked  %d dirs  %d files  %d errors  %s",
+		len(s.results), len(s.marked), p.DirsVisited, p.FilesExamined, len(s.errors), p.Elapsed.Round(time.Second)))
+	switch {
+	case s.done:
+		line(&b, cols, "search finished")
+	case p.CurrentPath != "":
+		line(&b, cols, "searching "+p.CurrentPath)
+	default:
+		line(&b, cols, "searching")
+	}
+	for i := s.offset; i < s.offset+height; i++ {
+		text := ""
+		if i < n {
+			text = s.row(i)
+		}
+		if i == s.cursor && i < n {
+			b.WriteString("\x1b[7m")
+			line(&b, cols, text)
+			b.WriteString("\x1b[0m")
+		} else {
+			line(&b, cols, text)
+		}
+	}
+	status := s.status
+	if status == "" {
+		status = tuiKeys
+	}
+	line(&b, cols, "")
+	b.WriteString(truncate(status, cols))
+	b.WriteString("\x1b[K\x1b[J")
+	s.tty.WriteString(b.String())
+}
+
+// line writes text, cut to cols characters, as a full line of the screen.
+func line(b *strings.Builder, cols int, text string) {
+	b.WriteString(truncate(text, cols))
+	b.WriteString("\x1b[K\r\n")
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// clamp keeps the cursor on a row of the current view.
+func (s *tuiSink) clamp() {
+	s.cursor = min(max(s.cursor, 0), max(s.rows()-1, 0))
+}
+
+// rows returns the number of rows in the current view.
+func (s *tuiSink) rows() int {
+	if s.showErrors {
+		return len(s.errors)
+	}
+	return len(s.results)
+}
+
+// row formats row i of the current view.
+func (s *tuiSink) row(i int) string {
+	if s.showErrors {
+		return "  " + s.errors[i].Error()
+	}
+	r := s.results[i]
+	mark := " "
+	if s.marked[i] {
+		mark = "*"
+	}
+	text := fmt.Sprintf("%s %-8s %s", mark, r.Kind, r.Path)
+	if r.LinkTarget != "" {
+		text += " -> " + r.LinkTarget
+	}
+	return text
+}
+
+// readKeys handles key presses until the user quits.
+func (s *tuiSink) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := s.tty.Read(buf)
+		if err != nil {
+			s.stop()
+			return
+		}
+		keys := string(buf[:n])
+		if strings.HasPrefix(keys, "\x1b[") {
+			s.key(keys)
+			continue
+		}
+		for _, k := range keys {
+			s.key(string(k))
+		}
+	}
+}
+
+// key handles a single key press, given as the bytes the terminal sent.
+func (s *tuiSink) key(k string) {
+	if k == "q" || k == "\x03" { // q or Ctrl-C
+		s.stop()
+		return
+	}
+
+	s.mu.Lock()
+	defer s.update()
+	defer s.mu.Unlock()
+	_, rows, err := terminalSize(s.tty)
+	if err != nil {
+		rows = 24
+	}
+	page := max(rows-4, 1)
+	s.status = ""
+	switch k {
+	case "k", "\x1b[A":
+		s.cursor--
+	case "j", "\x1b[B":
+		s.cursor++
+	case "\x1b[5~": // Page Up
+		s.cursor -= page
+	case "\x1b[6~": // Page Down
+		s.cursor += page
+	case "g", "\x1b[H":
+		s.cursor = 0
+	case "G", "\x1b[F":
+		s.cursor = s.rows() - 1
+	case "e":
+		s.showErrors = !s.showErrors
+		s.cursor, s.offset = 0, 0
+	case " ":
+		s.clamp()
+		if s.showErrors || s.cursor >= len(s.results) {
+			return
+		}
+		if s.marked[s.cursor] {
+			delete(s.marked, s.cursor)
+		} else {
+			s.marked[s.cursor] = true
+		}
+		s.cursor++
+	case "o":
+		s.clamp()
+		if path, ok := s.selected(); ok {
+			s.status = openDir(filepath.Dir(path))
+		}
+	case "c":
+		s.clamp()
+		if path, ok := s.selected(); ok {
+			// OSC 52 asks the terminal emulator to set the clipboard.
+			fmt.Fprintf(s.tty, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(path)))
+			s.status = "copied " + path
+		}
+	}
+}
+
+// selected returns the path under the cursor.
+func (s *tuiSink) selected() (string, bool) {
+	switch {
+	case s.showErrors && s.cursor < len(s.errors):
+		return s.errors[s.cursor].Path, true
+	case !s.showErrors && s.cursor < len(s.results):
+		return s.results[s.cursor].Path, true
+	}
+	return "", false
+}
+
+// stop closes the interface and stops the search.
+func (s *tuiSink) stop() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+}
+
+// openDir opens dir in the desktop's file manager and returns a status message.
+func openDir(dir string) string {
+	name := "xdg-open"
+	switch runtime.GOOS {
+	case "darwin":
+		name = "open"
+	case "windows":
+		name = "explorer"
+	}
+	cmd := exec.Command(name, dir)
+	if err := cmd.Start(); err != nil {
+		return fmt.Sprintf("cannot open %s: %v", dir, err)
+	}
+	go cmd.Wait()
+	return "opened " + dir
+}